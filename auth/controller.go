@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuthController struct {
+	AuthService *AuthService
+}
+
+func NewAuthController(authService *AuthService) AuthController {
+	return AuthController{
+		AuthService: authService,
+	}
+}
+
+// PostGuest issues a token for a new guest player
+func (e *AuthController) PostGuest(c *fiber.Ctx) error {
+	token, claims, err := e.AuthService.IssueGuestToken()
+	if err != nil {
+		log.Printf("Error issuing guest token: %v\n", err)
+		return fiber.ErrInternalServerError
+	}
+	return c.JSON(map[string]interface{}{
+		"token":     token,
+		"playerId":  claims.Subject,
+		"expiresAt": claims.ExpiresAt,
+	})
+}
@@ -0,0 +1,132 @@
+// Package auth issues and verifies signed player tokens
+package auth
+
+import (
+	"crypto/rand"
+	"drbh/partita/session"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PlayerIDLocal is the fiber local holding the verified player ID of a request
+const PlayerIDLocal = "playerId"
+
+// DefaultTokenTTL is how long a guest token stays valid
+const DefaultTokenTTL = 24 * time.Hour
+
+var ErrInvalidToken = errors.New("invalid token")
+var ErrExpiredToken = errors.New("token expired")
+
+// Claims is the signed content of a player token
+type Claims struct {
+	Subject   string `json:"sub"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type AuthService struct {
+	TokenTTL time.Duration
+	signer   *session.Signer
+}
+
+var authServiceInstance *AuthService
+var once sync.Once
+
+func ProvideAuthService() *AuthService {
+	log.Println("ProvideAuthService")
+	return GetAuthServiceInstance()
+}
+
+// GetAuthServiceInstance returns the singleton AuthService. The signing secret is
+// read from PARTITA_AUTH_SECRET, without it tokens are only valid for the lifetime
+// of the process.
+func GetAuthServiceInstance() *AuthService {
+	once.Do(func() {
+		secret := []byte(os.Getenv("PARTITA_AUTH_SECRET"))
+		if len(secret) == 0 {
+			secret = []byte(randomID())
+		}
+		authServiceInstance = NewAuthService(session.NewSigner(secret), DefaultTokenTTL)
+		log.Println("🔐 Successfully connected to Auth Service")
+	})
+	return authServiceInstance
+}
+
+// NewAuthService creates an AuthService, mostly useful for tests
+func NewAuthService(signer *session.Signer, tokenTTL time.Duration) *AuthService {
+	return &AuthService{
+		TokenTTL: tokenTTL,
+		signer:   signer,
+	}
+}
+
+// IssueToken signs a token for the given player ID
+func (e *AuthService) IssueToken(playerID string) (string, Claims, error) {
+	claims := Claims{
+		Subject:   playerID,
+		ExpiresAt: time.Now().Add(e.TokenTTL).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	return e.signer.Sign(string(payload)), claims, nil
+}
+
+// IssueGuestToken signs a token for a freshly generated guest player ID
+func (e *AuthService) IssueGuestToken() (string, Claims, error) {
+	return e.IssueToken("guest-" + randomID())
+}
+
+// VerifyToken checks the signature and expiry of a token and returns its claims
+func (e *AuthService) VerifyToken(token string) (Claims, error) {
+	payload, err := e.signer.Verify(token)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal([]byte(payload), &claims); err != nil || claims.Subject == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return Claims{}, ErrExpiredToken
+	}
+	return claims, nil
+}
+
+// RequireToken is a fiber middleware that rejects requests without a valid token.
+// The token is read from an "Authorization: Bearer" header or the "token" query
+// parameter (browsers cannot set headers on websocket upgrades), and the verified
+// player ID is stored in the PlayerIDLocal local.
+func (e *AuthService) RequireToken(c *fiber.Ctx) error {
+	token := c.Query("token")
+	if header := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimPrefix(header, "Bearer ")
+	}
+	if token == "" {
+		return fiber.NewError(fiber.StatusUnauthorized, "missing token")
+	}
+
+	claims, err := e.VerifyToken(token)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	c.Locals(PlayerIDLocal, claims.Subject)
+	return c.Next()
+}
+
+func randomID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		log.Fatalf("Error generating ID: %v", err)
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"drbh/partita/session"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newTestAuthService(ttl time.Duration) *AuthService {
+	return NewAuthService(session.NewSigner([]byte("secret")), ttl)
+}
+
+func TestIssueGuestToken(t *testing.T) {
+	service := newTestAuthService(time.Hour)
+	token, claims, err := service.IssueGuestToken()
+	if err != nil {
+		t.Fatalf("IssueGuestToken failed, expected %v, got %v", "nil", err)
+	}
+	verified, err := service.VerifyToken(token)
+	if err != nil || verified.Subject != claims.Subject {
+		t.Errorf("VerifyToken failed, expected %v, got %v (%v)", claims.Subject, verified.Subject, err)
+	}
+}
+
+func TestVerifyExpiredToken(t *testing.T) {
+	service := newTestAuthService(-time.Hour)
+	token, _, _ := service.IssueToken("player")
+	if _, err := service.VerifyToken(token); err != ErrExpiredToken {
+		t.Errorf("VerifyToken failed, expected %v, got %v", ErrExpiredToken, err)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	service := newTestAuthService(time.Hour)
+	app := fiber.New()
+	app.Get("/", service.RequireToken, func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals(PlayerIDLocal).(string))
+	})
+
+	response, _ := app.Test(httptest.NewRequest("GET", "/", nil))
+	if response.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("RequireToken failed, expected %v, got %v", fiber.StatusUnauthorized, response.StatusCode)
+	}
+
+	token, _, _ := service.IssueToken("player")
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+	response, _ = app.Test(request)
+	if response.StatusCode != fiber.StatusOK {
+		t.Errorf("RequireToken failed, expected %v, got %v", fiber.StatusOK, response.StatusCode)
+	}
+}
@@ -3,21 +3,19 @@ package background
 
 // Importing necessary packages
 import (
-	"drbh/partita/collision"
+	"crypto/rand"
+	"drbh/partita/bot"
 	"drbh/partita/connection"
 	"drbh/partita/game"
 	"drbh/partita/match"
+	"encoding/hex"
 	"encoding/json"
 	"log"
-	"math"
-	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const limit = 8.0
-const lowerLimit = -limit
-
 // BackgroundServiceInterface defines the methods for the background service
 type BackgroundServiceInterface interface {
 	Start()
@@ -31,9 +29,22 @@ type BackgroundService struct {
 	connectionService  *connection.ConnectionService
 	matchmakingService match.MatchmakingService
 	gameService        *game.GameService
-	collisionService   *collision.LineSegmentManager
+	// instanceID identifies this server when competing for the matcher lease
+	instanceID string
+	// emitOnce registers the tick listener a single time
+	emitOnce sync.Once
+	// reports run one after another off the game loops, see queueReport
+	reports chan func()
+	// droppedReports counts the reports that found the queue full
+	droppedReports atomic.Uint64
 }
 
+// reportQueueSize is how many reports may wait for the report worker
+const reportQueueSize = 256
+
+// reportQueueTimeout is how long a listener waits for room in a full report queue
+const reportQueueTimeout = 2 * time.Second
+
 // Global instances of the BackgroundServiceInterface and BackgroundService
 var BackgroundServiceInterfaceInstance BackgroundServiceInterface
 var BackgroundServiceInstance *BackgroundService
@@ -44,15 +55,20 @@ func NewBackgroundService(
 	connectionService *connection.ConnectionService,
 	matchmakingService match.MatchmakingService,
 	gameService *game.GameService,
-	collisionService *collision.LineSegmentManager,
 ) BackgroundServiceInterface {
 	once.Do(func() {
 		BackgroundServiceInstance = &BackgroundService{
 			connectionService:  connectionService,
 			matchmakingService: matchmakingService,
 			gameService:        gameService,
-			collisionService:   collisionService,
+			instanceID:         newInstanceID(),
+			reports:            make(chan func(), reportQueueSize),
 		}
+		go BackgroundServiceInstance.runReports()
+		gameService.OnGameEnded(BackgroundServiceInstance.reportGameResult)
+		gameService.OnPhaseChanged(BackgroundServiceInstance.reportPhaseChange)
+		gameService.OnRoundEnded(BackgroundServiceInstance.reportRoundEnd)
+		gameService.OnPlayerRenamed(BackgroundServiceInstance.reportRename)
 		log.Println("🍬 Successfully connected to Background Service")
 	})
 	return BackgroundServiceInstance
@@ -66,216 +82,160 @@ func (e *BackgroundService) Start() {
 
 // StartEmitting method starts emitting locations
 func (e *BackgroundService) StartEmitting() {
-	go e.EmitLocations()
+	e.EmitLocations()
 	log.Println("🍟 Successfully started Background Service")
 }
 
-// Constants for the game
-const delta = 0.25
-const speed = 0.50
-const boundary = 8
-const frontFacing = 2 * math.Pi
-const leftFacing = -math.Pi / 2
-const backFacing = frontFacing + math.Pi
-const rightFacing = frontFacing + math.Pi/2
-
-// helper function to create segments from path points
-func createSegments(
-	player *game.Player,
-	playerNextX float64,
-	playerNextZ float64,
-) []collision.Segment {
-	var segments []collision.Segment
-	for i := 0; i < len(player.PathPoints)-1; i++ {
-		newSegment := collision.NewSegmentFromCoords(
-			player.PathPoints[i].X, player.PathPoints[i].Z,
-			player.PathPoints[i+1].X, player.PathPoints[i+1].Z,
-		)
-		segments = append(segments, newSegment)
-	}
-	segments = append(segments, collision.NewSegmentFromCoords(
-		player.PathPoints[len(player.PathPoints)-1].X, player.PathPoints[len(player.PathPoints)-1].Z,
-		playerNextX, playerNextZ,
-	))
-	return segments
+// EmitLocations method sends every game's snapshot to its room after each of
+// the game's ticks, the games run on their own loops
+func (e *BackgroundService) EmitLocations() {
+	e.emitOnce.Do(func() {
+		e.gameService.OnTick(e.reportTick)
+	})
 }
 
-// EmitLocations method emits the locations of the players
-func (e *BackgroundService) EmitLocations() {
-	ticker := time.NewTicker(30 * time.Millisecond)
-	defer ticker.Stop()
+// reportTick sends the snapshot and collisions of a game's tick to its room
+func (e *BackgroundService) reportTick(event game.TickEvent) {
+	e.sendCollisions(event.GameKey, event.Collisions)
+	e.connectionService.SendSnapshotToRoom(event.GameKey, event.Snapshot)
+}
 
-	for range ticker.C {
-		allGames := e.gameService.GetAllGames()
-		for _, currentGame := range allGames {
-			for _, player := range currentGame.Players {
-				e.processPlayerMovement(player, currentGame)
-			}
+// sendCollisions tells the players of a game about the collisions of its last tick
+func (e *BackgroundService) sendCollisions(gameKey string, collisions []game.Collision) {
+	for _, collision := range collisions {
+		var payload map[string]interface{} = map[string]interface{}{
+			"command": "playerCollision",
+			"name":    collision.Collider,
+			"with":    collision.Victim,
+			"time":    collision.Time,
+			"at":      collision.At,
 		}
-		e.updateGameStateAndNotifyClients(allGames)
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("Error marshalling playerCollision payload: %v\n", err)
+			return
+		}
+		e.connectionService.SendToRoom(gameKey, string(payloadBytes))
 	}
 }
 
-// processPlayerMovement processes the movement of a single player
-func (e *BackgroundService) processPlayerMovement(player *game.Player, currentGame *game.Game) {
-	originalX, originalY, originalZ := player.X, player.Y, player.Z
-
-	// move the player
-	nextX, nextZ := e.calculateNextPosition(player)
-
-	// start timer
-	start := time.Now()
+// matcherLease is the lease a server must hold to build matches for the pending queue
+const matcherLease = "matcher:pending_players"
 
-	if len(player.PathPoints) > 1 {
-		playersWhoInitatedCollision := e.checkPlayerCollision(player, nextX, nextZ, currentGame)
+// matcherLeaseTTL is how long the lease outlives a matcher that stopped renewing it
+const matcherLeaseTTL = 3 * time.Second
 
-		// print that player.Name has collided with other players
-		for collider := range playersWhoInitatedCollision {
-			log.Printf("%v has collided with %v\n", player.Name, collider)
-
-			var payload map[string]interface{} = map[string]interface{}{
-				"command": "playerCollision",
-				"name":    collider,
-				"with":    player.Name,
-				"time":    time.Now().UnixNano() / int64(time.Millisecond),
-			}
-			payloadBytes, err := json.Marshal(payload)
-			if err != nil {
-				log.Printf("Error marshalling playerCollision payload: %v\n", err)
-				return
-			}
-			e.connectionService.SendToAll(string(payloadBytes))
-		}
-	}
-
-	// end timer
-	elapsed := time.Since(start)
-
-	if false {
-		log.Printf("Collision detection took %v\n", elapsed)
+// runReports runs the queued reports in order
+func (e *BackgroundService) runReports() {
+	for report := range e.reports {
+		report()
 	}
+}
 
-	// if player has just spawned, don't add a new path point
-	if player.JustSpawned {
-		player.JustSpawned = false
-		log.Printf("🐘🐘🐘 Player has a total of %v path points\n", len(player.PathPoints))
+// queueReport hands a report to the report worker so slow stores never hold up
+// the game loop that called a listener. Reports keep their order, when the
+// worker stays behind for reportQueueTimeout the report is dropped and counted.
+func (e *BackgroundService) queueReport(report func()) {
+	select {
+	case e.reports <- report:
 		return
+	default:
 	}
-
-	// move the player
-	player.X = nextX
-	player.Z = nextZ
-
-	// check if player hits the boundary and reverse its direction
-	e.checkBoundaryCollision(player)
-
-	// only add a new path point if the player has turned
-	if player.Rotation != player.LastRotation {
-		player.PathPoints = append(player.PathPoints, game.PathPoint{
-			X: originalX,
-			Y: originalY,
-			Z: originalZ,
-		})
-		log.Printf("Player has a total of %v path points\n", len(player.PathPoints))
+	timer := time.NewTimer(reportQueueTimeout)
+	defer timer.Stop()
+	select {
+	case e.reports <- report:
+	case <-timer.C:
+		dropped := e.droppedReports.Add(1)
+		log.Printf("Report queue is full, dropped %v reports so far\n", dropped)
 	}
-
-	player.LastRotation = player.Rotation
 }
 
-// calculateNextPosition calculates the next position of a player
-func (e *BackgroundService) calculateNextPosition(player *game.Player) (float64, float64) {
-	nextX := math.Round((player.X+math.Sin(player.Rotation)*speed*delta)*10000) / 10000
-	nextZ := math.Round((player.Z+math.Cos(player.Rotation)*speed*delta)*10000) / 10000
-	return nextX, nextZ
+// reportGameResult queues the result of a finished game, see sendGameResult
+func (e *BackgroundService) reportGameResult(result game.GameResult) {
+	e.queueReport(func() { e.sendGameResult(result) })
 }
 
-// checkPlayerCollision checks for collision between players
-func (e *BackgroundService) checkPlayerCollision(player *game.Player, nextX float64, nextZ float64, currentGame *game.Game) map[string]bool {
-	playerSegments := createSegments(player, player.X, player.Y) //nextX, nextZ)
-
-	playersToReset := make(map[string]bool)
-
-	for _, otherPlayer := range currentGame.Players {
-		if player.Name == otherPlayer.Name || len(player.PathPoints) < 2 || len(otherPlayer.PathPoints) < 2 {
-			continue
-		}
-		otherPlayerNextX, otherPlayerNextZ := e.calculateNextPosition(otherPlayer)
+// sendGameResult updates the ratings of a finished ranked game and tells its players
+func (e *BackgroundService) sendGameResult(result game.GameResult) {
+	payload := map[string]interface{}{
+		"command": "gameOver",
+		"result":  result,
+	}
 
-		otherPlayerSegments := createSegments(otherPlayer, otherPlayerNextX, otherPlayerNextZ)
-		e.collisionService.ClearAllSegments()
-		for _, segment := range playerSegments {
-			e.collisionService.AddSegment(segment)
-		}
-		intersectingSegment := e.collisionService.CheckIntersection(
-			otherPlayerSegments[len(otherPlayerSegments)-1],
-		)
-		if intersectingSegment != nil {
-			playersToReset[otherPlayer.Name] = true
-			e.resetPlayerPosition(player)
+	if result.Ranked {
+		ratings, err := e.matchmakingService.RecordResult(result)
+		if err != nil {
+			log.Printf("Error recording result of %v: %v\n", result.GameKey, err)
 		}
+		payload["ratings"] = ratings
 	}
 
-	return playersToReset
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling gameOver payload: %v\n", err)
+		return
+	}
+	e.connectionService.SendToRoom(result.GameKey, string(payloadBytes))
 }
 
-// resetPlayerPosition resets the position of a player after a collision
-func (e *BackgroundService) resetPlayerPosition(player *game.Player) {
-
-	// random player position
-	x := rand.Float64()*(limit-lowerLimit) + lowerLimit
-	z := rand.Float64()*(limit-lowerLimit) + lowerLimit
+// reportPhaseChange tells the players of a game that it changed phase
+func (e *BackgroundService) reportPhaseChange(event game.PhaseEvent) {
+	payload := map[string]interface{}{
+		"command":  "gamePhase",
+		"gameKey":  event.GameKey,
+		"phase":    event.Phase,
+		"previous": event.Previous,
+		"deadline": event.Deadline,
+		"config":   event.Config,
+		"dropped":  event.Dropped,
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling gamePhase payload: %v\n", err)
+		return
+	}
+	e.connectionService.SendToRoom(event.GameKey, string(payloadBytes))
 
-	// reset player's path points
-	player.PathPoints = []game.PathPoint{
-		{X: x, Y: 0.0, Z: z},
-		{X: x, Y: 0.0, Z: z},
+	if event.Phase == game.PhaseClosed {
+		// queued behind the game's result so the room still gets its gameOver
+		e.queueReport(func() { e.connectionService.RemoveRoom(event.GameKey) })
 	}
-	player.X = x
-	player.Y = 0
-	player.Z = z
-	player.Rotation = frontFacing
-	player.LastRotation = frontFacing
-	player.JustSpawned = true
 }
 
-// checkBoundaryCollision checks if a player hits the boundary and reverses its direction
-func (e *BackgroundService) checkBoundaryCollision(player *game.Player) {
-	if math.Abs(player.X) > boundary {
-		if player.X > 0 {
-			player.Rotation = leftFacing
-		} else {
-			player.Rotation = rightFacing
-		}
-		if player.X > 0 {
-			player.X = boundary
-		} else {
-			player.X = -boundary
-		}
+// reportRoundEnd tells the players of a game who won the round and the scores so far
+func (e *BackgroundService) reportRoundEnd(round game.RoundEvent) {
+	payload := map[string]interface{}{
+		"command":  "roundOver",
+		"gameKey":  round.GameKey,
+		"round":    round.Round,
+		"winner":   round.Winner,
+		"scores":   round.Scores,
+		"gameOver": round.GameOver,
 	}
-	if math.Abs(player.Z) > boundary {
-		if player.Z > 0 {
-			player.Rotation = backFacing
-		} else {
-			player.Rotation = frontFacing
-		}
-		if player.Z > 0 {
-			player.Z = boundary
-		} else {
-			player.Z = -boundary
-		}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling roundOver payload: %v\n", err)
+		return
 	}
+	e.connectionService.SendToRoom(round.GameKey, string(payloadBytes))
 }
 
-// updateGameStateAndNotifyClients updates the game state and notifies all clients
-func (e *BackgroundService) updateGameStateAndNotifyClients(allGames map[string]*game.Game) {
-	// update the game state
-	e.gameService.UpdateAllGames(allGames)
-
-	jsonVersion := e.gameService.GetAllGamesJSON()
-
-	if len(jsonVersion) > 4 {
-		e.connectionService.SendToAll(jsonVersion)
+// reportRename tells the players of a game that one of them changed its display name
+func (e *BackgroundService) reportRename(event game.RenameEvent) {
+	payload := map[string]interface{}{
+		"command":  "playerRenamed",
+		"gameKey":  event.GameKey,
+		"playerId": event.PlayerID,
+		"name":     event.Name,
+		"previous": event.Previous,
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling playerRenamed payload: %v\n", err)
+		return
 	}
+	e.connectionService.SendToRoom(event.GameKey, string(payloadBytes))
 }
 
 // BuildMatches method builds matches for the game
@@ -284,32 +244,51 @@ func (e *BackgroundService) BuildMatches() {
 	defer ticker.Stop()
 
 	for range ticker.C {
+		// only one matcher per queue runs across the cluster
+		if !e.matchmakingService.AcquireLease(matcherLease, e.instanceID, matcherLeaseTTL) {
+			continue
+		}
+
 		pendingPlayers := e.matchmakingService.GetPendingPlayers()
-		var matches []string
 		for _, playerId := range pendingPlayers {
 			playerElo := e.matchmakingService.GetPlayerElo(playerId)
-			match := e.matchmakingService.FindMatch(playerId, playerElo, 100)
-			if match != "" && playerId != match {
-				matches = append(matches, playerId, match)
-
-				// remove players from pending
-				e.matchmakingService.RemovePlayer(playerId)
-				e.matchmakingService.RemovePlayer(match)
+			// the search widens the longer the player has been waiting
+			eloThreshold := e.matchmakingService.CurrentWindow(playerId)
+			match := e.matchmakingService.FindMatch(playerId, playerElo, eloThreshold)
+			if match == "" && e.matchmakingService.ClaimBotMatch(playerId) {
+				// nobody came along in time, a bot of about the player's strength steps in
+				match = bot.NewID(bot.LevelForRating(playerElo))
+			} else if match == "" || playerId == match {
+				continue
+			} else {
+				// both players leave the queue atomically, or neither does if
+				// one of them was claimed in the meantime
+				if !e.matchmakingService.ClaimMatch(playerId, match) {
+					continue
+				}
 
 				// update last played with
 				e.matchmakingService.UpdateLastPlayedWith(playerId, match)
 				e.matchmakingService.UpdateLastPlayedWith(match, playerId)
-
 			}
-		}
-		if len(matches) > 0 {
-			log.Printf("Matches built: %v\n", matches)
-			marshaledMatches, err := json.Marshal(matches)
+
+			// each match is published on its own so listeners can derive the game key
+			log.Printf("Match built: %v vs %v\n", playerId, match)
+			marshaledMatch, err := json.Marshal([]string{playerId, match})
 			if err != nil {
 				log.Println(err)
-				return
+				continue
 			}
-			e.matchmakingService.PublishMatch(string(marshaledMatches))
+			e.matchmakingService.PublishMatch(string(marshaledMatch))
 		}
 	}
 }
+
+// newInstanceID returns a random ID for this server instance
+func newInstanceID() string {
+	bytes := make([]byte, 8)
+	if _, err := rand.Read(bytes); err != nil {
+		log.Fatalf("Error generating instance ID: %v", err)
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -2,7 +2,9 @@ package background
 
 import (
 	"log"
+	"sync"
 	"testing"
+	"time"
 )
 
 type DummyBackgroundService struct {
@@ -70,3 +72,34 @@ func TestBuildMatches(t *testing.T) {
 		t.Errorf("BuildMatches failed, expected %v, got %v", true, service.matchesBuilt)
 	}
 }
+
+func TestQueueReportKeepsOrderWhenFull(t *testing.T) {
+	service := &BackgroundService{reports: make(chan func(), 1)}
+	go service.runReports()
+
+	var mu sync.Mutex
+	var order []int
+	finished := make(chan struct{})
+	for i := 0; i < 20; i++ {
+		i := i
+		service.queueReport(func() {
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		})
+	}
+	service.queueReport(func() { close(finished) })
+	<-finished
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, reported := range order {
+		if reported != i {
+			t.Fatalf("queueReport failed, expected %v, got %v", "reports in order", order)
+		}
+	}
+	if len(order) != 20 || service.droppedReports.Load() != 0 {
+		t.Errorf("queueReport failed, expected %v, got %v", 20, len(order))
+	}
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"drbh/partita/collision"
+	"drbh/partita/game"
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+// quarterTurn is how far a bot turns at once, the same as a player's arrow keys
+const quarterTurn = math.Pi / 2
+
+// Brain steers a bot by looking ahead along its current heading and both
+// quarter turns. A heading scores for crossing a rival's trail early, which
+// eliminates the rival, and loses for running into the arena's boundary.
+type Brain struct {
+	level levelConfig
+	// stretches are the rivals' trails since their last turn, the turns
+	// before are looked up in the game's collision world
+	stretches []collision.Capsule
+	// rng decides the bot's mistakes, seeded from the game and the bot's ID
+	rng *rand.Rand
+}
+
+// NewBrain creates the brain of a bot of level
+func NewBrain(level Level) *Brain {
+	return &Brain{level: levels[level]}
+}
+
+// Decide turns the bot towards the best heading every few ticks, depending on its level
+func (b *Brain) Decide(g *game.Game, player *game.Player) *game.Input {
+	if b.level.every > 1 && g.Tick%b.level.every != 0 {
+		return nil
+	}
+	if b.rng == nil {
+		hash := fnv.New64a()
+		hash.Write([]byte(player.ID))
+		b.rng = rand.New(rand.NewSource(g.Seed ^ int64(hash.Sum64())))
+	}
+
+	headings := []float64{player.Rotation, turn(player.Rotation, -quarterTurn), turn(player.Rotation, quarterTurn)}
+	heading := player.Rotation
+	if b.rng.Float64() < b.level.mistakes {
+		heading = headings[b.rng.Intn(len(headings))]
+	} else {
+		b.loadTrails(g, player)
+		best := math.Inf(-1)
+		for i, candidate := range headings {
+			score := b.score(g, player, candidate)
+			// keeping the heading wins ties, every turn adds to the trail
+			if i == 0 {
+				score += 0.5
+			}
+			if score > best {
+				best, heading = score, candidate
+			}
+		}
+	}
+
+	if heading == player.Rotation {
+		return nil
+	}
+	return &game.Input{Rotation: &heading}
+}
+
+// loadTrails syncs the trails of the bot's living rivals into the game's
+// collision world and collects the stretches since their last turns
+func (b *Brain) loadTrails(g *game.Game, player *game.Player) {
+	b.stretches = b.stretches[:0]
+	for _, id := range g.PlayerIDs() {
+		rival := g.Players[id]
+		if rival.ID == player.ID || !g.IsAlive(rival.ID) || len(rival.PathPoints) == 0 {
+			continue
+		}
+		g.SyncTrail(rival)
+		last := rival.PathPoints[len(rival.PathPoints)-1]
+		b.stretches = append(b.stretches, collision.Capsule{
+			Segment: collision.NewSegmentFromCoords(last.X, last.Z, rival.X, rival.Z),
+			Radius:  rival.Radius,
+		})
+	}
+}
+
+// crossesRival reports whether a move of the bot touches the trail of a living
+// rival, the move is swept with the width of the bot and the rival
+func (b *Brain) crossesRival(g *game.Game, player *game.Player, move collision.Segment) bool {
+	world := g.CollisionWorld()
+	for _, id := range g.PlayerIDs() {
+		rival := g.Players[id]
+		if rival.ID == player.ID || !g.IsAlive(rival.ID) {
+			continue
+		}
+		if _, ok := world.SweptTrailHit(move, player.Radius+rival.Radius, rival.ID); ok {
+			return true
+		}
+	}
+	for _, stretch := range b.stretches {
+		if _, ok := world.SweptIntersection(move, player.Radius+stretch.Radius, stretch.Segment); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// score walks up to lookahead steps along heading, the sooner a trail is crossed
+// the higher the score and the sooner the boundary is reached the lower it is
+func (b *Brain) score(g *game.Game, player *game.Player, heading float64) float64 {
+	step := g.Config.Step()
+	x, z := player.X, player.Z
+	for k := 1; k <= b.level.lookahead; k++ {
+		remaining := float64(b.level.lookahead - k + 1)
+		nextX, nextZ := x+math.Sin(heading)*step, z+math.Cos(heading)*step
+		if math.Abs(nextX) > g.Config.ArenaSize || math.Abs(nextZ) > g.Config.ArenaSize {
+			return -remaining
+		}
+		if b.crossesRival(g, player, collision.NewSegmentFromCoords(x, z, nextX, nextZ)) {
+			return 2 * remaining
+		}
+		x, z = nextX, nextZ
+	}
+	return 0
+}
+
+// turn adds delta to a heading and keeps it within [0, 2π), rounded like the client's headings
+func turn(heading float64, delta float64) float64 {
+	turned := math.Mod(heading+delta, 2*math.Pi)
+	if turned < 0 {
+		turned += 2 * math.Pi
+	}
+	return math.Round(turned*10000) / 10000
+}
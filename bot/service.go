@@ -0,0 +1,163 @@
+// Package bot provides computer controlled players that play inside games
+package bot
+
+import (
+	"crypto/rand"
+	"drbh/partita/game"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Level is the difficulty of a bot
+type Level string
+
+const (
+	Easy   Level = "easy"
+	Medium Level = "medium"
+	Hard   Level = "hard"
+)
+
+// DefaultLevel is used when a bot is added without naming a level
+const DefaultLevel = Medium
+
+// MaxBots is how many bots a single game may hold
+const MaxBots = 6
+
+// idPrefix starts the ID of every bot so matches can tell bots from humans
+const idPrefix = "bot-"
+
+// levelConfig holds how well a bot of a level plays
+type levelConfig struct {
+	// lookahead is how many steps ahead each heading is checked
+	lookahead int
+	// every is how many ticks pass between two decisions
+	every uint64
+	// mistakes is the chance of turning at random instead of picking the best heading
+	mistakes float64
+}
+
+var levels = map[Level]levelConfig{
+	Easy:   {lookahead: 4, every: 8, mistakes: 0.25},
+	Medium: {lookahead: 10, every: 4, mistakes: 0.08},
+	Hard:   {lookahead: 24, every: 1, mistakes: 0},
+}
+
+// ParseLevel returns the named level, the default level for an empty name
+func ParseLevel(name string) (Level, error) {
+	if name == "" {
+		return DefaultLevel, nil
+	}
+	level := Level(name)
+	if _, ok := levels[level]; !ok {
+		return "", fmt.Errorf("unknown bot level %q", name)
+	}
+	return level, nil
+}
+
+// LevelForRating picks the level that suits a player of the given rating
+func LevelForRating(rating float64) Level {
+	switch {
+	case rating < 1400:
+		return Easy
+	case rating < 1700:
+		return Medium
+	default:
+		return Hard
+	}
+}
+
+// NewID returns a fresh player ID for a bot of level
+func NewID(level Level) string {
+	bytes := make([]byte, 4)
+	if _, err := rand.Read(bytes); err != nil {
+		log.Fatalf("Error generating bot ID: %v", err)
+	}
+	return idPrefix + string(level) + "-" + hex.EncodeToString(bytes)
+}
+
+// IsID reports whether a player ID belongs to a bot
+func IsID(id string) bool {
+	return strings.HasPrefix(id, idPrefix)
+}
+
+// LevelOf returns the level encoded in a bot's ID
+func LevelOf(id string) (Level, error) {
+	if !IsID(id) {
+		return "", fmt.Errorf("%v is not a bot", id)
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(id, idPrefix), "-")
+	return ParseLevel(name)
+}
+
+// displayName names a bot after its level and the end of its ID, e.g. "Medium Bot 3f2a"
+func displayName(id string, level Level) string {
+	suffix := id[len(id)-4:]
+	return strings.ToUpper(string(level[:1])) + string(level[1:]) + " Bot " + suffix
+}
+
+type BotService struct {
+	gameService *game.GameService
+}
+
+var botServiceInstance *BotService
+var once sync.Once
+
+func ProvideBotService() *BotService {
+	log.Println("ProvideBotService")
+	return GetBotServiceInstance()
+}
+
+func GetBotServiceInstance() *BotService {
+	once.Do(func() {
+		botServiceInstance = NewBotService(game.GetGameServiceInstance())
+		log.Println("🤖 Successfully connected to Bot Service")
+	})
+	return botServiceInstance
+}
+
+// NewBotService creates a bot service that adds bots to the games of gameService
+func NewBotService(gameService *game.GameService) *BotService {
+	return &BotService{gameService: gameService}
+}
+
+// AddBot adds a new bot of level to the game stored under gameKey, the bot is
+// ready right away
+func (e *BotService) AddBot(gameKey string, level Level) (*game.Player, error) {
+	return e.AddBotWithID(gameKey, NewID(level))
+}
+
+// AddBotWithID adds a bot with a known ID, e.g. one chosen by matchmaking, its
+// level is read from the ID
+func (e *BotService) AddBotWithID(gameKey string, id string) (*game.Player, error) {
+	level, err := LevelOf(id)
+	if err != nil {
+		return nil, err
+	}
+	player := e.gameService.NewPlayer(id)
+	player.Name = displayName(id, level)
+	player.Bot = string(level)
+	player.Brain = NewBrain(level)
+	// counted on the game's loop with the join so concurrent adds cannot pass MaxBots
+	if err := e.gameService.JoinGameIf(gameKey, player, func(g *game.Game) error {
+		bots := 0
+		for _, joined := range g.Players {
+			if joined.IsBot() {
+				bots++
+			}
+		}
+		if bots >= MaxBots {
+			return fmt.Errorf("game %v already has %v bots", gameKey, bots)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := e.gameService.SetReady(gameKey, player); err != nil {
+		return nil, err
+	}
+	log.Printf("🤖 Added %v bot %v to game %v\n", level, id, gameKey)
+	return player, nil
+}
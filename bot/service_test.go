@@ -0,0 +1,140 @@
+package bot
+
+import (
+	"drbh/partita/game"
+	"drbh/partita/modes"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel(""); err != nil || level != DefaultLevel {
+		t.Errorf("ParseLevel failed, expected %v, got %v", DefaultLevel, level)
+	}
+	if _, err := ParseLevel("impossible"); err == nil {
+		t.Errorf("ParseLevel failed, expected %v, got %v", "an error", nil)
+	}
+}
+
+func TestBotIDs(t *testing.T) {
+	id := NewID(Hard)
+	if !IsID(id) || IsID("player-1") {
+		t.Errorf("IsID failed, expected %v, got %v", "only bot IDs", id)
+	}
+	if level, err := LevelOf(id); err != nil || level != Hard {
+		t.Errorf("LevelOf failed, expected %v, got %v", Hard, level)
+	}
+	if err := game.ValidateDisplayName(displayName(id, Medium)); err != nil {
+		t.Errorf("displayName failed, expected %v, got %v", nil, err)
+	}
+}
+
+// runningGame returns a running game with the bot at the origin facing +Z
+func runningGame() (*game.Game, *game.Player) {
+	g := game.NewGame(game.DefaultLifecycleConfig())
+	g.State = game.PhaseRunning
+	player := &game.Player{ID: "bot-hard-0000", Rotation: 2 * math.Pi, PathPoints: []game.PathPoint{{}}}
+	g.Players[player.ID] = player
+	return g, player
+}
+
+func TestBrainCutsRivalTrail(t *testing.T) {
+	g, player := runningGame()
+	// a rival's trail runs along the bot's right hand side
+	g.Players["rival"] = &game.Player{ID: "rival", X: 0.2, Z: 5, PathPoints: []game.PathPoint{{X: 0.2, Z: -5}}}
+
+	input := NewBrain(Hard).Decide(g, player)
+	if input == nil || math.Abs(*input.Rotation-math.Pi/2) > 1e-3 {
+		t.Errorf("Decide failed, expected %v, got %v", math.Pi/2, input)
+	}
+}
+
+func TestBrainAvoidsBoundary(t *testing.T) {
+	g, player := runningGame()
+	player.Z = g.Config.ArenaSize - g.Config.Step()
+
+	if input := NewBrain(Hard).Decide(g, player); input == nil {
+		t.Errorf("Decide failed, expected %v, got %v", "a turn away from the boundary", input)
+	}
+
+	player.Z = 0
+	if input := NewBrain(Hard).Decide(g, player); input != nil {
+		t.Errorf("Decide failed, expected %v, got %v", nil, *input.Rotation)
+	}
+}
+
+func TestAddBot(t *testing.T) {
+	gameService := game.NewGameService()
+	gameService.ManualTicks = true
+	service := NewBotService(gameService)
+
+	g := game.NewGame(game.DefaultLifecycleConfig())
+	g.Lifecycle.CountdownDuration = 0
+	g.Mode = modes.NewSnakeMode()
+	gameService.AddGame("bots", g)
+	host := gameService.NewPlayer("host")
+	gameService.JoinGame("bots", host)
+
+	for i := 0; i < MaxBots; i++ {
+		if _, err := service.AddBot("bots", Hard); err != nil {
+			t.Fatalf("AddBot failed, expected %v, got %v", nil, err)
+		}
+	}
+	if _, err := service.AddBot("bots", Easy); err == nil {
+		t.Errorf("AddBot failed, expected %v, got %v", "too many bots", err)
+	}
+
+	now := time.Now()
+	for tick := 0; tick < 20; tick++ {
+		gameService.Tick("bots", now.Add(time.Duration(tick)*g.Config.TickInterval()))
+	}
+	bots := 0
+	gameService.WithGame("bots", func(g *game.Game) {
+		for _, player := range g.Players {
+			if player.IsBot() && player.Brain != nil {
+				bots++
+			}
+		}
+	})
+	if bots != MaxBots {
+		t.Errorf("AddBot failed, expected %v, got %v", MaxBots, bots)
+	}
+
+	// bots don't keep a game alive on their own
+	gameService.LeaveGame("bots", host)
+	if _, ok := gameService.GetGame("bots"); ok {
+		t.Errorf("LeaveGame failed, expected %v, got %v", "the game to close", ok)
+	}
+}
+
+func TestAddBotConcurrently(t *testing.T) {
+	gameService := game.NewGameService()
+	gameService.ManualTicks = true
+	service := NewBotService(gameService)
+	gameService.AddGame("crowded", game.NewGame(game.DefaultLifecycleConfig()))
+	gameService.JoinGame("crowded", gameService.NewPlayer("host"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4*MaxBots; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			service.AddBot("crowded", Easy)
+		}()
+	}
+	wg.Wait()
+
+	bots := 0
+	gameService.WithGame("crowded", func(g *game.Game) {
+		for _, player := range g.Players {
+			if player.IsBot() {
+				bots++
+			}
+		}
+	})
+	if bots != MaxBots {
+		t.Errorf("AddBot failed, expected %v, got %v", MaxBots, bots)
+	}
+}
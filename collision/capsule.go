@@ -0,0 +1,90 @@
+package collision
+
+import "math"
+
+// Capsule is every point within Radius of Segment, a trail with width
+type Capsule struct {
+	Segment Segment
+	Radius  float64
+}
+
+// CapsuleHit returns where target first enters c, T is the position along target
+// and U the position of the closest point of c's segment. A capsule with no
+// radius is hit like its segment.
+func CapsuleHit(target Segment, c Capsule) (Hit, bool) {
+	if c.Radius <= 0 {
+		return Intersection(target, c.Segment)
+	}
+	entry, ok := capsuleEntry(target, c)
+	if !ok {
+		// rounding can miss the boundary of a thin capsule, crossing its segment still enters it
+		hit, crosses := Intersection(target, c.Segment)
+		if !crosses {
+			return Hit{}, false
+		}
+		entry = hit.T
+	}
+	point := along(target, entry)
+	return Hit{Point: point, T: entry, U: project(c.Segment, point), Segment: c.Segment}, true
+}
+
+// SweptCircleHit returns where a circle of radius moving along path first
+// touches s, the hit's Point is the circle's center at that moment
+func SweptCircleHit(path Segment, radius float64, s Segment) (Hit, bool) {
+	return CapsuleHit(path, Capsule{Segment: s, Radius: radius})
+}
+
+// sweptHitWithin returns where a circle of radius moving along path first comes
+// within epsilon of s, a circle without radius is a point hitting like IntersectionWithin
+func sweptHitWithin(path Segment, radius float64, s Segment, epsilon float64) (Hit, bool) {
+	if radius <= 0 {
+		return IntersectionWithin(path, s, epsilon)
+	}
+	return CapsuleHit(path, Capsule{Segment: s, Radius: radius + epsilon})
+}
+
+// capsuleEntry returns the first position along target on the boundary of c,
+// which is the position target enters c at unless it starts inside
+func capsuleEntry(target Segment, c Capsule) (float64, bool) {
+	s := c.Segment
+	if distance(target.Start, along(s, project(s, target.Start))) <= c.Radius {
+		return 0, true
+	}
+	entry, found := math.Inf(1), false
+
+	// the straight sides of the capsule run along s at radius on either side
+	if d := sub(s.End, s.Start); d.X != 0 || d.Y != 0 {
+		length := math.Hypot(d.X, d.Y)
+		normal := Point{-d.Y / length * c.Radius, d.X / length * c.Radius}
+		for _, side := range []float64{-1, 1} {
+			offset := Point{normal.X * side, normal.Y * side}
+			edge := Segment{Point{s.Start.X + offset.X, s.Start.Y + offset.Y}, Point{s.End.X + offset.X, s.End.Y + offset.Y}}
+			if hit, ok := Intersection(target, edge); ok && hit.T < entry {
+				entry, found = hit.T, true
+			}
+		}
+	}
+
+	// the round ends are circles around the endpoints of s
+	for _, center := range []Point{s.Start, s.End} {
+		if t, ok := circleEntry(target, center, c.Radius); ok && t < entry {
+			entry, found = t, true
+		}
+	}
+	return entry, found
+}
+
+// circleEntry returns the first position along target within radius of center
+func circleEntry(target Segment, center Point, radius float64) (float64, bool) {
+	d, f := sub(target.End, target.Start), sub(target.Start, center)
+	a, b, c := d.X*d.X+d.Y*d.Y, 2*(f.X*d.X+f.Y*d.Y), f.X*f.X+f.Y*f.Y-radius*radius
+	if c <= 0 {
+		return 0, true
+	}
+	discriminant := b*b - 4*a*c
+	if a == 0 || discriminant < 0 {
+		return 0, false
+	}
+	t := (-b - math.Sqrt(discriminant)) / (2 * a)
+	return t, t >= 0 && t <= 1
+}
@@ -0,0 +1,122 @@
+package collision
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestCapsuleHit(t *testing.T) {
+	trail := Segment{Point{0, 0}, Point{4, 0}}
+	tests := []struct {
+		name   string
+		target Segment
+		radius float64
+		ok     bool
+		t      float64
+	}{
+		{"grazing the side", Segment{Point{1, -1}, Point{1, 1}}, 0.5, true, 0.25},
+		{"passing beside", Segment{Point{-1, 0.4}, Point{5, 0.4}}, 0.5, true, 0.7 / 6},
+		{"passing outside", Segment{Point{-1, 0.6}, Point{5, 0.6}}, 0.5, false, 0},
+		{"round end", Segment{Point{6, 0}, Point{-2, 0}}, 1, true, 0.125},
+		{"starting inside", Segment{Point{2, 0.1}, Point{2, 5}}, 0.5, true, 0},
+		{"no radius", Segment{Point{1, -1}, Point{1, 1}}, 0, true, 0.5},
+	}
+	for _, test := range tests {
+		hit, ok := CapsuleHit(test.target, Capsule{Segment: trail, Radius: test.radius})
+		if ok != test.ok || (ok && math.Abs(hit.T-test.t) > 1e-9) {
+			t.Errorf("CapsuleHit %s failed, expected %v, got %v", test.name, test.t, hit)
+		}
+	}
+}
+
+func TestSweptCircleCannotTunnel(t *testing.T) {
+	// a gap narrower than the circle between two trails, crossed in a single huge step
+	left, right := Segment{Point{-0.04, -10}, Point{-0.04, 10}}, Segment{Point{0.04, -10}, Point{0.04, 10}}
+	path := Segment{Point{0, -1000}, Point{0, 1000}}
+	for _, s := range []Segment{left, right} {
+		if _, ok := SweptCircleHit(path, 0.05, s); !ok {
+			t.Errorf("SweptCircleHit failed, expected %v, got %v for %v", true, false, s)
+		}
+		if _, ok := Intersection(path, s); ok {
+			t.Errorf("Intersection failed, expected %v, got %v for %v", false, true, s)
+		}
+	}
+}
+
+func TestCapsuleHitMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	for i := 0; i < 3000; i++ {
+		target := Segment{Point{rounded(rng, 2), rounded(rng, 2)}, Point{rounded(rng, 2), rounded(rng, 2)}}
+		s := Segment{Point{rounded(rng, 2), rounded(rng, 2)}, Point{rounded(rng, 2), rounded(rng, 2)}}
+		radius := rng.Float64()
+		distance := referenceDistance(target, s)
+		limit := new(big.Rat).Mul(rat(radius), rat(radius))
+		// the float distance cannot decide within a hair of the radius
+		if ratio, _ := new(big.Rat).Quo(distance, limit).Float64(); math.Abs(ratio-1) < 1e-6 {
+			continue
+		}
+
+		hit, ok := CapsuleHit(target, Capsule{Segment: s, Radius: radius})
+		if expected := distance.Cmp(limit) <= 0; ok != expected {
+			t.Fatalf("CapsuleHit failed, expected %v, got %v for %v and %v with radius %v", expected, ok, target, s, radius)
+		}
+		if !ok {
+			continue
+		}
+		// the circle touches s at the hit and stays clear of it before
+		if d := pointDistance(hit.Point, s); d > radius+1e-9 {
+			t.Fatalf("CapsuleHit failed, expected %v, got %v for %v and %v", "a point within radius", hit, target, s)
+		}
+		for k := 0; k < 20 && hit.T > 0; k++ {
+			earlier := along(target, hit.T*float64(k)/20)
+			if d := pointDistance(earlier, s); d < radius-1e-9 {
+				t.Fatalf("CapsuleHit failed, expected %v, got %v for %v and %v", "no earlier touch", hit, target, s)
+			}
+		}
+	}
+}
+
+// pointDistance returns the distance between p and s
+func pointDistance(p Point, s Segment) float64 {
+	return distance(p, along(s, project(s, p)))
+}
+
+func TestSegmentGridSweptHits(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	grid := NewSegmentGrid(DefaultCellSize)
+	var all []Segment
+	var owners []string
+	for player := 0; player < 8; player++ {
+		for _, segment := range randomTrail(rng, 50, 8) {
+			grid.Add(fmt.Sprint(player), segment)
+			all = append(all, segment)
+			owners = append(owners, fmt.Sprint(player))
+		}
+	}
+
+	for i := 0; i < 300; i++ {
+		path, radius := randomTrail(rng, 1, 8)[0], rng.Float64()*2
+		expected := make(map[string]float64)
+		for j, segment := range all {
+			hit, ok := sweptHitWithin(path, radius, segment, grid.Epsilon())
+			if first, seen := expected[owners[j]]; ok && (!seen || hit.T < first) {
+				expected[owners[j]] = hit.T
+			}
+		}
+		found := make(map[string]bool)
+		for _, hit := range grid.SweptHits(path, radius) {
+			found[hit.Owner] = true
+		}
+		if len(found) != len(expected) {
+			t.Fatalf("SweptHits failed, expected %v, got %v", expected, found)
+		}
+		for owner, first := range expected {
+			if hit, ok := grid.FirstSweptHit(path, radius, owner); !ok || hit.T != first {
+				t.Fatalf("FirstSweptHit failed, expected %v, got %v", first, hit)
+			}
+		}
+	}
+}
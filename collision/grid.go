@@ -0,0 +1,250 @@
+package collision
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultCellSize is the cell size of a SegmentGrid in arena units, a few steps
+// of a player at the default speed
+const DefaultCellSize = 1.0
+
+// cell is the integer coordinate of a grid cell
+type cell struct {
+	x, y int
+}
+
+// gridEntry is a segment stored in a SegmentGrid
+type gridEntry struct {
+	segment Segment
+	owner   string
+	removed bool
+}
+
+// SegmentGrid is a uniform grid over segments that grows with the trails it
+// holds. Segments are added as trails get longer and removed by owner when a
+// trail is reset, queries only test the segments in the cells the target
+// covers. A SegmentGrid is not safe for concurrent use, every game owns its own.
+type SegmentGrid struct {
+	cellSize float64
+	// epsilon is how close a target has to come to a segment to hit it
+	epsilon float64
+	cells   map[cell][]int
+	entries []gridEntry
+	// owners indexes the live entries of each owner
+	owners  map[string][]int
+	removed int
+	// visited stamps entries during a query so segments spanning several
+	// cells are tested once
+	visited []uint32
+	stamp   uint32
+}
+
+// NewSegmentGrid creates an empty grid with DefaultEpsilon, cellSize falls back
+// to DefaultCellSize when not positive
+func NewSegmentGrid(cellSize float64) *SegmentGrid {
+	if cellSize <= 0 {
+		cellSize = DefaultCellSize
+	}
+	return &SegmentGrid{
+		cellSize: cellSize,
+		epsilon:  DefaultEpsilon,
+		cells:    make(map[cell][]int),
+		owners:   make(map[string][]int),
+	}
+}
+
+// Epsilon returns how close a target has to come to a segment to hit it
+func (g *SegmentGrid) Epsilon() float64 {
+	return g.epsilon
+}
+
+// SetEpsilon sets how close a target has to come to a segment to hit it, 0 only
+// counts segments that meet exactly
+func (g *SegmentGrid) SetEpsilon(epsilon float64) {
+	g.epsilon = max(0, epsilon)
+}
+
+// Len returns the number of segments in the grid
+func (g *SegmentGrid) Len() int {
+	return len(g.entries) - g.removed
+}
+
+// Add stores a segment of owner
+func (g *SegmentGrid) Add(owner string, s Segment) {
+	index := len(g.entries)
+	g.entries = append(g.entries, gridEntry{segment: s, owner: owner})
+	g.visited = append(g.visited, 0)
+	g.owners[owner] = append(g.owners[owner], index)
+	g.forCells(s, func(c cell) {
+		g.cells[c] = append(g.cells[c], index)
+	})
+}
+
+// RemoveOwner drops every segment of owner
+func (g *SegmentGrid) RemoveOwner(owner string) {
+	indexes, ok := g.owners[owner]
+	if !ok {
+		return
+	}
+	delete(g.owners, owner)
+	for _, index := range indexes {
+		g.entries[index].removed = true
+		g.forCells(g.entries[index].segment, func(c cell) {
+			g.cells[c] = without(g.cells[c], index)
+			if len(g.cells[c]) == 0 {
+				delete(g.cells, c)
+			}
+		})
+	}
+	g.removed += len(indexes)
+	// removed entries are compacted away once they make up most of the grid
+	if g.removed > len(g.entries)/2 {
+		g.compact()
+	}
+}
+
+// Owners returns the owners of the segments of the grid, sorted
+func (g *SegmentGrid) Owners() []string {
+	owners := make([]string, 0, len(g.owners))
+	for owner := range g.owners {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+	return owners
+}
+
+// FirstHit returns the hit of target on a segment of owner closest to target's Start
+func (g *SegmentGrid) FirstHit(target Segment, owner string) (Hit, bool) {
+	return g.FirstSweptHit(target, 0, owner)
+}
+
+// Hits returns every hit of target, ordered along target
+func (g *SegmentGrid) Hits(target Segment) []Hit {
+	return g.SweptHits(target, 0)
+}
+
+// FirstSweptHit returns where a circle of radius moving along path first touches
+// a segment of owner
+func (g *SegmentGrid) FirstSweptHit(path Segment, radius float64, owner string) (Hit, bool) {
+	var first Hit
+	found := false
+	g.query(path, radius, func(entry *gridEntry) {
+		if entry.owner != owner {
+			return
+		}
+		hit, ok := sweptHitWithin(path, radius, entry.segment, g.epsilon)
+		hit.Owner = owner
+		if ok && (!found || hit.before(first)) {
+			first, found = hit, true
+		}
+	})
+	return first, found
+}
+
+// SweptHits returns every segment a circle of radius moving along path touches,
+// ordered along path
+func (g *SegmentGrid) SweptHits(path Segment, radius float64) []Hit {
+	var hits []Hit
+	g.query(path, radius, func(entry *gridEntry) {
+		if hit, ok := sweptHitWithin(path, radius, entry.segment, g.epsilon); ok {
+			hit.Owner = entry.owner
+			hits = append(hits, hit)
+		}
+	})
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].before(hits[j])
+	})
+	return hits
+}
+
+// query calls visit once for every live entry sharing a cell with target or
+// within radius and epsilon of it
+func (g *SegmentGrid) query(target Segment, radius float64, visit func(entry *gridEntry)) {
+	g.stamp++
+	if g.stamp == 0 {
+		// the stamp wrapped around, old stamps could match again
+		for i := range g.visited {
+			g.visited[i] = 0
+		}
+		g.stamp = 1
+	}
+	margin := max(0, radius) + g.epsilon
+	if count := g.cellCount(target, margin); !(count <= float64(len(g.entries))) {
+		// a query spanning more cells than there are entries scans the entries instead
+		for index := range g.entries {
+			if !g.entries[index].removed {
+				visit(&g.entries[index])
+			}
+		}
+		return
+	}
+	g.forCellsAround(target, margin, func(c cell) {
+		for _, index := range g.cells[c] {
+			if g.visited[index] == g.stamp {
+				continue
+			}
+			g.visited[index] = g.stamp
+			visit(&g.entries[index])
+		}
+	})
+}
+
+// forCells calls fn for every cell of the bounding box of s
+func (g *SegmentGrid) forCells(s Segment, fn func(c cell)) {
+	g.forCellsAround(s, 0, fn)
+}
+
+// forCellsAround calls fn for every cell of the bounding box of s grown by margin
+func (g *SegmentGrid) forCellsAround(s Segment, margin float64, fn func(c cell)) {
+	minX, maxX := g.cellOf(min(s.Start.X, s.End.X)-margin), g.cellOf(max(s.Start.X, s.End.X)+margin)
+	minY, maxY := g.cellOf(min(s.Start.Y, s.End.Y)-margin), g.cellOf(max(s.Start.Y, s.End.Y)+margin)
+	for x := minX; x <= maxX; x++ {
+		for y := minY; y <= maxY; y++ {
+			fn(cell{x, y})
+		}
+	}
+}
+
+// cellCount returns how many cells forCellsAround visits, as a float so huge
+// bounding boxes cannot overflow
+func (g *SegmentGrid) cellCount(s Segment, margin float64) float64 {
+	width := math.Floor((max(s.Start.X, s.End.X)+margin)/g.cellSize) - math.Floor((min(s.Start.X, s.End.X)-margin)/g.cellSize) + 1
+	height := math.Floor((max(s.Start.Y, s.End.Y)+margin)/g.cellSize) - math.Floor((min(s.Start.Y, s.End.Y)-margin)/g.cellSize) + 1
+	return width * height
+}
+
+// cellOf returns the cell coordinate of a position along one axis
+func (g *SegmentGrid) cellOf(value float64) int {
+	return int(math.Floor(value / g.cellSize))
+}
+
+// compact rebuilds the grid from its live entries
+func (g *SegmentGrid) compact() {
+	entries := g.entries
+	g.cells = make(map[cell][]int)
+	g.owners = make(map[string][]int)
+	g.entries = nil
+	g.visited = nil
+	g.removed = 0
+	for _, entry := range entries {
+		if !entry.removed {
+			g.Add(entry.owner, entry.segment)
+		}
+	}
+}
+
+// without removes value from a slice of indexes in place
+func without(indexes []int, value int) []int {
+	for i, index := range indexes {
+		if index == value {
+			return append(indexes[:i], indexes[i+1:]...)
+		}
+	}
+	return indexes
+}
+
+// Intersects reports whether two segments intersect, touching counts
+func Intersects(a, b Segment) bool {
+	return doIntersect(a.Start, a.End, b.Start, b.End)
+}
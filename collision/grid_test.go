@@ -0,0 +1,150 @@
+package collision
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// randomTrail returns a trail of quarter turns through an arena of the given half size
+func randomTrail(rng *rand.Rand, turns int, size float64) []Segment {
+	x, y := rng.Float64()*2*size-size, rng.Float64()*2*size-size
+	segments := make([]Segment, 0, turns)
+	for i := 0; i < turns; i++ {
+		length := rng.Float64() * 2
+		nextX, nextY := x, y
+		if i%2 == 0 {
+			nextX = clamp(x+length*float64(rng.Intn(3)-1), size)
+		} else {
+			nextY = clamp(y+length*float64(rng.Intn(3)-1), size)
+		}
+		segments = append(segments, NewSegmentFromCoords(x, y, nextX, nextY))
+		x, y = nextX, nextY
+	}
+	return segments
+}
+
+func clamp(value, size float64) float64 {
+	return max(-size, min(size, value))
+}
+
+func TestSegmentGridMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	grid := NewSegmentGrid(DefaultCellSize)
+	var all []Segment
+	var owners []string
+	for player := 0; player < 8; player++ {
+		for _, segment := range randomTrail(rng, 50, 8) {
+			grid.Add(fmt.Sprint(player), segment)
+			all = append(all, segment)
+			owners = append(owners, fmt.Sprint(player))
+		}
+	}
+
+	for i := 0; i < 500; i++ {
+		target := randomTrail(rng, 1, 8)[0]
+		var expected []Hit
+		for j, segment := range all {
+			if hit, ok := IntersectionWithin(target, segment, grid.Epsilon()); ok {
+				hit.Owner = owners[j]
+				expected = append(expected, hit)
+			}
+		}
+		found := grid.Hits(target)
+		if len(found) != len(expected) {
+			t.Fatalf("Hits failed, expected %v, got %v", expected, found)
+		}
+		for j, hit := range found {
+			if j > 0 && hit.before(found[j-1]) {
+				t.Fatalf("Hits failed, expected %v, got %v", "hits ordered along the target", found)
+			}
+			if first, ok := grid.FirstHit(target, hit.Owner); !ok || first.T > hit.T {
+				t.Fatalf("FirstHit failed, expected %v, got %v", hit, first)
+			}
+		}
+	}
+}
+
+func TestSegmentGridRemoveOwner(t *testing.T) {
+	grid := NewSegmentGrid(DefaultCellSize)
+	grid.Add("a", Segment{Point{5, 0}, Point{5, 10}})
+	grid.Add("b", Segment{Point{4, 0}, Point{4, 10}})
+	target := Segment{Point{0, 5}, Point{10, 5}}
+	if found := grid.Hits(target); len(found) != 2 || found[0].Owner != "b" || found[1].Owner != "a" {
+		t.Errorf("Hits failed, expected %v, got %v", []string{"b", "a"}, found)
+	}
+
+	grid.RemoveOwner("a")
+	if found := grid.Hits(target); len(found) != 1 || found[0].Owner != "b" || grid.Len() != 1 {
+		t.Errorf("RemoveOwner failed, expected %v, got %v", []string{"b"}, found)
+	}
+	if owners := grid.Owners(); len(owners) != 1 || owners[0] != "b" {
+		t.Errorf("Owners failed, expected %v, got %v", []string{"b"}, owners)
+	}
+}
+
+func TestSegmentGridScansHugeQueries(t *testing.T) {
+	grid := NewSegmentGrid(DefaultCellSize)
+	grid.Add("a", Segment{Point{5, 0}, Point{5, 10}})
+	grid.Add("b", Segment{Point{4, 0}, Point{4, 10}})
+	grid.Add("c", Segment{Point{-3, 0}, Point{-3, 10}})
+	grid.RemoveOwner("c")
+
+	// both would walk about 10^18 cells
+	if found := grid.Hits(Segment{Point{-1e9, 5}, Point{1e9, 5}}); len(found) != 2 || found[0].Owner != "b" || found[1].Owner != "a" {
+		t.Errorf("Hits failed, expected %v, got %v", []string{"b", "a"}, found)
+	}
+	if found := grid.SweptHits(Segment{Point{0, 5}, Point{1, 5}}, 1e9); len(found) != 2 {
+		t.Errorf("SweptHits failed, expected %v, got %v", 2, found)
+	}
+}
+
+// benchmarkSizes are the numbers of trail segments per game the benchmarks scale through
+var benchmarkSizes = []int{100, 1000, 5000, 20000}
+
+func BenchmarkSegmentGridQuery(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			rng := rand.New(rand.NewSource(1))
+			grid := NewSegmentGrid(DefaultCellSize)
+			for i, segment := range randomTrail(rng, size, 32) {
+				grid.Add(fmt.Sprint(i%16), segment)
+			}
+			targets := randomTrail(rng, 256, 32)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				grid.FirstHit(targets[i%len(targets)], "0")
+			}
+		})
+	}
+}
+
+func BenchmarkSegmentGridAdd(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	segments := randomTrail(rng, 4096, 32)
+	grid := NewSegmentGrid(DefaultCellSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		grid.Add("player", segments[i%len(segments)])
+	}
+}
+
+// BenchmarkLineSegmentManagerQuery is the sweep every query used before the
+// grid, it sorts every segment on each call and is too slow to run beyond a
+// thousand segments
+func BenchmarkLineSegmentManagerQuery(b *testing.B) {
+	for _, size := range benchmarkSizes[:2] {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			rng := rand.New(rand.NewSource(1))
+			manager := NewLineSegmentManager()
+			for i, segment := range randomTrail(rng, size, 32) {
+				manager.AddSegment(fmt.Sprint(i%16), segment)
+			}
+			targets := randomTrail(rng, 256, 32)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				manager.CheckIntersection(targets[i%len(targets)])
+			}
+		})
+	}
+}
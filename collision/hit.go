@@ -0,0 +1,143 @@
+package collision
+
+import "math"
+
+// Hit is where a target segment meets a stored segment. T is the position of the
+// hit along the target and U along the stored segment, both from 0 at Start to 1
+// at End. Segment and Owner are the stored segment and the owner it was added with.
+type Hit struct {
+	Point   Point
+	T       float64
+	U       float64
+	Segment Segment
+	Owner   string
+}
+
+// Self reports whether the hit is on a segment of owner
+func (h Hit) Self(owner string) bool {
+	return h.Owner == owner
+}
+
+// before orders hits along the target, ties go by owner and then along the stored segment
+func (h Hit) before(other Hit) bool {
+	if h.T != other.T {
+		return h.T < other.T
+	}
+	if h.Owner != other.Owner {
+		return h.Owner < other.Owner
+	}
+	return h.U < other.U
+}
+
+// Intersection returns where target first meets s, starting from target's Start.
+// Collinear segments meet where their overlap starts, touching counts.
+func Intersection(target, s Segment) (Hit, bool) {
+	return IntersectionWithin(target, s, 0)
+}
+
+// IntersectionWithin is Intersection where segments that pass within epsilon of
+// each other touch as well, where the first endpoint that close to the other
+// segment is
+func IntersectionWithin(target, s Segment, epsilon float64) (Hit, bool) {
+	crosses := doIntersect(target.Start, target.End, s.Start, s.End)
+	collinear := orientation(s.Start, s.End, target.Start) == 0 && orientation(s.Start, s.End, target.End) == 0
+	if crosses && !collinear {
+		// target's endpoints lie on either side of s, it crosses s where the
+		// areas they span with s balance out
+		q := sub(s.End, s.Start)
+		startArea, endArea := cross(q, sub(target.Start, s.Start)), cross(q, sub(target.End, s.Start))
+		if startArea != endArea {
+			// nearly parallel segments can only cross where target runs alongside s
+			lo, hi := project(target, s.Start), project(target, s.End)
+			t := max(min(lo, hi), min(max(lo, hi), clamp01(startArea/(startArea-endArea))))
+			point := along(target, t)
+			return Hit{Point: point, T: t, U: project(s, point), Segment: s}, true
+		}
+	}
+
+	// segments that do not cross come closest at an endpoint of one of them,
+	// collinear segments meet at the endpoints their overlap starts and ends with
+	var first, closest *endpointHit
+	candidates := endpointHits(target, s)
+	for i := range candidates {
+		candidate := &candidates[i]
+		if (candidate.exact || (epsilon > 0 && candidate.distance <= epsilon)) && (first == nil || candidate.hit.T < first.hit.T) {
+			first = candidate
+		}
+		if closest == nil || candidate.distance < closest.distance {
+			closest = candidate
+		}
+	}
+	if first == nil && crosses {
+		// the areas are equal in floating point but the segments cross exactly, they meet where they are closest
+		first = closest
+	}
+	if first == nil {
+		return Hit{}, false
+	}
+	return first.hit, true
+}
+
+// endpointHit is a hit at an endpoint of target or of the segment it is tested against
+type endpointHit struct {
+	hit Hit
+	// distance is how far the endpoint is from the other segment
+	distance float64
+	// exact is set when the endpoint lies exactly on the other segment
+	exact bool
+}
+
+// endpointHits returns the hits at the endpoints of target and s, each on the
+// point of the other segment closest to it
+func endpointHits(target, s Segment) [4]endpointHit {
+	var hits [4]endpointHit
+	for i, p := range []Point{target.Start, target.End} {
+		u := project(s, p)
+		hits[i] = endpointHit{
+			hit:      Hit{Point: p, T: float64(i), U: u, Segment: s},
+			distance: distance(p, along(s, u)),
+			exact:    liesOn(p, s),
+		}
+	}
+	for i, p := range []Point{s.Start, s.End} {
+		t := project(target, p)
+		onTarget := along(target, t)
+		hits[2+i] = endpointHit{
+			hit:      Hit{Point: onTarget, T: t, U: float64(i), Segment: s},
+			distance: distance(p, onTarget),
+			exact:    liesOn(p, target),
+		}
+	}
+	return hits
+}
+
+// project returns the position of p along s, 0 for a segment without length
+func project(s Segment, p Point) float64 {
+	d := sub(s.End, s.Start)
+	length := d.X*d.X + d.Y*d.Y
+	if length == 0 {
+		return 0
+	}
+	return clamp01((d.X*(p.X-s.Start.X) + d.Y*(p.Y-s.Start.Y)) / length)
+}
+
+// along returns the point at position t along s
+func along(s Segment, t float64) Point {
+	return Point{s.Start.X + t*(s.End.X-s.Start.X), s.Start.Y + t*(s.End.Y-s.Start.Y)}
+}
+
+func distance(a, b Point) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+func sub(a, b Point) Point {
+	return Point{a.X - b.X, a.Y - b.Y}
+}
+
+func cross(a, b Point) float64 {
+	return a.X*b.Y - a.Y*b.X
+}
+
+func clamp01(value float64) float64 {
+	return max(0, min(1, value))
+}
@@ -0,0 +1,83 @@
+package collision
+
+import (
+	"math"
+	"math/big"
+)
+
+// DefaultEpsilon is how close segments have to come to touch. Positions are
+// rounded to four decimals, trails that meet in the game can end up that far apart.
+const DefaultEpsilon = 1e-4
+
+// orientationErrorBound bounds the rounding error of the floating point
+// determinant in orientation relative to the magnitude of its terms, from
+// Shewchuk's "Adaptive Precision Floating-Point Arithmetic and Fast Robust
+// Geometric Predicates"
+var orientationErrorBound = (3 + 16*machineEpsilon) * machineEpsilon
+
+// machineEpsilon is half the distance from 1 to the next float64
+const machineEpsilon = 1.0 / (1 << 53)
+
+// orientation returns 0 when p, q and r are collinear, 1 when they turn clockwise
+// and 2 when they turn counterclockwise. The sign is exact, the determinant is
+// computed in floating point and only recomputed exactly when it is too close
+// to 0 for its rounding error.
+func orientation(p, q, r Point) int {
+	left := (q.X - p.X) * (r.Y - p.Y)
+	right := (q.Y - p.Y) * (r.X - p.X)
+	det := left - right
+	// rounding keeps the signs of the terms, when they differ or one of them is 0
+	// the sign of det is exact already
+	if (left > 0) != (right > 0) || left == 0 || right == 0 {
+		return orientationSign(det)
+	}
+	// an infinite bound means the terms overflowed, which positions in an arena never do
+	if bound := orientationErrorBound * (math.Abs(left) + math.Abs(right)); math.Abs(det) <= bound && !math.IsInf(bound, 1) {
+		det = exactOrientation(p, q, r)
+	}
+	return orientationSign(det)
+}
+
+// orientationSign maps the sign of a determinant to the result of orientation
+func orientationSign(det float64) int {
+	switch {
+	case det < 0:
+		return 1
+	case det > 0:
+		return 2
+	}
+	return 0
+}
+
+// exactOrientation returns the sign of the determinant of orientation computed
+// with rational numbers, every finite float64 is exactly representable as one
+func exactOrientation(p, q, r Point) float64 {
+	rat := func(value float64) *big.Rat {
+		return new(big.Rat).SetFloat64(value)
+	}
+	diff := func(a, b float64) *big.Rat {
+		return new(big.Rat).Sub(rat(a), rat(b))
+	}
+	left := new(big.Rat).Mul(diff(q.X, p.X), diff(r.Y, p.Y))
+	right := new(big.Rat).Mul(diff(q.Y, p.Y), diff(r.X, p.X))
+	return float64(left.Cmp(right))
+}
+
+// onSegment reports whether q lies within the bounding box of p and r, for a q
+// collinear with them it lies on the segment
+func onSegment(p, q, r Point) bool {
+	return q.X <= max(p.X, r.X) && q.X >= min(p.X, r.X) && q.Y <= max(p.Y, r.Y) && q.Y >= min(p.Y, r.Y)
+}
+
+// liesOn reports whether p lies exactly on s
+func liesOn(p Point, s Segment) bool {
+	return orientation(s.Start, s.End, p) == 0 && onSegment(s.Start, p, s.End)
+}
+
+func doIntersect(p1, q1, p2, q2 Point) bool {
+	o1, o2, o3, o4 := orientation(p1, q1, p2), orientation(p1, q1, q2), orientation(p2, q2, p1), orientation(p2, q2, q1)
+	if o1 != o2 && o3 != o4 {
+		return true
+	}
+	return (o1 == 0 && onSegment(p1, p2, q1)) || (o2 == 0 && onSegment(p1, q2, q1)) || (o3 == 0 && onSegment(p2, p1, q2)) || (o4 == 0 && onSegment(p2, q1, q2))
+}
@@ -0,0 +1,235 @@
+package collision
+
+import (
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// The reference implementation below computes every predicate with rational
+// numbers and no shortcuts, it is slow but exact
+
+func rat(value float64) *big.Rat {
+	return new(big.Rat).SetFloat64(value)
+}
+
+type ratPoint struct {
+	x, y *big.Rat
+}
+
+func toRat(p Point) ratPoint {
+	return ratPoint{rat(p.X), rat(p.Y)}
+}
+
+func (a ratPoint) sub(b ratPoint) ratPoint {
+	return ratPoint{new(big.Rat).Sub(a.x, b.x), new(big.Rat).Sub(a.y, b.y)}
+}
+
+func (a ratPoint) dot(b ratPoint) *big.Rat {
+	return new(big.Rat).Add(new(big.Rat).Mul(a.x, b.x), new(big.Rat).Mul(a.y, b.y))
+}
+
+func (a ratPoint) cross(b ratPoint) *big.Rat {
+	return new(big.Rat).Sub(new(big.Rat).Mul(a.x, b.y), new(big.Rat).Mul(a.y, b.x))
+}
+
+func referenceOrientation(p, q, r Point) int {
+	a, b, c := toRat(p), toRat(q), toRat(r)
+	switch b.sub(a).cross(c.sub(a)).Sign() {
+	case -1:
+		return 1
+	case 1:
+		return 2
+	}
+	return 0
+}
+
+func referenceOnSegment(p Point, s Segment) bool {
+	between := func(value, a, b float64) bool {
+		return rat(value).Cmp(rat(min(a, b))) >= 0 && rat(value).Cmp(rat(max(a, b))) <= 0
+	}
+	return referenceOrientation(s.Start, s.End, p) == 0 && between(p.X, s.Start.X, s.End.X) && between(p.Y, s.Start.Y, s.End.Y)
+}
+
+func referenceIntersects(a, b Segment) bool {
+	o1, o2 := referenceOrientation(a.Start, a.End, b.Start), referenceOrientation(a.Start, a.End, b.End)
+	o3, o4 := referenceOrientation(b.Start, b.End, a.Start), referenceOrientation(b.Start, b.End, a.End)
+	if o1 != 0 && o2 != 0 && o3 != 0 && o4 != 0 {
+		return o1 != o2 && o3 != o4
+	}
+	return referenceOnSegment(b.Start, a) || referenceOnSegment(b.End, a) || referenceOnSegment(a.Start, b) || referenceOnSegment(a.End, b)
+}
+
+// referenceDistance returns the squared distance between p and s
+func referencePointDistance(p Point, s Segment) *big.Rat {
+	a, b, c := toRat(s.Start), toRat(s.End), toRat(p)
+	d := b.sub(a)
+	length := d.dot(d)
+	if length.Sign() == 0 {
+		return c.sub(a).dot(c.sub(a))
+	}
+	t := new(big.Rat).Quo(c.sub(a).dot(d), length)
+	if t.Sign() < 0 {
+		t.SetInt64(0)
+	} else if t.Cmp(big.NewRat(1, 1)) > 0 {
+		t.SetInt64(1)
+	}
+	closest := ratPoint{new(big.Rat).Add(a.x, new(big.Rat).Mul(t, d.x)), new(big.Rat).Add(a.y, new(big.Rat).Mul(t, d.y))}
+	return c.sub(closest).dot(c.sub(closest))
+}
+
+// referenceDistance returns the squared distance between two segments
+func referenceDistance(a, b Segment) *big.Rat {
+	if referenceIntersects(a, b) {
+		return new(big.Rat)
+	}
+	closest := referencePointDistance(a.Start, b)
+	for _, d := range []*big.Rat{referencePointDistance(a.End, b), referencePointDistance(b.Start, a), referencePointDistance(b.End, a)} {
+		if d.Cmp(closest) < 0 {
+			closest = d
+		}
+	}
+	return closest
+}
+
+// nearlyCollinearSegment returns a segment whose endpoints lie on or a few ulps
+// next to the line through s, where floating point orientation tests go wrong
+func nearlyCollinearSegment(rng *rand.Rand, s Segment) Segment {
+	point := func() Point {
+		t := rng.Float64()*1.5 - 0.25
+		p := along(s, t)
+		for i := rng.Intn(3); i > 0; i-- {
+			p.X = math.Nextafter(p.X, math.Inf(rng.Intn(2)*2-1))
+			p.Y = math.Nextafter(p.Y, math.Inf(rng.Intn(2)*2-1))
+		}
+		return p
+	}
+	return Segment{point(), point()}
+}
+
+// rounded returns a random position rounded to four decimals like the game's
+func rounded(rng *rand.Rand, size float64) float64 {
+	return math.Round((rng.Float64()*2*size-size)*10000) / 10000
+}
+
+func TestOrientationNearlyCollinear(t *testing.T) {
+	// the points of Kettner et al.'s "Classroom Examples of Robustness Problems
+	// in Geometric Computations", the naive determinant gets many of them wrong
+	q, r := Point{12, 12}, Point{24, 24}
+	for i := 0; i < 64; i++ {
+		for j := 0; j < 64; j++ {
+			p := Point{0.5 + float64(i)*math.Pow(2, -53), 0.5 + float64(j)*math.Pow(2, -53)}
+			if got, expected := orientation(p, q, r), referenceOrientation(p, q, r); got != expected {
+				t.Fatalf("orientation failed, expected %v, got %v for %v", expected, got, p)
+			}
+		}
+	}
+}
+
+func TestIntersectsMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		a := Segment{Point{rounded(rng, 8), rounded(rng, 8)}, Point{rounded(rng, 8), rounded(rng, 8)}}
+		b := nearlyCollinearSegment(rng, a)
+		if i%2 == 0 {
+			// quarter turns like the game's trails
+			b = Segment{a.End, Point{a.End.X, rounded(rng, 8)}}
+		}
+		if got, expected := Intersects(a, b), referenceIntersects(a, b); got != expected {
+			t.Fatalf("Intersects failed, expected %v, got %v for %v and %v", expected, got, a, b)
+		}
+	}
+}
+
+func TestIntersectionWithinMatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, epsilon := range []float64{0, DefaultEpsilon, 0.01} {
+		limit := new(big.Rat).Mul(rat(epsilon), rat(epsilon))
+		for i := 0; i < 2000; i++ {
+			a := Segment{Point{rounded(rng, 1), rounded(rng, 1)}, Point{rounded(rng, 1), rounded(rng, 1)}}
+			b := nearlyCollinearSegment(rng, a)
+			// move b off the line by up to twice epsilon
+			offset := (rng.Float64()*4 - 2) * epsilon
+			b.Start.Y += offset
+			b.End.Y += offset
+
+			distance := referenceDistance(a, b)
+			if epsilon > 0 {
+				// the float distance cannot decide within a hair of epsilon
+				ratio, _ := new(big.Rat).Quo(distance, limit).Float64()
+				if math.Abs(ratio-1) < 1e-6 {
+					continue
+				}
+			}
+			_, got := IntersectionWithin(a, b, epsilon)
+			if expected := distance.Cmp(limit) <= 0; got != expected {
+				t.Fatalf("IntersectionWithin failed, expected %v, got %v for %v and %v within %v", expected, got, a, b, epsilon)
+			}
+		}
+	}
+}
+
+func TestIntersectionProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 5000; i++ {
+		a := Segment{Point{rounded(rng, 2), rounded(rng, 2)}, Point{rounded(rng, 2), rounded(rng, 2)}}
+		b := Segment{Point{rounded(rng, 2), rounded(rng, 2)}, Point{rounded(rng, 2), rounded(rng, 2)}}
+		if i%3 == 0 {
+			b = nearlyCollinearSegment(rng, a)
+		}
+		checkIntersectionProperties(t, a, b, DefaultEpsilon)
+	}
+}
+
+// checkIntersectionProperties checks what holds for any two segments
+func checkIntersectionProperties(t *testing.T, a, b Segment, epsilon float64) {
+	hit, ok := IntersectionWithin(a, b, epsilon)
+	if _, symmetric := IntersectionWithin(b, a, epsilon); ok != symmetric {
+		t.Fatalf("IntersectionWithin failed, expected %v, got %v for %v and %v swapped", ok, symmetric, a, b)
+	}
+	reversed := Segment{b.End, b.Start}
+	if _, same := IntersectionWithin(a, reversed, epsilon); ok != same {
+		t.Fatalf("IntersectionWithin failed, expected %v, got %v for %v and %v reversed", ok, same, a, b)
+	}
+	if _, wider := IntersectionWithin(a, b, 2*epsilon+1e-9); ok && !wider {
+		t.Fatalf("IntersectionWithin failed, expected %v, got %v for %v and %v with a larger epsilon", true, false, a, b)
+	}
+	if exact := Intersects(a, b); exact && !ok {
+		t.Fatalf("IntersectionWithin failed, expected %v, got %v for the crossing %v and %v", true, false, a, b)
+	}
+	if !ok {
+		return
+	}
+	if hit.T < 0 || hit.T > 1 || hit.U < 0 || hit.U > 1 || hit.Segment != b {
+		t.Fatalf("IntersectionWithin failed, expected %v, got %v for %v and %v", "parameters within [0, 1]", hit, a, b)
+	}
+	// the point lies on a and within epsilon of b, give or take rounding
+	tolerance := epsilon + 1e-9*(1+math.Abs(hit.Point.X)+math.Abs(hit.Point.Y))
+	if d := distance(hit.Point, along(a, hit.T)); d > tolerance {
+		t.Fatalf("IntersectionWithin failed, expected %v, got %v for %v and %v", "a point at T on the target", hit, a, b)
+	}
+	if d := distance(hit.Point, along(b, hit.U)); d > tolerance {
+		t.Fatalf("IntersectionWithin failed, expected %v, got %v for %v and %v", "a point near U on the segment", hit, a, b)
+	}
+}
+
+func FuzzIntersects(f *testing.F) {
+	f.Add(0.5, 0.5, 24.0, 24.0, 12.0, 12.0, 36.0, 36.0)
+	f.Add(0.0, 0.0, 4.0, 0.0, 4.0, 0.0, 4.0, 2.0)
+	f.Add(0.1, 0.2, 0.3, 0.6, 0.2, 0.4, 0.4, 0.8)
+	f.Add(-1.0, 0.0, 1.0, 0.0, 0.0, 1e-300, 0.0, -1e-300)
+	f.Fuzz(func(t *testing.T, x1, y1, x2, y2, x3, y3, x4, y4 float64) {
+		for _, value := range []float64{x1, y1, x2, y2, x3, y3, x4, y4} {
+			// positions are finite and small enough for their products to be finite
+			if math.IsNaN(value) || math.Abs(value) > 1e100 {
+				t.Skip()
+			}
+		}
+		a, b := NewSegmentFromCoords(x1, y1, x2, y2), NewSegmentFromCoords(x3, y3, x4, y4)
+		if got, expected := Intersects(a, b), referenceIntersects(a, b); got != expected {
+			t.Fatalf("Intersects failed, expected %v, got %v for %v and %v", expected, got, a, b)
+		}
+		checkIntersectionProperties(t, a, b, 0)
+	})
+}
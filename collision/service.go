@@ -5,33 +5,33 @@ import (
 	"sync"
 )
 
+// Point is a position in the arena's plane, Y is the arena's Z axis
 type Point struct {
-	x, y float64
+	X, Y float64
 }
 
+// Segment is a straight piece of a trail from Start to End
 type Segment struct {
-	start, end Point
+	Start, End Point
 }
 
 type Event struct {
 	point   Point
 	seg     *Segment
+	owner   string
 	isStart bool
 }
 
+// LineSegmentManager checks a target against a set of segments with a sweep over
+// all of them, games use a World instead which only looks at nearby segments
 type LineSegmentManager struct {
 	events []Event
 	mu     sync.Mutex
 }
 
-var instance *LineSegmentManager
-var once sync.Once
-
-func GetLineSegmentManagerInstance() *LineSegmentManager {
-	once.Do(func() {
-		instance = &LineSegmentManager{}
-	})
-	return instance
+// NewLineSegmentManager creates an empty manager
+func NewLineSegmentManager() *LineSegmentManager {
+	return &LineSegmentManager{}
 }
 
 func NewPoint(x, y float64) Point {
@@ -53,33 +53,11 @@ func (lsm *LineSegmentManager) ClearAllSegments() {
 	lsm.events = []Event{}
 }
 
-func (lsm *LineSegmentManager) AddSegment(s Segment) {
+// AddSegment stores a segment tagged with owner, which hits on it report
+func (lsm *LineSegmentManager) AddSegment(owner string, s Segment) {
 	lsm.mu.Lock()
 	defer lsm.mu.Unlock()
-	lsm.events = append(lsm.events, Event{point: s.start, seg: &s, isStart: true}, Event{point: s.end, seg: &s, isStart: false})
-}
-
-func orientation(p, q, r Point) int {
-	val := (q.y-p.y)*(r.x-q.x) - (q.x-p.x)*(r.y-q.y)
-	if val == 0 {
-		return 0
-	}
-	if val > 0 {
-		return 1
-	}
-	return 2
-}
-
-func onSegment(p, q, r Point) bool {
-	return q.x <= max(p.x, r.x) && q.x >= min(p.x, r.x) && q.y <= max(p.y, r.y) && q.y >= min(p.y, r.y)
-}
-
-func doIntersect(p1, q1, p2, q2 Point) bool {
-	o1, o2, o3, o4 := orientation(p1, q1, p2), orientation(p1, q1, q2), orientation(p2, q2, p1), orientation(p2, q2, q1)
-	if o1 != o2 && o3 != o4 {
-		return true
-	}
-	return (o1 == 0 && onSegment(p1, p2, q1)) || (o2 == 0 && onSegment(p1, q2, q1)) || (o3 == 0 && onSegment(p2, p1, q2)) || (o4 == 0 && onSegment(p2, q1, q2))
+	lsm.events = append(lsm.events, Event{point: s.Start, seg: &s, owner: owner, isStart: true}, Event{point: s.End, seg: &s, owner: owner, isStart: false})
 }
 
 func min(a, b float64) float64 {
@@ -96,37 +74,44 @@ func max(a, b float64) float64 {
 	return b
 }
 
-func (lsm *LineSegmentManager) CheckIntersection(target Segment) *Segment {
+// CheckIntersection returns the hit of target on a stored segment closest to
+// target's Start, or nil when target crosses none of them
+func (lsm *LineSegmentManager) CheckIntersection(target Segment) *Hit {
 	lsm.mu.Lock()
 	defer lsm.mu.Unlock()
 
 	segments := append([]Event(nil), lsm.events...)
-	segments = append(segments, Event{point: target.start, seg: &target, isStart: true}, Event{point: target.end, seg: &target, isStart: false})
+	segments = append(segments, Event{point: target.Start, seg: &target, isStart: true}, Event{point: target.End, seg: &target, isStart: false})
 
 	sort.Slice(segments, func(i, j int) bool {
-		if segments[i].point.x == segments[j].point.x {
-			return segments[i].point.y < segments[j].point.y
+		if segments[i].point.X == segments[j].point.X {
+			return segments[i].point.Y < segments[j].point.Y
 		}
-		return segments[i].point.x < segments[j].point.x
+		return segments[i].point.X < segments[j].point.X
 	})
 
-	active := make(map[*Segment]struct{})
+	var first *Hit
+	active := make(map[*Segment]string)
 	for _, e := range segments {
 		if e.isStart {
-			for seg := range active {
-
-				// log.Printf("Checking intersection between %v and %v\n", seg, e.seg)
-
-				if doIntersect(seg.start, seg.end, e.seg.start, e.seg.end) {
-					if e.seg == &target || seg == &target {
-						return seg
+			for seg, owner := range active {
+				stored := seg
+				if seg == &target {
+					stored, owner = e.seg, e.owner
+				} else if e.seg != &target {
+					continue
+				}
+				if hit, ok := Intersection(target, *stored); ok {
+					hit.Owner = owner
+					if first == nil || hit.before(*first) {
+						first = &hit
 					}
 				}
 			}
-			active[e.seg] = struct{}{}
+			active[e.seg] = e.owner
 		} else {
 			delete(active, e.seg)
 		}
 	}
-	return nil
+	return first
 }
@@ -5,19 +5,89 @@ import (
 )
 
 func TestAddSegment(t *testing.T) {
-	manager := GetLineSegmentManagerInstance()
-	manager.AddSegment(Segment{Point{5, 0}, Point{5, 10}})
+	manager := NewLineSegmentManager()
+	manager.AddSegment("a", Segment{Point{5, 0}, Point{5, 10}})
 	if len(manager.events) != 2 {
 		t.Errorf("AddSegment failed, expected %v, got %v", 2, len(manager.events))
 	}
 }
 
 func TestCheckIntersection(t *testing.T) {
-	manager := GetLineSegmentManagerInstance()
-	manager.AddSegment(Segment{Point{5, 0}, Point{5, 10}})
+	manager := NewLineSegmentManager()
+	manager.AddSegment("a", Segment{Point{5, 0}, Point{5, 10}})
 	target := Segment{Point{0, 5}, Point{10, 5}}
-	intersectingSegment := manager.CheckIntersection(target)
-	if intersectingSegment == nil {
-		t.Errorf("CheckIntersection failed, expected %v, got %v", "not nil", "nil")
+	hit := manager.CheckIntersection(target)
+	if hit == nil {
+		t.Fatalf("CheckIntersection failed, expected %v, got %v", "not nil", "nil")
+	}
+	if hit.Owner != "a" || hit.Point != (Point{5, 5}) || hit.T != 0.5 || hit.U != 0.5 {
+		t.Errorf("CheckIntersection failed, expected %v, got %v", Hit{Point: Point{5, 5}, T: 0.5, U: 0.5, Owner: "a"}, *hit)
+	}
+
+	// the hit closest to the target's start wins
+	manager.AddSegment("b", Segment{Point{2, 0}, Point{2, 10}})
+	if hit = manager.CheckIntersection(target); hit == nil || hit.Owner != "b" || hit.Point != (Point{2, 5}) {
+		t.Errorf("CheckIntersection failed, expected %v, got %v", "a hit on b at (2, 5)", hit)
+	}
+}
+
+func TestIntersection(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Segment
+		s      Segment
+		ok     bool
+		hit    Hit
+	}{
+		{"crossing", Segment{Point{0, 0}, Point{4, 0}}, Segment{Point{1, -1}, Point{1, 3}}, true, Hit{Point: Point{1, 0}, T: 0.25, U: 0.25}},
+		{"touching end", Segment{Point{0, 0}, Point{4, 0}}, Segment{Point{4, 0}, Point{4, 2}}, true, Hit{Point: Point{4, 0}, T: 1, U: 0}},
+		{"collinear overlap", Segment{Point{0, 0}, Point{4, 0}}, Segment{Point{6, 0}, Point{2, 0}}, true, Hit{Point: Point{2, 0}, T: 0.5, U: 1}},
+		{"collinear from inside", Segment{Point{3, 0}, Point{8, 0}}, Segment{Point{0, 0}, Point{4, 0}}, true, Hit{Point: Point{3, 0}, T: 0, U: 0.75}},
+		{"parallel", Segment{Point{0, 0}, Point{4, 0}}, Segment{Point{0, 1}, Point{4, 1}}, false, Hit{}},
+		{"miss", Segment{Point{0, 0}, Point{4, 0}}, Segment{Point{5, -1}, Point{5, 1}}, false, Hit{}},
+		{"point on segment", Segment{Point{2, 0}, Point{2, 0}}, Segment{Point{0, 0}, Point{4, 0}}, true, Hit{Point: Point{2, 0}, T: 0, U: 0.5}},
+	}
+	for _, test := range tests {
+		hit, ok := Intersection(test.target, test.s)
+		test.hit.Segment = test.s
+		if ok != test.ok || (ok && hit != test.hit) {
+			t.Errorf("Intersection %s failed, expected %v, got %v", test.name, test.hit, hit)
+		}
+	}
+}
+
+func TestWorldsAreIndependent(t *testing.T) {
+	factory := ProvideWorldFactory()
+	first, second := factory(), factory()
+	trail := []Point{{5, 0}, {5, 10}}
+	first.SyncTrail("player", len(trail), func(i int) Point { return trail[i] })
+
+	target := Segment{Point{0, 5}, Point{10, 5}}
+	if hit, ok := first.TrailHit(target, "player"); !ok || hit.Owner != "player" || hit.Point != (Point{5, 5}) {
+		t.Errorf("TrailHit failed, expected %v, got %v", "a hit on player at (5, 5)", hit)
+	}
+	if _, ok := second.TrailHit(target, "player"); ok || len(second.Trails()) != 0 {
+		t.Errorf("TrailHit failed, expected %v, got %v", false, second.Trails())
+	}
+}
+
+func TestSyncTrail(t *testing.T) {
+	world := NewWorld(DefaultCellSize)
+	trail := []Point{{0, 0}, {0, 4}}
+	sync := func() { world.SyncTrail("player", len(trail), func(i int) Point { return trail[i] }) }
+	sync()
+
+	// a turn adds a segment
+	trail = append(trail, Point{4, 4})
+	sync()
+	if _, ok := world.TrailHit(Segment{Point{2, 3}, Point{2, 5}}, "player"); !ok {
+		t.Errorf("SyncTrail failed, expected %v, got %v", "the new turn to be indexed", world.Trails())
+	}
+
+	// a respawn replaces the trail
+	trail = []Point{{-5, -5}, {-5, -5}}
+	sync()
+	if _, ok := world.TrailHit(Segment{Point{-1, 2}, Point{1, 2}}, "player"); ok {
+		t.Errorf("SyncTrail failed, expected %v, got %v", "the old trail to be gone", world.Trails())
 	}
 }
@@ -0,0 +1,121 @@
+package collision
+
+import "log"
+
+// World holds the trails of a single game. Every game owns its own world and
+// only touches it from its own loop, so worlds of different games never share
+// state and run in parallel without locking.
+type World struct {
+	trails *SegmentGrid
+	// synced tracks how much of each trail is indexed
+	synced map[string]syncedTrail
+}
+
+// syncedTrail is how far a trail was indexed, first tells a reset trail from a grown one
+type syncedTrail struct {
+	points int
+	first  Point
+}
+
+// WorldFactory creates the collision world of a new game
+type WorldFactory func() *World
+
+// NewWorld creates an empty world indexed by a grid of cellSize
+func NewWorld(cellSize float64) *World {
+	return &World{
+		trails: NewSegmentGrid(cellSize),
+		synced: make(map[string]syncedTrail),
+	}
+}
+
+// ProvideWorldFactory returns the factory games get their collision worlds from
+func ProvideWorldFactory() WorldFactory {
+	log.Println("ProvideWorldFactory")
+	return func() *World {
+		return NewWorld(DefaultCellSize)
+	}
+}
+
+// Epsilon returns how close segments have to come to touch in the world
+func (w *World) Epsilon() float64 {
+	return w.trails.Epsilon()
+}
+
+// SetEpsilon sets how close segments have to come to touch in the world
+func (w *World) SetEpsilon(epsilon float64) {
+	w.trails.SetEpsilon(epsilon)
+}
+
+// Intersection returns where target first meets s, with the world's epsilon
+func (w *World) Intersection(target, s Segment) (Hit, bool) {
+	return IntersectionWithin(target, s, w.Epsilon())
+}
+
+// SweptIntersection returns where a circle of radius moving along path first
+// touches s, with the world's epsilon
+func (w *World) SweptIntersection(path Segment, radius float64, s Segment) (Hit, bool) {
+	return sweptHitWithin(path, radius, s, w.Epsilon())
+}
+
+// SyncTrail brings the trail of owner up to date, point returns the i-th of its
+// length turns. Only turns added since the last sync are indexed, a trail that
+// got shorter or starts somewhere else was reset and is indexed anew.
+func (w *World) SyncTrail(owner string, length int, point func(i int) Point) {
+	synced, ok := w.synced[owner]
+	if ok && (length < synced.points || (synced.points > 0 && length > 0 && point(0) != synced.first)) {
+		w.trails.RemoveOwner(owner)
+		synced = syncedTrail{}
+	}
+	for i := synced.points; i < length; i++ {
+		if i == 0 {
+			continue
+		}
+		w.trails.Add(owner, Segment{point(i - 1), point(i)})
+	}
+	if length > 0 {
+		synced = syncedTrail{points: length, first: point(0)}
+	}
+	w.synced[owner] = synced
+}
+
+// RemoveTrail drops the trail of owner, e.g. when it left the game
+func (w *World) RemoveTrail(owner string) {
+	w.trails.RemoveOwner(owner)
+	delete(w.synced, owner)
+}
+
+// Trails returns the owners of the synced trails, sorted
+func (w *World) Trails() []string {
+	return w.trails.Owners()
+}
+
+// Synced returns the owners ever synced, including those whose trail is still empty
+func (w *World) Synced() []string {
+	owners := make([]string, 0, len(w.synced))
+	for owner := range w.synced {
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// TrailHit returns where target first crosses the indexed trail of owner
+func (w *World) TrailHit(target Segment, owner string) (Hit, bool) {
+	return w.trails.FirstHit(target, owner)
+}
+
+// Hits returns every crossing of target with an indexed trail, ordered along target
+func (w *World) Hits(target Segment) []Hit {
+	return w.trails.Hits(target)
+}
+
+// SweptTrailHit returns where a circle of radius moving along path first touches
+// the indexed trail of owner, radius covers the width of both the circle and the trail
+func (w *World) SweptTrailHit(path Segment, radius float64, owner string) (Hit, bool) {
+	return w.trails.FirstSweptHit(path, radius, owner)
+}
+
+// SweptHits returns every indexed trail a circle of radius moving along path
+// touches, ordered along path
+func (w *World) SweptHits(path Segment, radius float64) []Hit {
+	return w.trails.SweptHits(path, radius)
+}
@@ -0,0 +1,20 @@
+package connection
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+type ConnectionController struct {
+	ConnectionService *ConnectionService
+}
+
+func NewConnectionController(connectionService *ConnectionService) ConnectionController {
+	return ConnectionController{
+		ConnectionService: connectionService,
+	}
+}
+
+// Get returns the outbound queue statistics of all connections together
+func (e *ConnectionController) Get(c *fiber.Ctx) error {
+	return c.JSON(e.ConnectionService.QueueStats())
+}
@@ -9,7 +9,17 @@ import (
 )
 
 type ConnectionService struct {
-	Connections      map[string]*websocket.Conn
+	Connections map[string]*websocket.Conn
+	// Writers owns the outbound queue of every connection, all writes go through them
+	Writers map[string]*ConnectionWriter
+	// evicted holds the writers dropped from Writers after evicting their
+	// consumer until the connection is removed and waits for them to stop
+	evicted map[string]*ConnectionWriter
+	// Rooms maps a game key to the set of connection keys that receive its updates
+	Rooms map[string]map[string]struct{}
+	// Spectators maps a game key to the connections watching it, see spectators.go
+	Spectators       map[string]*spectatorRoom
+	WriterConfig     WriterConfig
 	ConnectionsMutex sync.Mutex
 }
 
@@ -25,6 +35,11 @@ func GetConnectionServiceInstance() *ConnectionService {
 	once.Do(func() {
 		connectionServiceInstance = &ConnectionService{
 			Connections:      make(map[string]*websocket.Conn),
+			Writers:          make(map[string]*ConnectionWriter),
+			evicted:          make(map[string]*ConnectionWriter),
+			Rooms:            make(map[string]map[string]struct{}),
+			Spectators:       make(map[string]*spectatorRoom),
+			WriterConfig:     DefaultWriterConfig(),
 			ConnectionsMutex: sync.Mutex{},
 		}
 		log.Println("🔌 Successfully connected to Connection Service")
@@ -40,8 +55,9 @@ func (e *ConnectionService) PrintAllConnections() {
 
 func (e *ConnectionService) AddConnection(key string, conn *websocket.Conn) {
 	e.ConnectionsMutex.Lock()
-	defer e.ConnectionsMutex.Unlock()
-	e.Connections[key] = conn
+	stale := e.setConnection(key, conn)
+	e.ConnectionsMutex.Unlock()
+	closeWriters(stale)
 }
 
 func (e *ConnectionService) GetConnection(key string) (*websocket.Conn, bool) {
@@ -51,17 +67,74 @@ func (e *ConnectionService) GetConnection(key string) (*websocket.Conn, bool) {
 	return conn, ok
 }
 
+// RemoveConnection forgets a connection and returns once its writer stopped, so
+// the handler may release the connection afterwards
 func (e *ConnectionService) RemoveConnection(key string) {
 	e.ConnectionsMutex.Lock()
-	defer e.ConnectionsMutex.Unlock()
 	delete(e.Connections, key)
+	stale := e.takeWriters(key)
+	e.leaveAllRooms(key)
+	e.removeSpectator(key)
+	e.ConnectionsMutex.Unlock()
+
+	// a write in flight may take up to the write timeout, other connections keep sending meanwhile
+	closeWriters(stale)
 	log.Println("❌ Successfully removed connection")
 }
 
 func (e *ConnectionService) UpdateConnection(key string, conn *websocket.Conn) {
 	e.ConnectionsMutex.Lock()
-	defer e.ConnectionsMutex.Unlock()
+	stale := e.setConnection(key, conn)
+	e.ConnectionsMutex.Unlock()
+	closeWriters(stale)
+}
+
+// setConnection stores conn and gives it a fresh writer, it returns the writers
+// of the replaced connection for the caller to close once it released
+// ConnectionsMutex. Expects the caller to hold ConnectionsMutex.
+func (e *ConnectionService) setConnection(key string, conn *websocket.Conn) []*ConnectionWriter {
+	stale := e.takeWriters(key)
 	e.Connections[key] = conn
+	e.Writers[key] = NewConnectionWriter(key, conn, e.WriterConfig)
+	return stale
+}
+
+// takeWriters removes the current and the evicted writer of a connection,
+// expects the caller to hold ConnectionsMutex
+func (e *ConnectionService) takeWriters(key string) []*ConnectionWriter {
+	var writers []*ConnectionWriter
+	for _, byKey := range []map[string]*ConnectionWriter{e.Writers, e.evicted} {
+		if writer, ok := byKey[key]; ok {
+			writers = append(writers, writer)
+			delete(byKey, key)
+		}
+	}
+	return writers
+}
+
+// closeWriters closes writers and waits for them to stop
+func closeWriters(writers []*ConnectionWriter) {
+	for _, writer := range writers {
+		writer.Close()
+	}
+}
+
+// writer returns the writer of a live consumer, a writer that evicted its
+// consumer is moved out of Writers. Expects the caller to hold ConnectionsMutex.
+func (e *ConnectionService) writer(key string) (*ConnectionWriter, bool) {
+	writer, ok := e.Writers[key]
+	if !ok {
+		return nil, false
+	}
+	if writer.Closed() {
+		delete(e.Writers, key)
+		if e.evicted == nil {
+			e.evicted = make(map[string]*ConnectionWriter)
+		}
+		e.evicted[key] = writer
+		return nil, false
+	}
+	return writer, true
 }
 
 func (e *ConnectionService) GetConnections() map[string]*websocket.Conn {
@@ -70,13 +143,148 @@ func (e *ConnectionService) GetConnections() map[string]*websocket.Conn {
 	return e.Connections
 }
 
+// send message to a single connection
+func (e *ConnectionService) SendTo(key string, message string) bool {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	writer, ok := e.writer(key)
+	if !ok {
+		return false
+	}
+	writer.SendEvent([]byte(message))
+	// the message may have been the one that evicted the consumer
+	_, ok = e.writer(key)
+	return ok
+}
+
 // send message to all connections
 func (e *ConnectionService) SendToAll(message string) {
 	e.ConnectionsMutex.Lock()
 	defer e.ConnectionsMutex.Unlock()
-	for _, conn := range e.Connections {
-		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
-			log.Println("write:", err)
+	for key := range e.Writers {
+		if writer, ok := e.writer(key); ok {
+			writer.SendEvent([]byte(message))
+		}
+	}
+}
+
+// ConnectionStats sums up the outbound queues of all connections, it names no
+// connection since their keys contain the clients' addresses
+type ConnectionStats struct {
+	Connections int   `json:"connections"`
+	Behind      int   `json:"behind"`
+	Depth       int   `json:"depth"`
+	MaxDepth    int   `json:"maxDepth"`
+	Dropped     int64 `json:"dropped"`
+	Written     int64 `json:"written"`
+}
+
+// QueueStats returns the outbound queue statistics of all connections together
+func (e *ConnectionService) QueueStats() ConnectionStats {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	stats := ConnectionStats{Connections: len(e.Writers)}
+	for _, writer := range e.Writers {
+		queue := writer.Stats()
+		stats.Depth += queue.Depth
+		if queue.Depth > stats.MaxDepth {
+			stats.MaxDepth = queue.Depth
+		}
+		if queue.Behind {
+			stats.Behind++
+		}
+		stats.Dropped += queue.Dropped
+		stats.Written += queue.Written
+	}
+	return stats
+}
+
+// JoinRoom subscribes a connection to the updates of a room (game)
+func (e *ConnectionService) JoinRoom(room string, key string) {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	members, ok := e.Rooms[room]
+	if !ok {
+		members = make(map[string]struct{})
+		e.Rooms[room] = members
+	}
+	members[key] = struct{}{}
+}
+
+// LeaveRoom unsubscribes a connection from a room, removing the room once empty
+func (e *ConnectionService) LeaveRoom(room string, key string) {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	e.leaveRoom(room, key)
+}
+
+// LeaveAllRooms unsubscribes a connection from every room
+func (e *ConnectionService) LeaveAllRooms(key string) {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	e.leaveAllRooms(key)
+}
+
+// RemoveRoom drops a room and all of its subscriptions, its spectators are
+// dropped once they received the messages held back for them
+func (e *ConnectionService) RemoveRoom(room string) {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	delete(e.Rooms, room)
+	e.closeSpectators(room)
+}
+
+// GetRoomConnections returns the connection keys subscribed to a room
+func (e *ConnectionService) GetRoomConnections(room string) []string {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	keys := make([]string, 0, len(e.Rooms[room]))
+	for key := range e.Rooms[room] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// send message to all connections in a room
+func (e *ConnectionService) SendToRoom(room string, message string) {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	for key := range e.Rooms[room] {
+		if writer, ok := e.writer(key); ok {
+			writer.SendEvent([]byte(message))
+		}
+	}
+	e.sendToSpectators(room, []byte(message), false)
+}
+
+// send a state snapshot to all connections in a room, snapshots may be
+// coalesced or dropped for connections that are behind
+func (e *ConnectionService) SendSnapshotToRoom(room string, message string) {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	for key := range e.Rooms[room] {
+		if writer, ok := e.writer(key); ok {
+			writer.SendSnapshot(room, []byte(message))
 		}
 	}
+	e.sendToSpectators(room, []byte(message), true)
+}
+
+// leaveRoom expects the caller to hold ConnectionsMutex
+func (e *ConnectionService) leaveRoom(room string, key string) {
+	members, ok := e.Rooms[room]
+	if !ok {
+		return
+	}
+	delete(members, key)
+	if len(members) == 0 {
+		delete(e.Rooms, room)
+	}
+}
+
+// leaveAllRooms expects the caller to hold ConnectionsMutex
+func (e *ConnectionService) leaveAllRooms(key string) {
+	for room := range e.Rooms {
+		e.leaveRoom(room, key)
+	}
 }
@@ -2,6 +2,7 @@ package connection
 
 import (
 	"testing"
+	"time"
 
 	"github.com/gofiber/websocket/v2"
 )
@@ -36,3 +37,131 @@ func TestUpdateConnection(t *testing.T) {
 		t.Errorf("UpdateConnection failed, expected %v, got %v", conn2, conn)
 	}
 }
+
+func TestJoinRoom(t *testing.T) {
+	service := ProvideConnectionService()
+	service.AddConnection("test", &websocket.Conn{})
+	service.JoinRoom("room", "test")
+	if keys := service.GetRoomConnections("room"); len(keys) != 1 || keys[0] != "test" {
+		t.Errorf("JoinRoom failed, expected %v, got %v", []string{"test"}, keys)
+	}
+	if keys := service.GetRoomConnections("other"); len(keys) != 0 {
+		t.Errorf("JoinRoom failed, expected %v, got %v", 0, len(keys))
+	}
+}
+
+func TestLeaveRoomOnRemoveConnection(t *testing.T) {
+	service := ProvideConnectionService()
+	service.AddConnection("test", &websocket.Conn{})
+	service.JoinRoom("room", "test")
+	service.RemoveConnection("test")
+	if keys := service.GetRoomConnections("room"); len(keys) != 0 {
+		t.Errorf("RemoveConnection failed, expected %v, got %v", 0, len(keys))
+	}
+}
+
+func TestSpectatorsReceiveRoomMessages(t *testing.T) {
+	service := &ConnectionService{
+		Writers: make(map[string]*ConnectionWriter),
+		Rooms:   make(map[string]map[string]struct{}),
+	}
+	player, spectator := &fakeConn{}, &fakeConn{}
+	service.Writers["player"] = NewConnectionWriter("player", player, DefaultWriterConfig())
+	service.Writers["spectator"] = NewConnectionWriter("spectator", spectator, DefaultWriterConfig())
+	service.JoinRoom("watched", "player")
+
+	service.AddSpectator("watched", "spectator")
+	if count := service.SpectatorCount("watched"); count != 1 {
+		t.Errorf("AddSpectator failed, expected %v, got %v", 1, count)
+	}
+	waitFor(t, func() bool {
+		written, _ := player.snapshot()
+		return len(written) == 1 && written[0] == `{"command":"spectators","count":1,"gameKey":"watched"}`
+	})
+
+	service.SendToRoom("watched", "event")
+	waitFor(t, func() bool {
+		written, _ := spectator.snapshot()
+		return len(written) == 2 && written[1] == "event"
+	})
+
+	service.RemoveSpectator("spectator")
+	if count := service.SpectatorCount("watched"); count != 0 {
+		t.Errorf("RemoveSpectator failed, expected %v, got %v", 0, count)
+	}
+}
+
+func TestSpectatorDelay(t *testing.T) {
+	service := &ConnectionService{
+		Writers: make(map[string]*ConnectionWriter),
+		Rooms:   make(map[string]map[string]struct{}),
+	}
+	spectator := &fakeConn{}
+	service.Writers["spectator"] = NewConnectionWriter("spectator", spectator, DefaultWriterConfig())
+	service.SetSpectatorDelay("delayed", 50*time.Millisecond)
+	service.AddSpectator("delayed", "spectator")
+
+	sent := time.Now()
+	service.SendSnapshotToRoom("delayed", "snapshot")
+	waitFor(t, func() bool {
+		written, _ := spectator.snapshot()
+		return len(written) == 2
+	})
+	if elapsed := time.Since(sent); elapsed < 50*time.Millisecond {
+		t.Errorf("SendSnapshotToRoom failed, expected %v, got %v", "a delay of 50ms", elapsed)
+	}
+}
+
+func TestSpectatorDelayKeepsBacklogAndCloses(t *testing.T) {
+	service := &ConnectionService{
+		Writers: make(map[string]*ConnectionWriter),
+		Rooms:   make(map[string]map[string]struct{}),
+	}
+	spectator := &fakeConn{}
+	config := DefaultWriterConfig()
+	config.QueueSize = 4096
+	service.Writers["spectator"] = NewConnectionWriter("spectator", spectator, config)
+	service.SetSpectatorDelay("delayed", 20*time.Millisecond)
+	service.AddSpectator("delayed", "spectator")
+
+	for i := 0; i < 2000; i++ {
+		service.SendToRoom("delayed", "event")
+	}
+	service.SendToRoom("delayed", "gameOver")
+	service.RemoveRoom("delayed")
+	waitFor(t, func() bool {
+		written, _ := spectator.snapshot()
+		return len(written) == 2002 && written[2001] == "gameOver"
+	})
+	waitFor(t, func() bool { return service.SpectatorCount("delayed") == 0 })
+	service.ConnectionsMutex.Lock()
+	_, ok := service.Spectators["delayed"]
+	service.ConnectionsMutex.Unlock()
+	if ok {
+		t.Errorf("RemoveRoom failed, expected %v, got %v", "the spectator room to be removed", ok)
+	}
+}
+
+func TestSendToSkipsEvictedConsumer(t *testing.T) {
+	service := &ConnectionService{
+		Writers: make(map[string]*ConnectionWriter),
+		Rooms:   make(map[string]map[string]struct{}),
+	}
+	conn := &fakeConn{block: make(chan struct{})}
+	config := DefaultWriterConfig()
+	config.QueueSize = 1
+	config.EvictAfter = 0
+	service.Writers["slow"] = NewConnectionWriter("slow", conn, config)
+
+	service.SendTo("slow", "blocked")
+	waitFor(t, func() bool { return service.Writers["slow"].Stats().Depth == 0 })
+	service.SendTo("slow", "queued")
+	if service.SendTo("slow", "evicted") || service.SendTo("slow", "gone") {
+		t.Errorf("SendTo failed, expected %v, got %v", false, true)
+	}
+	if stats := service.QueueStats(); stats.Connections != 0 {
+		t.Errorf("SendTo failed, expected %v, got %v", 0, stats.Connections)
+	}
+	close(conn.block)
+	service.RemoveConnection("slow")
+}
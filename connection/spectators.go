@@ -0,0 +1,224 @@
+package connection
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// spectatorRoom holds the spectators of a room and the messages held back for them
+type spectatorRoom struct {
+	members map[string]struct{}
+	// delay holds every message of the room back from its spectators
+	delay time.Duration
+	// delayed are the messages waiting for their due time, oldest first. Nothing
+	// is dropped, the game config caps the delay and with it the backlog.
+	delayed []delayedMessage
+	// wake tells the goroutine delivering a delayed room about new messages,
+	// it is nil for rooms without delay
+	wake chan struct{}
+	// closing rooms are removed once their delayed messages went out
+	closing bool
+}
+
+// delayedMessage is a room message waiting for its spectators
+type delayedMessage struct {
+	due      time.Time
+	data     []byte
+	snapshot bool
+}
+
+// SetSpectatorDelay holds every message of a room back from its spectators for delay
+func (e *ConnectionService) SetSpectatorDelay(room string, delay time.Duration) {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	spectators := e.spectatorRoom(room)
+	spectators.delay = delay
+	if delay > 0 && spectators.wake == nil {
+		spectators.wake = make(chan struct{}, 1)
+		go e.deliverDelayed(room, spectators)
+	}
+}
+
+// AddSpectator lets a connection watch a room, a connection watches one room at a time
+func (e *ConnectionService) AddSpectator(room string, key string) {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	e.removeSpectator(key)
+	e.spectatorRoom(room).members[key] = struct{}{}
+	e.notifySpectatorCount(room)
+}
+
+// RemoveSpectator stops a connection from watching any room
+func (e *ConnectionService) RemoveSpectator(key string) {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	e.removeSpectator(key)
+}
+
+// SpectatorCount returns how many connections watch a room
+func (e *ConnectionService) SpectatorCount(room string) int {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	if spectators, ok := e.Spectators[room]; ok {
+		return len(spectators.members)
+	}
+	return 0
+}
+
+// SpectatorDelay returns how long the spectators of a room wait for its messages
+func (e *ConnectionService) SpectatorDelay(room string) time.Duration {
+	e.ConnectionsMutex.Lock()
+	defer e.ConnectionsMutex.Unlock()
+	if spectators, ok := e.Spectators[room]; ok {
+		return spectators.delay
+	}
+	return 0
+}
+
+// spectatorRoom returns the spectators of a room, creating them if needed. A
+// room that is closing belongs to a game that ended, a new game under the same
+// key starts a fresh room. Expects the caller to hold ConnectionsMutex.
+func (e *ConnectionService) spectatorRoom(room string) *spectatorRoom {
+	if e.Spectators == nil {
+		e.Spectators = make(map[string]*spectatorRoom)
+	}
+	spectators, ok := e.Spectators[room]
+	if !ok || spectators.closing {
+		spectators = &spectatorRoom{members: make(map[string]struct{})}
+		e.Spectators[room] = spectators
+	}
+	return spectators
+}
+
+// removeSpectator expects the caller to hold ConnectionsMutex
+func (e *ConnectionService) removeSpectator(key string) {
+	for room, spectators := range e.Spectators {
+		if _, ok := spectators.members[key]; ok {
+			delete(spectators.members, key)
+			e.notifySpectatorCount(room)
+		}
+	}
+}
+
+// sendToSpectators passes a room message on to its spectators, after the room's
+// delay if it has one. Expects the caller to hold ConnectionsMutex.
+func (e *ConnectionService) sendToSpectators(room string, data []byte, snapshot bool) {
+	spectators, ok := e.Spectators[room]
+	if !ok {
+		return
+	}
+	if spectators.wake == nil {
+		e.writeToSpectators(room, spectators, data, snapshot)
+		return
+	}
+	spectators.delayed = append(spectators.delayed, delayedMessage{due: time.Now().Add(spectators.delay), data: data, snapshot: snapshot})
+	spectators.signal()
+}
+
+// signal wakes the goroutine delivering the room's delayed messages
+func (s *spectatorRoom) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// closeSpectators removes the spectators of a room once the messages held back
+// for them went out. Expects the caller to hold ConnectionsMutex.
+func (e *ConnectionService) closeSpectators(room string) {
+	spectators, ok := e.Spectators[room]
+	if !ok {
+		return
+	}
+	if spectators.wake == nil {
+		delete(e.Spectators, room)
+		return
+	}
+	spectators.closing = true
+	spectators.signal()
+}
+
+// writeToSpectators expects the caller to hold ConnectionsMutex
+func (e *ConnectionService) writeToSpectators(room string, spectators *spectatorRoom, data []byte, snapshot bool) {
+	for key := range spectators.members {
+		writer, ok := e.writer(key)
+		if !ok {
+			continue
+		}
+		if snapshot {
+			writer.SendSnapshot(room, data)
+		} else {
+			writer.SendEvent(data)
+		}
+	}
+}
+
+// deliverDelayed sends the messages held back for a room's spectators once they
+// are due, it returns once the room is closing and everything went out
+func (e *ConnectionService) deliverDelayed(room string, spectators *spectatorRoom) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		e.ConnectionsMutex.Lock()
+		if len(spectators.delayed) == 0 {
+			if spectators.closing {
+				if e.Spectators[room] == spectators {
+					delete(e.Spectators, room)
+				}
+				e.ConnectionsMutex.Unlock()
+				return
+			}
+			e.ConnectionsMutex.Unlock()
+			<-spectators.wake
+			continue
+		}
+
+		message := spectators.delayed[0]
+		if wait := time.Until(message.due); wait > 0 {
+			e.ConnectionsMutex.Unlock()
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-spectators.wake:
+			}
+			continue
+		}
+		spectators.delayed[0] = delayedMessage{}
+		spectators.delayed = spectators.delayed[1:]
+		e.writeToSpectators(room, spectators, message.data, message.snapshot)
+		e.ConnectionsMutex.Unlock()
+	}
+}
+
+// notifySpectatorCount tells the players and spectators of a room how many
+// spectators it has. Expects the caller to hold ConnectionsMutex.
+func (e *ConnectionService) notifySpectatorCount(room string) {
+	count := 0
+	if spectators, ok := e.Spectators[room]; ok {
+		count = len(spectators.members)
+	}
+	payloadBytes, err := json.Marshal(map[string]interface{}{
+		"command": "spectators",
+		"gameKey": room,
+		"count":   count,
+	})
+	if err != nil {
+		log.Printf("Error marshalling spectators payload: %v\n", err)
+		return
+	}
+	for key := range e.Rooms[room] {
+		if writer, ok := e.writer(key); ok {
+			writer.SendEvent(payloadBytes)
+		}
+	}
+	if spectators, ok := e.Spectators[room]; ok {
+		e.writeToSpectators(room, spectators, payloadBytes, false)
+	}
+}
@@ -0,0 +1,256 @@
+package connection
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// SnapshotPolicy decides what happens to a state snapshot when a connection's queue is full
+type SnapshotPolicy int
+
+const (
+	// SnapshotCoalesce replaces any queued snapshot of the same room with the newest one
+	SnapshotCoalesce SnapshotPolicy = iota
+	// SnapshotDropOldest drops the oldest queued snapshot to make room for the newest one
+	SnapshotDropOldest
+	// SnapshotDropNewest drops the incoming snapshot
+	SnapshotDropNewest
+)
+
+// WriterConfig configures the outbound queue of every connection
+type WriterConfig struct {
+	// QueueSize is the maximum number of messages waiting to be written
+	QueueSize int
+	// WriteTimeout is the write deadline applied to every websocket write
+	WriteTimeout time.Duration
+	// EvictAfter is how long a connection may stay behind (full queue) before it is disconnected
+	EvictAfter time.Duration
+	// SnapshotPolicy applies to snapshots queued while the queue is full
+	SnapshotPolicy SnapshotPolicy
+}
+
+// DefaultWriterConfig returns the config used for new connections
+func DefaultWriterConfig() WriterConfig {
+	return WriterConfig{
+		QueueSize:      64,
+		WriteTimeout:   5 * time.Second,
+		EvictAfter:     3 * time.Second,
+		SnapshotPolicy: SnapshotCoalesce,
+	}
+}
+
+// QueueStats describes the state of a connection's outbound queue
+type QueueStats struct {
+	Depth   int   `json:"depth"`
+	Dropped int64 `json:"dropped"`
+	Written int64 `json:"written"`
+	Behind  bool  `json:"behind"`
+}
+
+// messageWriter is the subset of *websocket.Conn used by ConnectionWriter
+type messageWriter interface {
+	WriteMessage(messageType int, data []byte) error
+	SetWriteDeadline(t time.Time) error
+	Close() error
+}
+
+type outboundMessage struct {
+	room     string
+	data     []byte
+	snapshot bool
+}
+
+// ConnectionWriter owns all writes to a single websocket connection. Messages are
+// queued without blocking the caller and written by a dedicated goroutine.
+type ConnectionWriter struct {
+	key         string
+	conn        messageWriter
+	config      WriterConfig
+	mu          sync.Mutex
+	queue       []outboundMessage
+	behindSince time.Time
+	dropped     int64
+	written     int64
+	closed      bool
+	signal      chan struct{}
+	done        chan struct{}
+	// stopped is closed once run has returned and no longer touches conn
+	stopped chan struct{}
+}
+
+// NewConnectionWriter creates a writer for conn and starts its goroutine
+func NewConnectionWriter(key string, conn messageWriter, config WriterConfig) *ConnectionWriter {
+	if config.QueueSize <= 0 {
+		config.QueueSize = DefaultWriterConfig().QueueSize
+	}
+	w := &ConnectionWriter{
+		key:     key,
+		conn:    conn,
+		config:  config,
+		signal:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// SendEvent queues a message that must be delivered in order. Events are never
+// dropped, a consumer that stays behind for longer than EvictAfter is evicted.
+func (w *ConnectionWriter) SendEvent(data []byte) {
+	w.enqueue(outboundMessage{data: data})
+}
+
+// SendSnapshot queues a state snapshot for a room, applying the snapshot policy
+// when the queue is full
+func (w *ConnectionWriter) SendSnapshot(room string, data []byte) {
+	w.enqueue(outboundMessage{room: room, data: data, snapshot: true})
+}
+
+func (w *ConnectionWriter) enqueue(message outboundMessage) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+
+	if message.snapshot && w.config.SnapshotPolicy == SnapshotCoalesce {
+		// the newest snapshot goes to the tail so it never overtakes events queued after the old one
+		for i := range w.queue {
+			if w.queue[i].snapshot && w.queue[i].room == message.room {
+				w.queue = append(w.queue[:i], w.queue[i+1:]...)
+				w.dropped++
+				break
+			}
+		}
+	}
+
+	if len(w.queue) >= w.config.QueueSize {
+		if w.behindSince.IsZero() {
+			w.behindSince = time.Now()
+		}
+		if time.Since(w.behindSince) > w.config.EvictAfter {
+			log.Printf("🐌 Evicting slow consumer %v (queue depth %v)\n", w.key, len(w.queue))
+			w.closeLocked(true)
+			return
+		}
+		// events outgrow the queue for the grace period, snapshots follow the policy
+		if message.snapshot && !w.makeRoom() {
+			return
+		}
+	}
+
+	w.queue = append(w.queue, message)
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// makeRoom frees a queue slot for a snapshot if the snapshot policy allows it,
+// expects the caller to hold mu
+func (w *ConnectionWriter) makeRoom() bool {
+	w.dropped++
+	if w.config.SnapshotPolicy == SnapshotDropNewest {
+		return false
+	}
+	for i := range w.queue {
+		if w.queue[i].snapshot {
+			w.queue = append(w.queue[:i], w.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns the current queue statistics
+func (w *ConnectionWriter) Stats() QueueStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return QueueStats{
+		Depth:   len(w.queue),
+		Dropped: w.dropped,
+		Written: w.written,
+		Behind:  !w.behindSince.IsZero(),
+	}
+}
+
+// Close stops the writer and waits until it no longer touches the connection,
+// the connection itself is owned by its handler
+func (w *ConnectionWriter) Close() {
+	w.mu.Lock()
+	w.closeLocked(false)
+	w.mu.Unlock()
+	<-w.stopped
+}
+
+// Closed reports whether the writer was closed or evicted its consumer
+func (w *ConnectionWriter) Closed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+// closeLocked stops the writer, an evicted connection is also closed so that a
+// stalled write and its reader unblock and the handler cleans up. Expects the
+// caller to hold mu.
+func (w *ConnectionWriter) closeLocked(evict bool) {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.queue = nil
+	close(w.done)
+	if evict {
+		w.conn.Close()
+	}
+}
+
+func (w *ConnectionWriter) next() (outboundMessage, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || len(w.queue) == 0 {
+		// the consumer has caught up
+		w.behindSince = time.Time{}
+		return outboundMessage{}, false
+	}
+	message := w.queue[0]
+	w.queue = w.queue[1:]
+	if len(w.queue) < w.config.QueueSize {
+		// back below the limit, the next overflow starts a new grace period
+		w.behindSince = time.Time{}
+	}
+	return message, true
+}
+
+func (w *ConnectionWriter) run() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.signal:
+		}
+
+		for {
+			message, ok := w.next()
+			if !ok {
+				break
+			}
+			w.conn.SetWriteDeadline(time.Now().Add(w.config.WriteTimeout))
+			if err := w.conn.WriteMessage(websocket.TextMessage, message.data); err != nil {
+				log.Printf("write %v: %v\n", w.key, err)
+				w.mu.Lock()
+				w.closeLocked(true)
+				w.mu.Unlock()
+				continue
+			}
+			w.mu.Lock()
+			w.written++
+			w.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package connection
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeConn struct {
+	mu      sync.Mutex
+	written []string
+	block   chan struct{}
+	closed  bool
+}
+
+func (f *fakeConn) WriteMessage(messageType int, data []byte) error {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.written = append(f.written, string(data))
+	return nil
+}
+
+func (f *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (f *fakeConn) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeConn) snapshot() ([]string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.written...), f.closed
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConnectionWriterDeliversInOrder(t *testing.T) {
+	conn := &fakeConn{}
+	writer := NewConnectionWriter("test", conn, DefaultWriterConfig())
+	defer writer.Close()
+	writer.SendEvent([]byte("a"))
+	writer.SendEvent([]byte("b"))
+	waitFor(t, func() bool { written, _ := conn.snapshot(); return len(written) == 2 })
+	if written, _ := conn.snapshot(); written[0] != "a" || written[1] != "b" {
+		t.Errorf("SendEvent failed, expected %v, got %v", []string{"a", "b"}, written)
+	}
+}
+
+func TestConnectionWriterCoalescesSnapshots(t *testing.T) {
+	conn := &fakeConn{block: make(chan struct{})}
+	writer := NewConnectionWriter("test", conn, DefaultWriterConfig())
+	defer writer.Close()
+
+	// the first snapshot is picked up by the writer and blocks it
+	writer.SendSnapshot("room", []byte("1"))
+	waitFor(t, func() bool { return writer.Stats().Depth == 0 })
+	writer.SendSnapshot("room", []byte("2"))
+	writer.SendSnapshot("room", []byte("3"))
+	if depth := writer.Stats().Depth; depth != 1 {
+		t.Errorf("SendSnapshot failed, expected %v, got %v", 1, depth)
+	}
+	close(conn.block)
+	waitFor(t, func() bool { written, _ := conn.snapshot(); return len(written) == 2 })
+	if written, _ := conn.snapshot(); written[1] != "3" {
+		t.Errorf("SendSnapshot failed, expected %v, got %v", "3", written[1])
+	}
+}
+
+func TestConnectionWriterCoalescedSnapshotFollowsEvents(t *testing.T) {
+	conn := &fakeConn{block: make(chan struct{})}
+	writer := NewConnectionWriter("test", conn, DefaultWriterConfig())
+	defer writer.Close()
+
+	writer.SendEvent([]byte("blocked"))
+	waitFor(t, func() bool { return writer.Stats().Depth == 0 })
+	writer.SendSnapshot("room", []byte("old"))
+	writer.SendEvent([]byte("event"))
+	writer.SendSnapshot("room", []byte("new"))
+	close(conn.block)
+	waitFor(t, func() bool { written, _ := conn.snapshot(); return len(written) == 3 })
+	if written, _ := conn.snapshot(); written[1] != "event" || written[2] != "new" {
+		t.Errorf("SendSnapshot failed, expected %v, got %v", []string{"blocked", "event", "new"}, written)
+	}
+}
+
+func TestConnectionWriterEvictsSlowConsumer(t *testing.T) {
+	conn := &fakeConn{block: make(chan struct{})}
+	config := DefaultWriterConfig()
+	config.QueueSize = 2
+	config.EvictAfter = 0
+	writer := NewConnectionWriter("test", conn, config)
+	defer close(conn.block)
+
+	writer.SendEvent([]byte("blocked"))
+	waitFor(t, func() bool { return writer.Stats().Depth == 0 })
+	writer.SendEvent([]byte("1"))
+	writer.SendEvent([]byte("2"))
+	writer.SendEvent([]byte("overflow"))
+	writer.SendEvent([]byte("past the grace period"))
+	waitFor(t, func() bool { _, closed := conn.snapshot(); return closed })
+	if !writer.Closed() {
+		t.Errorf("SendEvent failed, expected %v, got %v", true, false)
+	}
+}
+
+func TestConnectionWriterKeepsEventsDuringGracePeriod(t *testing.T) {
+	conn := &fakeConn{block: make(chan struct{})}
+	config := DefaultWriterConfig()
+	config.QueueSize = 2
+	writer := NewConnectionWriter("test", conn, config)
+	defer writer.Close()
+
+	writer.SendEvent([]byte("blocked"))
+	waitFor(t, func() bool { return writer.Stats().Depth == 0 })
+	for _, event := range []string{"1", "2", "3"} {
+		writer.SendEvent([]byte(event))
+	}
+	if stats := writer.Stats(); stats.Depth != 3 || !stats.Behind {
+		t.Errorf("SendEvent failed, expected %v, got %v", "3 queued events", stats)
+	}
+	close(conn.block)
+	waitFor(t, func() bool { written, _ := conn.snapshot(); return len(written) == 4 })
+	if written, closed := conn.snapshot(); closed || written[3] != "3" {
+		t.Errorf("SendEvent failed, expected %v, got %v", "every event delivered", written)
+	}
+}
+
+func TestConnectionWriterForgivesIntermittentlySlowConsumer(t *testing.T) {
+	conn := &fakeConn{block: make(chan struct{})}
+	config := DefaultWriterConfig()
+	config.QueueSize = 2
+	config.EvictAfter = 30 * time.Millisecond
+	writer := NewConnectionWriter("test", conn, config)
+	defer writer.Close()
+	defer close(conn.block)
+
+	writer.SendEvent([]byte("blocked"))
+	waitFor(t, func() bool { return writer.Stats().Depth == 0 })
+	for _, event := range []string{"1", "2", "3"} {
+		writer.SendEvent([]byte(event))
+	}
+	// the consumer drains below the limit without ever emptying its queue
+	conn.block <- struct{}{}
+	conn.block <- struct{}{}
+	waitFor(t, func() bool { return writer.Stats().Depth == 1 })
+
+	time.Sleep(2 * config.EvictAfter)
+	writer.SendEvent([]byte("4"))
+	writer.SendEvent([]byte("5"))
+	if writer.Closed() {
+		t.Errorf("SendEvent failed, expected %v, got %v", "a new grace period", "an eviction")
+	}
+}
+
+func TestConnectionWriterCloseWaitsForWrite(t *testing.T) {
+	conn := &fakeConn{block: make(chan struct{})}
+	writer := NewConnectionWriter("test", conn, DefaultWriterConfig())
+	writer.SendEvent([]byte("blocked"))
+	waitFor(t, func() bool { return writer.Stats().Depth == 0 })
+
+	closed := make(chan struct{})
+	go func() {
+		writer.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatalf("Close failed, expected %v, got %v", "to wait for the write", "an early return")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(conn.block)
+	<-closed
+}
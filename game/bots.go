@@ -0,0 +1,43 @@
+package game
+
+// Brain chooses the inputs of a computer controlled player. It runs on the
+// game's loop at the start of every step, like a mode it may read the game
+// freely but must not call back into the GameService.
+type Brain interface {
+	// Decide returns the bot's input for the coming step, nil to keep going
+	Decide(g *Game, player *Player) *Input
+}
+
+// IsBot reports whether the player is computer controlled
+func (p *Player) IsBot() bool {
+	return p.Bot != ""
+}
+
+// humans counts the players of the game that are not bots
+func (g *Game) humans() int {
+	count := 0
+	for _, player := range g.Players {
+		if !player.IsBot() {
+			count++
+		}
+	}
+	return count
+}
+
+// botInputs asks the brains of the bots still alive for their inputs, in player order
+func (g *Game) botInputs() []playerInput {
+	if !g.IsRunning() {
+		return nil
+	}
+	var inputs []playerInput
+	for _, id := range g.PlayerIDs() {
+		player := g.Players[id]
+		if player.Brain == nil || !g.IsAlive(id) {
+			continue
+		}
+		if input := player.Brain.Decide(g, player); input != nil {
+			inputs = append(inputs, playerInput{player: id, input: *input})
+		}
+	}
+	return inputs
+}
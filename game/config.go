@@ -0,0 +1,226 @@
+package game
+
+import (
+	"drbh/partita/collision"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// GameConfig holds the arena, movement and spawn rules of a single game
+type GameConfig struct {
+	// ArenaSize is the half width of the square arena, players turn around at its edges
+	ArenaSize float64 `json:"arenaSize"`
+	// SpawnSize is the half width of the square players spawn in
+	SpawnSize float64 `json:"spawnSize"`
+	// Speed is the distance a player moves per unit of Delta
+	Speed float64 `json:"speed"`
+	// Delta is the simulated time that passes every tick
+	Delta float64 `json:"delta"`
+	// TickMillis is the fixed length of a simulation step
+	TickMillis int `json:"tickMillis"`
+	// SnapshotMillis is how often the game is sent to its players, 0 sends every tick
+	SnapshotMillis int `json:"snapshotMillis"`
+	// SpectatorDelayMillis holds the game back from its spectators so they cannot ghost for a player
+	SpectatorDelayMillis int `json:"spectatorDelayMillis,omitempty"`
+	// CollisionEpsilon is how close trails have to come to touch, 0 uses collision.DefaultEpsilon
+	CollisionEpsilon float64 `json:"collisionEpsilon,omitempty"`
+	// PlayerRadius is half the width of a player's head and trail, 0 uses DefaultPlayerRadius
+	PlayerRadius float64 `json:"playerRadius,omitempty"`
+}
+
+// DefaultPlayerRadius is half the width the client draws trails with
+const DefaultPlayerRadius = 0.025
+
+// DefaultGameConfig is the arena every game used before configs existed, simulated
+// at about 66Hz and sent at about 22Hz
+func DefaultGameConfig() GameConfig {
+	return GameConfig{
+		ArenaSize:      8,
+		SpawnSize:      8,
+		Speed:          0.5,
+		Delta:          0.125,
+		TickMillis:     15,
+		SnapshotMillis: 45,
+	}
+}
+
+// RankedPreset is the preset matchmaking creates games from
+const RankedPreset = "ranked"
+
+// RankedSpectatorDelay keeps spectators of ranked games behind the players
+const RankedSpectatorDelay = 3 * time.Second
+
+// MaxSpectatorDelay caps SpectatorDelayMillis, every message of the delay is
+// held in memory until the spectators get it
+const MaxSpectatorDelay = time.Minute
+
+// rankedGameConfig is the default arena with delayed spectators
+func rankedGameConfig() GameConfig {
+	config := DefaultGameConfig()
+	config.SpectatorDelayMillis = int(RankedSpectatorDelay / time.Millisecond)
+	return config
+}
+
+// DefaultPresets are the built in configs, PARTITA_GAME_PRESETS may add to or override them
+func DefaultPresets() map[string]GameConfig {
+	return map[string]GameConfig{
+		"default": DefaultGameConfig(),
+		// ranked is used by matchmaking
+		"ranked": rankedGameConfig(),
+		"small": {
+			ArenaSize:      5,
+			SpawnSize:      4,
+			Speed:          0.7,
+			Delta:          0.125,
+			TickMillis:     10,
+			SnapshotMillis: 40,
+		},
+		"large": {
+			ArenaSize:      16,
+			SpawnSize:      14,
+			Speed:          0.4,
+			Delta:          0.125,
+			TickMillis:     20,
+			SnapshotMillis: 60,
+		},
+	}
+}
+
+// Limits of a GameConfig, clients may send configs so every value is bounded
+// to keep a game from stalling its loop
+const (
+	// MaxArenaSize caps ArenaSize and with it SpawnSize
+	MaxArenaSize = 64
+	// MinTickMillis is the shortest simulation step
+	MinTickMillis = 5
+	// maxRadiusFraction caps PlayerRadius and CollisionEpsilon as a fraction of ArenaSize
+	maxRadiusFraction = 0.05
+	// maxStepFraction caps the distance a player moves per tick as a fraction of ArenaSize
+	maxStepFraction = 0.1
+)
+
+// Validate checks that the config describes a playable arena
+func (c GameConfig) Validate() error {
+	for _, value := range []float64{c.ArenaSize, c.SpawnSize, c.Speed, c.Delta, c.CollisionEpsilon, c.PlayerRadius} {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return errors.New("config values must be finite numbers")
+		}
+	}
+	if c.ArenaSize <= 0 || c.ArenaSize > MaxArenaSize {
+		return fmt.Errorf("arenaSize must be positive and at most %v", MaxArenaSize)
+	}
+	if c.SpawnSize <= 0 || c.SpawnSize > c.ArenaSize {
+		return errors.New("spawnSize must be positive and at most arenaSize")
+	}
+	if c.Speed <= 0 || c.Delta <= 0 {
+		return errors.New("speed and delta must be positive")
+	}
+	if c.Step() > c.ArenaSize*maxStepFraction {
+		return fmt.Errorf("speed times delta must be at most %v of arenaSize", maxStepFraction)
+	}
+	if c.TickMillis < MinTickMillis {
+		return fmt.Errorf("tickMillis must be at least %v", MinTickMillis)
+	}
+	if c.SpectatorDelayMillis < 0 {
+		return errors.New("spectatorDelayMillis must not be negative")
+	}
+	if c.SpectatorDelay() > MaxSpectatorDelay {
+		return fmt.Errorf("spectatorDelayMillis must not exceed %v", MaxSpectatorDelay.Milliseconds())
+	}
+	if c.CollisionEpsilon < 0 || c.PlayerRadius < 0 {
+		return errors.New("collisionEpsilon and playerRadius must not be negative")
+	}
+	if c.CollisionEpsilon > c.ArenaSize*maxRadiusFraction || c.PlayerRadius > c.ArenaSize*maxRadiusFraction {
+		return fmt.Errorf("collisionEpsilon and playerRadius must be at most %v of arenaSize", maxRadiusFraction)
+	}
+	if c.SnapshotMillis < 0 || (c.SnapshotMillis > 0 && c.SnapshotMillis < c.TickMillis) {
+		return errors.New("snapshotMillis must be 0 or at least tickMillis")
+	}
+	return nil
+}
+
+// TickInterval returns how often the game is simulated, games created without
+// a config use the default tick
+func (c GameConfig) TickInterval() time.Duration {
+	if c.TickMillis <= 0 {
+		return time.Duration(DefaultGameConfig().TickMillis) * time.Millisecond
+	}
+	return time.Duration(c.TickMillis) * time.Millisecond
+}
+
+// SnapshotInterval returns how often the game is sent to its players, 0 sends
+// a snapshot after every tick
+func (c GameConfig) SnapshotInterval() time.Duration {
+	if c.SnapshotMillis <= 0 {
+		return 0
+	}
+	return time.Duration(c.SnapshotMillis) * time.Millisecond
+}
+
+// TouchEpsilon returns how close trails have to come to touch
+func (c GameConfig) TouchEpsilon() float64 {
+	if c.CollisionEpsilon <= 0 {
+		return collision.DefaultEpsilon
+	}
+	return c.CollisionEpsilon
+}
+
+// Radius returns half the width of a player's head and trail
+func (c GameConfig) Radius() float64 {
+	if c.PlayerRadius <= 0 {
+		return DefaultPlayerRadius
+	}
+	return c.PlayerRadius
+}
+
+// SpectatorDelay returns how long spectators wait for the game's messages
+func (c GameConfig) SpectatorDelay() time.Duration {
+	return time.Duration(c.SpectatorDelayMillis) * time.Millisecond
+}
+
+// Step returns the distance a player moves every tick
+func (c GameConfig) Step() float64 {
+	return c.Speed * c.Delta
+}
+
+// SpawnPosition draws a random position within the spawn area from rng
+func (c GameConfig) SpawnPosition(rng *rand.Rand) (float64, float64) {
+	x := rng.Float64()*2*c.SpawnSize - c.SpawnSize
+	z := rng.Float64()*2*c.SpawnSize - c.SpawnSize
+	return x, z
+}
+
+// loadPresets reads extra presets from the JSON file named by PARTITA_GAME_PRESETS,
+// a preset with the same name as a built in one replaces it
+func loadPresets() map[string]GameConfig {
+	presets := DefaultPresets()
+	path := os.Getenv("PARTITA_GAME_PRESETS")
+	if path == "" {
+		return presets
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Error reading game presets: %v\n", err)
+		return presets
+	}
+	var custom map[string]GameConfig
+	if err := json.Unmarshal(data, &custom); err != nil {
+		log.Printf("Error parsing game presets: %v\n", err)
+		return presets
+	}
+	for name, config := range custom {
+		if err := config.Validate(); err != nil {
+			log.Printf("Skipping game preset %q: %v\n", name, err)
+			continue
+		}
+		presets[name] = config
+	}
+	return presets
+}
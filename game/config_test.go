@@ -0,0 +1,134 @@
+package game
+
+import (
+	"drbh/partita/collision"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGameConfigValidate(t *testing.T) {
+	for name, config := range DefaultPresets() {
+		if err := config.Validate(); err != nil {
+			t.Errorf("Validate failed for %v, expected %v, got %v", name, nil, err)
+		}
+	}
+	config := DefaultGameConfig()
+	config.SpawnSize = config.ArenaSize + 1
+	if err := config.Validate(); err == nil {
+		t.Errorf("Validate failed, expected %v, got %v", "an error", nil)
+	}
+	config = DefaultGameConfig()
+	config.CollisionEpsilon = -1
+	if err := config.Validate(); err == nil {
+		t.Errorf("Validate failed, expected %v, got %v", "an error", nil)
+	}
+	config = DefaultGameConfig()
+	config.SpectatorDelayMillis = int(MaxSpectatorDelay.Milliseconds()) + 1
+	if err := config.Validate(); err == nil {
+		t.Errorf("Validate failed, expected %v, got %v", "an error", nil)
+	}
+
+	for name, change := range map[string]func(*GameConfig){
+		"arenaSize":        func(c *GameConfig) { c.ArenaSize, c.SpawnSize = MaxArenaSize+1, 8 },
+		"infinite arena":   func(c *GameConfig) { c.ArenaSize = math.Inf(1) },
+		"NaN speed":        func(c *GameConfig) { c.Speed = math.NaN() },
+		"playerRadius":     func(c *GameConfig) { c.PlayerRadius = c.ArenaSize * 0.06 },
+		"collisionEpsilon": func(c *GameConfig) { c.CollisionEpsilon = 1e9 },
+		"tickMillis":       func(c *GameConfig) { c.TickMillis, c.SnapshotMillis = MinTickMillis-1, 0 },
+		"step":             func(c *GameConfig) { c.Speed = c.ArenaSize },
+	} {
+		config = DefaultGameConfig()
+		change(&config)
+		if err := config.Validate(); err == nil {
+			t.Errorf("Validate failed for %v, expected %v, got %v", name, "an error", nil)
+		}
+	}
+	config = DefaultGameConfig()
+	config.ArenaSize, config.SpawnSize, config.TickMillis = MaxArenaSize, MaxArenaSize, MinTickMillis
+	config.PlayerRadius = config.ArenaSize * 0.05
+	if err := config.Validate(); err != nil {
+		t.Errorf("Validate failed, expected %v, got %v", nil, err)
+	}
+}
+
+func TestTouchEpsilon(t *testing.T) {
+	if epsilon := (GameConfig{}).TouchEpsilon(); epsilon != collision.DefaultEpsilon {
+		t.Errorf("TouchEpsilon failed, expected %v, got %v", collision.DefaultEpsilon, epsilon)
+	}
+	if radius := (GameConfig{}).Radius(); radius != DefaultPlayerRadius {
+		t.Errorf("Radius failed, expected %v, got %v", DefaultPlayerRadius, radius)
+	}
+	game := NewGame(DefaultLifecycleConfig())
+	game.Config.CollisionEpsilon = 0.01
+	if epsilon := game.CollisionWorld().Epsilon(); epsilon != 0.01 {
+		t.Errorf("CollisionWorld failed, expected %v, got %v", 0.01, epsilon)
+	}
+}
+
+func TestTickInterval(t *testing.T) {
+	if interval := (GameConfig{TickMillis: 20}).TickInterval(); interval != 20*time.Millisecond {
+		t.Errorf("TickInterval failed, expected %v, got %v", 20*time.Millisecond, interval)
+	}
+	if interval := (GameConfig{}).TickInterval(); interval != 15*time.Millisecond {
+		t.Errorf("TickInterval failed, expected %v, got %v", 15*time.Millisecond, interval)
+	}
+}
+
+func TestFixedStep(t *testing.T) {
+	start := time.Now()
+	clock := newFixedStep(10*time.Millisecond, start)
+	if steps, skipped := clock.due(start.Add(25 * time.Millisecond)); steps != 2 || skipped != 0 {
+		t.Errorf("due failed, expected %v, got %v", []int{2, 0}, []int{steps, skipped})
+	}
+	clock.next()
+	clock.next()
+	// the 5ms left over carry over to the next step
+	if steps, _ := clock.due(start.Add(30 * time.Millisecond)); steps != 1 {
+		t.Errorf("due failed, expected %v, got %v", 1, steps)
+	}
+	clock.next()
+	if steps, skipped := clock.due(start.Add(time.Second)); steps != maxCatchUpSteps || skipped != 97-maxCatchUpSteps {
+		t.Errorf("due failed, expected %v, got %v", []int{maxCatchUpSteps, 97 - maxCatchUpSteps}, []int{steps, skipped})
+	}
+}
+
+func TestJoinGameSpawnsInArena(t *testing.T) {
+	service := manualGameService()
+	game := NewGame(DefaultLifecycleConfig())
+	game.Config, _ = service.GetPreset("small")
+	service.AddGame("small-arena", game)
+	player := service.NewPlayer("small")
+	service.JoinGame("small-arena", player)
+	service.WithGame("small-arena", func(game *Game) {
+		player := game.Players["small"]
+		if math.Abs(player.X) > game.Config.SpawnSize || math.Abs(player.Z) > game.Config.SpawnSize {
+			t.Errorf("JoinGame failed, expected %v, got %v", "a position in the spawn area", []float64{player.X, player.Z})
+		}
+		if player.Radius != game.Config.Radius() {
+			t.Errorf("JoinGame failed, expected %v, got %v", game.Config.Radius(), player.Radius)
+		}
+	})
+}
+
+func TestLoadPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "presets.json")
+	os.WriteFile(path, []byte(`{
+		"tiny": {"arenaSize": 3, "spawnSize": 2, "speed": 1, "delta": 0.25, "tickMillis": 15},
+		"broken": {"arenaSize": 0}
+	}`), 0o600)
+	t.Setenv("PARTITA_GAME_PRESETS", path)
+
+	presets := loadPresets()
+	if tiny, ok := presets["tiny"]; !ok || tiny.ArenaSize != 3 {
+		t.Errorf("loadPresets failed, expected %v, got %v", "tiny", tiny)
+	}
+	if _, ok := presets["broken"]; ok {
+		t.Errorf("loadPresets failed, expected %v, got %v", "broken to be skipped", presets["broken"])
+	}
+	if _, ok := presets["default"]; !ok {
+		t.Errorf("loadPresets failed, expected %v, got %v", "default", presets)
+	}
+}
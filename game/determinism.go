@@ -0,0 +1,26 @@
+package game
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Rand returns the game's RNG, seeded with Seed on first use. All randomness of
+// the game and its mode must come from it so a game can be replayed.
+func (g *Game) Rand() *rand.Rand {
+	if g.rng == nil {
+		g.rng = rand.New(rand.NewSource(g.Seed))
+	}
+	return g.rng
+}
+
+// PlayerIDs returns the IDs of the players in a stable order, modes iterate
+// players through it because map order differs between runs
+func (g *Game) PlayerIDs() []string {
+	ids := make([]string, 0, len(g.Players))
+	for id := range g.Players {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
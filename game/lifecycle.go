@@ -0,0 +1,236 @@
+package game
+
+import (
+	"fmt"
+	"time"
+)
+
+// Phase is a step of a game's lifecycle
+type Phase string
+
+const (
+	// PhaseWaiting waits until enough players joined
+	PhaseWaiting Phase = "waiting"
+	// PhaseReadyCheck waits until every player confirmed they are ready
+	PhaseReadyCheck Phase = "ready"
+	// PhaseCountdown gives players a moment before the game starts
+	PhaseCountdown Phase = "countdown"
+	// PhaseRunning is the only phase that is simulated
+	PhaseRunning Phase = "running"
+	// PhaseFinished keeps the final state visible before the game is closed
+	PhaseFinished Phase = "finished"
+	// PhaseClosed games are removed from the GameService
+	PhaseClosed Phase = "closed"
+)
+
+// transitions lists the phases each phase may move to
+var transitions = map[Phase][]Phase{
+	PhaseWaiting:    {PhaseReadyCheck, PhaseClosed},
+	PhaseReadyCheck: {PhaseCountdown, PhaseWaiting, PhaseClosed},
+	PhaseCountdown:  {PhaseRunning, PhaseWaiting, PhaseClosed},
+	PhaseRunning:    {PhaseFinished, PhaseClosed},
+	PhaseFinished:   {PhaseClosed},
+}
+
+// LifecycleConfig holds the rules and timeouts of a game's phases, a zero
+// duration disables the timeout of its phase except for the countdown, which is skipped
+type LifecycleConfig struct {
+	// MinPlayers needed to leave the waiting phase
+	MinPlayers int
+	// ReadyCheck requires every player to send "ready", otherwise players are ready when they join
+	ReadyCheck bool
+	// AllowLateJoin lets players join a game that is already counting down or running
+	AllowLateJoin bool
+
+	WaitingTimeout    time.Duration
+	ReadyTimeout      time.Duration
+	CountdownDuration time.Duration
+	MaxDuration       time.Duration
+	FinishedLinger    time.Duration
+}
+
+// DefaultLifecycleConfig is used for custom games started by a player
+func DefaultLifecycleConfig() LifecycleConfig {
+	return LifecycleConfig{
+		MinPlayers:        1,
+		AllowLateJoin:     true,
+		WaitingTimeout:    5 * time.Minute,
+		ReadyTimeout:      30 * time.Second,
+		CountdownDuration: 3 * time.Second,
+		FinishedLinger:    10 * time.Second,
+	}
+}
+
+// RankedLifecycleConfig is used for games created by matchmaking
+func RankedLifecycleConfig() LifecycleConfig {
+	return LifecycleConfig{
+		MinPlayers:        2,
+		WaitingTimeout:    1 * time.Minute,
+		ReadyTimeout:      15 * time.Second,
+		CountdownDuration: 3 * time.Second,
+		MaxDuration:       5 * time.Minute,
+		FinishedLinger:    10 * time.Second,
+	}
+}
+
+// PhaseEvent is reported to the phase listeners whenever a game changes phase
+type PhaseEvent struct {
+	GameKey  string `json:"gameKey"`
+	Phase    Phase  `json:"phase"`
+	Previous Phase  `json:"previous"`
+	// Deadline is when the phase times out in unix milliseconds, 0 without timeout
+	Deadline int64 `json:"deadline"`
+	// Config is the game's config, clients size the arena from it
+	Config GameConfig `json:"config"`
+	// Dropped are the players removed for not confirming the ready check in time
+	Dropped []string `json:"dropped,omitempty"`
+}
+
+// NewGame creates a game waiting for players
+func NewGame(lifecycle LifecycleConfig) *Game {
+	now := time.Now()
+	return &Game{
+		State:          PhaseWaiting,
+		Players:        make(map[string]*Player),
+		Config:         DefaultGameConfig(),
+		Lifecycle:      lifecycle,
+		PhaseStartedAt: now,
+		CreatedAt:      now,
+		Ready:          make(map[string]bool),
+		Scoring:        DefaultScoringConfig(),
+		Seed:           now.UnixNano(),
+	}
+}
+
+// CanTransition reports whether the game may move from its current phase to next
+func (g *Game) CanTransition(next Phase) bool {
+	for _, allowed := range transitions[g.State] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves the game to the next phase if the lifecycle allows it
+func (g *Game) Transition(next Phase, now time.Time) error {
+	if !g.CanTransition(next) {
+		return fmt.Errorf("invalid game transition from %q to %q", g.State, next)
+	}
+	g.State = next
+	g.PhaseStartedAt = now
+	return nil
+}
+
+// IsRunning reports whether the game should be simulated
+func (g *Game) IsRunning() bool {
+	return g.State == PhaseRunning
+}
+
+// CanJoin reports whether a player may join the game in its current phase
+func (g *Game) CanJoin() bool {
+	switch g.State {
+	case PhaseWaiting, PhaseReadyCheck:
+		return true
+	case PhaseCountdown, PhaseRunning:
+		return g.Lifecycle.AllowLateJoin
+	}
+	return false
+}
+
+// PhaseTimeout returns how long the current phase may last, 0 without timeout
+func (g *Game) PhaseTimeout() time.Duration {
+	switch g.State {
+	case PhaseWaiting:
+		return g.Lifecycle.WaitingTimeout
+	case PhaseReadyCheck:
+		return g.Lifecycle.ReadyTimeout
+	case PhaseCountdown:
+		return g.Lifecycle.CountdownDuration
+	case PhaseRunning:
+		return g.Lifecycle.MaxDuration
+	case PhaseFinished:
+		return g.Lifecycle.FinishedLinger
+	}
+	return 0
+}
+
+// phaseEvent describes the game's current phase
+func (g *Game) phaseEvent(key string, previous Phase) PhaseEvent {
+	event := PhaseEvent{GameKey: key, Phase: g.State, Previous: previous, Config: g.Config}
+	if timeout := g.PhaseTimeout(); timeout > 0 {
+		event.Deadline = g.PhaseStartedAt.Add(timeout).UnixNano() / int64(time.Millisecond)
+	}
+	return event
+}
+
+// phaseExpired reports whether the current phase ran past its timeout
+func (g *Game) phaseExpired(now time.Time) bool {
+	timeout := g.PhaseTimeout()
+	return timeout > 0 && now.Sub(g.PhaseStartedAt) >= timeout
+}
+
+// allReady reports whether every player passed the ready check
+func (g *Game) allReady() bool {
+	for name := range g.Players {
+		if !g.Ready[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// nextPhase decides where the game goes from its current phase, it returns the
+// current phase when the game stays put. When the ready check timed out it also
+// returns the players that did not confirm, the caller removes them.
+func (g *Game) nextPhase(now time.Time) (Phase, []string) {
+	enoughPlayers := len(g.Players) >= g.Lifecycle.MinPlayers && len(g.Players) > 0
+
+	switch g.State {
+	case PhaseWaiting:
+		if enoughPlayers {
+			return PhaseReadyCheck, nil
+		}
+		if g.phaseExpired(now) {
+			return PhaseClosed, nil
+		}
+	case PhaseReadyCheck:
+		if !enoughPlayers {
+			return PhaseWaiting, nil
+		}
+		if g.allReady() {
+			return PhaseCountdown, nil
+		}
+		if g.phaseExpired(now) {
+			// players that did not confirm in time are dropped from the game
+			var dropped []string
+			for _, id := range g.PlayerIDs() {
+				if !g.Ready[id] {
+					dropped = append(dropped, id)
+				}
+			}
+			staying := len(g.Players) - len(dropped)
+			if staying >= g.Lifecycle.MinPlayers && staying > 0 {
+				return PhaseCountdown, dropped
+			}
+			return PhaseWaiting, dropped
+		}
+	case PhaseCountdown:
+		if !enoughPlayers {
+			return PhaseWaiting, nil
+		}
+		// without a countdown the game starts right away
+		if g.Lifecycle.CountdownDuration == 0 || g.phaseExpired(now) {
+			return PhaseRunning, nil
+		}
+	case PhaseRunning:
+		if g.phaseExpired(now) {
+			return PhaseFinished, nil
+		}
+	case PhaseFinished:
+		if g.phaseExpired(now) {
+			return PhaseClosed, nil
+		}
+	}
+	return g.State, nil
+}
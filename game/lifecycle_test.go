@@ -0,0 +1,162 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransition(t *testing.T) {
+	game := NewGame(DefaultLifecycleConfig())
+	now := time.Now()
+	if err := game.Transition(PhaseRunning, now); err == nil {
+		t.Errorf("Transition failed, expected %v, got %v", "an error", nil)
+	}
+	if err := game.Transition(PhaseReadyCheck, now); err != nil || game.State != PhaseReadyCheck {
+		t.Errorf("Transition failed, expected %v, got %v", PhaseReadyCheck, game.State)
+	}
+	if err := game.Transition(PhaseFinished, now); err == nil {
+		t.Errorf("Transition failed, expected %v, got %v", "an error", nil)
+	}
+}
+
+func TestCanJoin(t *testing.T) {
+	ranked := NewGame(RankedLifecycleConfig())
+	ranked.State = PhaseRunning
+	if ranked.CanJoin() {
+		t.Errorf("CanJoin failed, expected %v, got %v", false, true)
+	}
+	custom := NewGame(DefaultLifecycleConfig())
+	custom.State = PhaseRunning
+	if !custom.CanJoin() {
+		t.Errorf("CanJoin failed, expected %v, got %v", true, false)
+	}
+	custom.State = PhaseFinished
+	if custom.CanJoin() {
+		t.Errorf("CanJoin failed, expected %v, got %v", false, true)
+	}
+}
+
+func TestAdvanceLifecycles(t *testing.T) {
+	service := manualGameService()
+	var phases []Phase
+	service.OnPhaseChanged(func(event PhaseEvent) {
+		if event.GameKey == "lifecycle" {
+			phases = append(phases, event.Phase)
+		}
+	})
+	results := 0
+	service.OnGameEnded(func(result GameResult) {
+		if result.GameKey == "lifecycle" {
+			results++
+		}
+	})
+
+	lifecycle := RankedLifecycleConfig()
+	lifecycle.ReadyCheck = true
+	game := NewGame(lifecycle)
+	service.AddGame("lifecycle", game)
+	first, second := service.NewPlayer("first"), service.NewPlayer("second")
+	service.JoinGame("lifecycle", first)
+
+	now := time.Now()
+	service.Tick("lifecycle", now)
+	if game.State != PhaseWaiting {
+		t.Errorf("Tick failed, expected %v, got %v", PhaseWaiting, game.State)
+	}
+
+	service.JoinGame("lifecycle", second)
+	service.Tick("lifecycle", now)
+	if game.State != PhaseReadyCheck {
+		t.Errorf("Tick failed, expected %v, got %v", PhaseReadyCheck, game.State)
+	}
+
+	service.SetReady("lifecycle", first)
+	service.SetReady("lifecycle", second)
+	service.Tick("lifecycle", now)
+	if game.State != PhaseCountdown {
+		t.Errorf("Tick failed, expected %v, got %v", PhaseCountdown, game.State)
+	}
+
+	now = now.Add(lifecycle.CountdownDuration)
+	service.Tick("lifecycle", now)
+	if !game.IsRunning() {
+		t.Errorf("Tick failed, expected %v, got %v", PhaseRunning, game.State)
+	}
+	if err := service.JoinGame("lifecycle", service.NewPlayer("late")); err == nil {
+		t.Errorf("JoinGame failed, expected %v, got %v", "an error", nil)
+	}
+
+	now = now.Add(lifecycle.MaxDuration)
+	service.Tick("lifecycle", now)
+	if game.State != PhaseFinished || results != 1 {
+		t.Errorf("Tick failed, expected %v, got %v", PhaseFinished, game.State)
+	}
+
+	now = now.Add(lifecycle.FinishedLinger)
+	service.Tick("lifecycle", now)
+	if _, ok := service.GetGame("lifecycle"); ok {
+		t.Errorf("Tick failed, expected %v, got %v", "the game to be removed", game.State)
+	}
+
+	expected := []Phase{PhaseReadyCheck, PhaseCountdown, PhaseRunning, PhaseFinished, PhaseClosed}
+	if len(phases) != len(expected) {
+		t.Fatalf("OnPhaseChanged failed, expected %v, got %v", expected, phases)
+	}
+	for i := range expected {
+		if phases[i] != expected[i] {
+			t.Errorf("OnPhaseChanged failed, expected %v, got %v", expected, phases)
+		}
+	}
+}
+
+func TestReadyTimeoutDropsUnreadyPlayers(t *testing.T) {
+	lifecycle := RankedLifecycleConfig()
+	lifecycle.ReadyCheck = true
+	game := NewGame(lifecycle)
+	game.Players["ready"] = &Player{ID: "ready", Name: "ready"}
+	game.Players["idle"] = &Player{ID: "idle", Name: "idle"}
+	game.Players["also-ready"] = &Player{ID: "also-ready", Name: "also-ready"}
+	game.Ready["ready"] = true
+	game.Ready["also-ready"] = true
+	game.State = PhaseReadyCheck
+
+	next, dropped := game.nextPhase(game.PhaseStartedAt.Add(lifecycle.ReadyTimeout))
+	if next != PhaseCountdown || len(dropped) != 1 || dropped[0] != "idle" {
+		t.Errorf("nextPhase failed, expected %v, got %v", []interface{}{PhaseCountdown, []string{"idle"}}, []interface{}{next, dropped})
+	}
+}
+
+func TestReadyTimeoutRemovesPlayersFromService(t *testing.T) {
+	service := manualGameService()
+	var dropped []string
+	service.OnPhaseChanged(func(event PhaseEvent) {
+		dropped = append(dropped, event.Dropped...)
+	})
+	lifecycle := RankedLifecycleConfig()
+	lifecycle.ReadyCheck = true
+	game := NewGame(lifecycle)
+	service.AddGame("ready-timeout", game)
+	ready, idle, late := service.NewPlayer("ready"), service.NewPlayer("idle"), service.NewPlayer("late")
+	service.JoinGame("ready-timeout", ready)
+	service.JoinGame("ready-timeout", idle)
+	service.JoinGame("ready-timeout", late)
+	service.SetReady("ready-timeout", ready)
+	service.SetReady("ready-timeout", late)
+
+	var startedAt time.Time
+	service.Tick("ready-timeout", time.Now())
+	service.WithGame("ready-timeout", func(game *Game) { startedAt = game.PhaseStartedAt })
+	service.Tick("ready-timeout", startedAt.Add(lifecycle.ReadyTimeout))
+
+	if len(dropped) != 1 || dropped[0] != "idle" {
+		t.Errorf("Tick failed, expected %v, got %v", []string{"idle"}, dropped)
+	}
+	if games := service.GetPlayerGames(idle); len(games) != 0 {
+		t.Errorf("GetPlayerGames failed, expected %v, got %v", 0, games)
+	}
+	service.WithGame("ready-timeout", func(game *Game) {
+		if _, ok := game.Players["idle"]; ok {
+			t.Errorf("Tick failed, expected %v, got %v", "idle to be removed", game.Players)
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package game
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// inputQueueSize is how many inputs may wait for a game's next turn before new ones are dropped
+const inputQueueSize = 64
+
+// maxCatchUpSteps is how many steps a game simulates at once after falling
+// behind, the steps beyond it are skipped
+const maxCatchUpSteps = 5
+
+// TickEvent is reported to the tick listeners after every tick of a game
+type TickEvent struct {
+	GameKey string
+	// Tick is the number of the last simulated step
+	Tick uint64
+	// Snapshot is the game serialized in the same shape as GetGameJSON
+	Snapshot string
+	// Collisions scored since the previous snapshot
+	Collisions []Collision
+}
+
+// loopAction is an action waiting for the game's loop, finished is closed once
+// the action ran and a closed game left the registry
+type loopAction struct {
+	run      func()
+	finished chan struct{}
+}
+
+// playerInput is an input waiting for the game's loop
+type playerInput struct {
+	player string
+	input  Input
+}
+
+// OnTick registers a listener that receives every game's snapshots at the game's snapshot rate.
+// Listeners run on the loop of the game and must not wait on the same game.
+func (e *GameService) OnTick(listener func(TickEvent)) {
+	e.listenersMutex.Lock()
+	defer e.listenersMutex.Unlock()
+	e.tickListeners = append(e.tickListeners, listener)
+}
+
+// startLoop starts the goroutine that owns the game, expects the caller to hold GamesMutex
+func (e *GameService) startLoop(key string, game *Game) {
+	game.actions = make(chan loopAction)
+	game.inputs = make(chan playerInput, inputQueueSize)
+	game.stop = make(chan struct{})
+	game.done = make(chan struct{})
+	go e.runLoop(key, game, e.ManualTicks)
+}
+
+// stopLoop stops the game's goroutine without waiting for it
+func (g *Game) stopLoop() {
+	if g.stop == nil {
+		return
+	}
+	g.stopOnce.Do(func() { close(g.stop) })
+}
+
+// runLoop serves the game's actions, inputs and ticks one at a time until the
+// game closes or is removed, so nothing else ever touches its state. The game
+// is simulated in fixed steps of its TickInterval and snapshots go out at its
+// SnapshotInterval.
+func (e *GameService) runLoop(key string, game *Game, manualTicks bool) {
+	defer close(game.done)
+
+	var ticks <-chan time.Time
+	if !manualTicks {
+		ticker := time.NewTicker(game.Config.TickInterval())
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+	// tick n of a game happens n steps after it was created
+	start := game.CreatedAt
+	if start.IsZero() {
+		start = time.Now()
+	}
+	clock := newFixedStep(game.Config.TickInterval(), start)
+	lastSnapshot := clock.simulated
+	var collisions []Collision
+
+	for {
+		var finished chan struct{}
+		select {
+		case action := <-game.actions:
+			action.run()
+			finished = action.finished
+		case input := <-game.inputs:
+			game.pendingInputs = append(game.pendingInputs, input)
+		case now := <-ticks:
+			steps, skipped := clock.due(now)
+			if skipped > 0 {
+				log.Printf("⏩ Game %v fell behind, skipping %v ticks\n", key, skipped)
+				// skipped ticks keep their numbers so tick n is always n steps in
+				game.recordSkip(uint64(skipped))
+				game.Tick += uint64(skipped)
+			}
+			for i := 0; i < steps && game.State != PhaseClosed; i++ {
+				collisions = append(collisions, e.step(key, game, clock.next())...)
+			}
+			if game.State != PhaseClosed && now.Sub(lastSnapshot) >= game.Config.SnapshotInterval() {
+				e.snapshot(key, game, collisions)
+				collisions = nil
+				lastSnapshot = now
+			}
+		case <-game.stop:
+			e.closeRecorder(game)
+			return
+		}
+
+		closed := game.State == PhaseClosed
+		if closed {
+			e.forget(key, game)
+			e.closeRecorder(game)
+		}
+		if finished != nil {
+			close(finished)
+		}
+		if closed {
+			return
+		}
+	}
+}
+
+// do runs action on the game's loop and waits for it, it returns false if the
+// loop already stopped. It must not be called from the game's own loop or while
+// holding GamesMutex.
+func (g *Game) do(action func()) bool {
+	finished := make(chan struct{})
+	select {
+	case g.actions <- loopAction{run: action, finished: finished}:
+	case <-g.done:
+		return false
+	}
+	<-finished
+	return true
+}
+
+// WithGame runs fn on the loop of the game stored under key, it is the only safe
+// way to read or change a game from outside its loop
+func (e *GameService) WithGame(key string, fn func(*Game)) bool {
+	game, ok := e.GetGame(key)
+	if !ok {
+		return false
+	}
+	return game.do(func() { fn(game) })
+}
+
+// Tick simulates a single step of the game stored under key at now and reports
+// its snapshot, games tick on their own unless ManualTicks is set
+func (e *GameService) Tick(key string, now time.Time) bool {
+	game, ok := e.GetGame(key)
+	if !ok {
+		return false
+	}
+	return game.do(func() {
+		collisions := e.step(key, game, now)
+		if game.State != PhaseClosed {
+			e.snapshot(key, game, collisions)
+		}
+	})
+}
+
+// step advances the game's lifecycle and steps its mode while it is running,
+// it runs on the game's loop
+func (e *GameService) step(key string, game *Game, now time.Time) []Collision {
+	game.Tick++
+	game.simulatedAt = now
+	// inputs only take effect on tick boundaries, in the order they arrived
+	game.drainInputs()
+	// bots decide on the same boundary and are recorded like everyone else
+	game.pendingInputs = append(game.pendingInputs, game.botInputs()...)
+	for _, input := range game.pendingInputs {
+		game.record(RecordedEvent{Tick: game.Tick, Kind: RecordInput, Player: input.player, Rotation: input.input.Rotation})
+		e.applyInput(game, input)
+	}
+	game.pendingInputs = nil
+	defer game.recordKeyframe(key)
+	e.advance(key, game, now)
+	if game.State == PhaseClosed || !game.IsRunning() || game.Mode == nil {
+		return nil
+	}
+
+	collisions := game.Mode.Step(game, now)
+	game.ModeState = game.Mode.Serialize(game)
+	if game.RoundOver() {
+		e.finishRound(key, game, now)
+	}
+	if game.IsRunning() && game.Mode.IsFinished(game, now) {
+		e.endGame(key, game, now)
+	}
+	return collisions
+}
+
+// snapshot reports the game to the tick listeners, it runs on the game's loop
+func (e *GameService) snapshot(key string, game *Game, collisions []Collision) {
+	e.notifyTick(TickEvent{
+		GameKey:    key,
+		Tick:       game.Tick,
+		Snapshot:   toJSON(map[string]*Game{key: game}),
+		Collisions: collisions,
+	})
+}
+
+// HandleInput queues a player's input for every game the player is in, the
+// game's mode applies it at the start of the game's next tick
+func (e *GameService) HandleInput(player *Player, input Input) error {
+	var games []*Game
+	e.GamesMutex.Lock()
+	for key := range e.playerGames[player.ID] {
+		if game, ok := e.Games[key]; ok {
+			games = append(games, game)
+		}
+	}
+	e.GamesMutex.Unlock()
+
+	if len(games) == 0 {
+		return errors.New("player is not in a game")
+	}
+	for _, game := range games {
+		select {
+		case game.inputs <- playerInput{player: player.ID, input: input}:
+		default:
+			log.Printf("Dropping input of %v, the game is falling behind\n", player.ID)
+		}
+	}
+	return nil
+}
+
+// closeRecorder ends the game's recording when its loop stops
+func (e *GameService) closeRecorder(game *Game) {
+	if game.Recorder == nil {
+		return
+	}
+	game.record(RecordedEvent{Tick: game.Tick, Kind: RecordClose})
+	if err := game.Recorder.Close(); err != nil {
+		log.Printf("Error closing recording: %v\n", err)
+	}
+	game.Recorder = nil
+}
+
+// drainInputs moves the inputs still waiting in the channel to pendingInputs, so
+// an input queued before a tick is part of that tick
+func (g *Game) drainInputs() {
+	for {
+		select {
+		case input := <-g.inputs:
+			g.pendingInputs = append(g.pendingInputs, input)
+		default:
+			return
+		}
+	}
+}
+
+// applyInput passes an input to the game's mode, it runs on the game's loop
+func (e *GameService) applyInput(game *Game, input playerInput) {
+	player, ok := game.Players[input.player]
+	if !ok {
+		return
+	}
+	// games without a mode only know about rotations
+	if game.Mode == nil {
+		if input.input.Rotation != nil {
+			player.Rotation = *input.input.Rotation
+		}
+		return
+	}
+	if err := game.Mode.HandleInput(game, player, input.input); err != nil {
+		log.Printf("Error handling input of %v: %v\n", input.player, err)
+	}
+}
+
+// notifyTick calls the tick listeners
+func (e *GameService) notifyTick(event TickEvent) {
+	e.listenersMutex.Lock()
+	listeners := make([]func(TickEvent), len(e.tickListeners))
+	copy(listeners, e.tickListeners)
+	e.listenersMutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// forget removes a closed game from the registry
+func (e *GameService) forget(key string, game *Game) {
+	e.GamesMutex.Lock()
+	defer e.GamesMutex.Unlock()
+	e.forgetLocked(key, game)
+}
+
+// forgetLocked removes the game from the registry and player index, expects the
+// caller to hold GamesMutex
+func (e *GameService) forgetLocked(key string, game *Game) {
+	if e.Games[key] != game {
+		return
+	}
+	delete(e.Games, key)
+	for name, keys := range e.playerGames {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(e.playerGames, name)
+		}
+	}
+}
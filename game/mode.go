@@ -0,0 +1,35 @@
+package game
+
+import (
+	"errors"
+	"time"
+)
+
+// GameMode holds the rules of a game, the game's loop drives one mode per game.
+// Modes run on the game's loop so they may change the game freely but must not
+// call back into the GameService.
+type GameMode interface {
+	// Name identifies the mode, e.g. in startGame
+	Name() string
+	// Init prepares the game for a new round, players have already been respawned
+	Init(g *Game, now time.Time)
+	// HandleInput applies the input of a player
+	HandleInput(g *Game, player *Player, input Input) error
+	// Step advances the game by one tick and returns the collisions it scored
+	// with Game.ScoreCollision
+	Step(g *Game, now time.Time) []Collision
+	// IsFinished reports whether the mode ended the game on its own, independent
+	// of the rounds played
+	IsFinished(g *Game, now time.Time) bool
+	// Serialize returns mode specific state that is sent with every snapshot, nil for none
+	Serialize(g *Game) interface{}
+}
+
+// Input is a player's input to a game, only the set fields apply
+type Input struct {
+	// Rotation is the new heading in radians
+	Rotation *float64
+}
+
+// ErrUnsupportedInput is returned by modes that ignore an input
+var ErrUnsupportedInput = errors.New("input not supported by this game mode")
@@ -0,0 +1,184 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MinNameLength and MaxNameLength bound display names, in characters
+	MinNameLength = 3
+	MaxNameLength = 16
+)
+
+// BlockedWords may not appear in display names, they are matched after folding
+// case and common letter substitutions
+var BlockedWords = []string{
+	"fuck", "shit", "cunt", "bitch", "asshole", "dick", "cock", "pussy",
+	"whore", "slut", "nigger", "nigga", "faggot", "retard", "nazi",
+}
+
+// lookalikes maps characters used to dodge the filter to the letters they stand for
+var lookalikes = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b",
+	"@", "a", "$", "s", "!", "i", "|", "l",
+)
+
+// RenameEvent is reported to the rename listeners when a player in a game changes its display name
+type RenameEvent struct {
+	GameKey  string `json:"gameKey"`
+	PlayerID string `json:"playerId"`
+	Name     string `json:"name"`
+	Previous string `json:"previous"`
+}
+
+// ValidateDisplayName checks the length, characters and wording of a display name
+func ValidateDisplayName(name string) error {
+	length := utf8.RuneCountInString(name)
+	if length < MinNameLength || length > MaxNameLength {
+		return fmt.Errorf("name must be %v to %v characters long", MinNameLength, MaxNameLength)
+	}
+	if strings.TrimSpace(name) != name || strings.Contains(name, "  ") {
+		return errors.New("name must not start or end with spaces or contain double spaces")
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" _-.", r) {
+			return fmt.Errorf("name must not contain %q", r)
+		}
+	}
+	if containsBlockedWord(name) {
+		return errors.New("name is not allowed")
+	}
+	return nil
+}
+
+// containsBlockedWord folds every word of a name to plain letters and compares it
+// with the blocked words. Whole words are compared so names that merely contain
+// one, like "Hancock" or "Scunthorpe", stay allowed.
+func containsBlockedWord(name string) bool {
+	for _, token := range nameTokens(name) {
+		folded := lookalikes.Replace(strings.ToLower(token))
+		folded = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) {
+				return r
+			}
+			return -1
+		}, folded)
+		for _, word := range BlockedWords {
+			if folded == word {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nameTokens splits a name into words at spaces, underscores, dashes and where
+// a lower case letter is followed by an upper case one, as in "ShitHead"
+func nameTokens(name string) []string {
+	var tokens []string
+	var current []rune
+	var previous rune
+	flush := func() {
+		if len(current) > 0 {
+			tokens = append(tokens, string(current))
+			current = nil
+		}
+	}
+	for _, r := range name {
+		switch {
+		case unicode.IsSpace(r) || r == '_' || r == '-':
+			flush()
+		case unicode.IsUpper(r) && unicode.IsLower(previous):
+			flush()
+			current = append(current, r)
+		default:
+			current = append(current, r)
+		}
+		previous = r
+	}
+	flush()
+	return tokens
+}
+
+// nameTaken reports whether another player of the game uses name, ignoring case
+func (g *Game) nameTaken(id string, name string) bool {
+	for otherID, other := range g.Players {
+		if otherID != id && strings.EqualFold(other.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnPlayerRenamed registers a listener that receives every rename in a game
+func (e *GameService) OnPlayerRenamed(listener func(RenameEvent)) {
+	e.listenersMutex.Lock()
+	defer e.listenersMutex.Unlock()
+	e.renameListeners = append(e.renameListeners, listener)
+}
+
+// RenamePlayer validates a new display name and gives it to the player and its
+// copy in every game it is in. The name must be unique within each of those games.
+func (e *GameService) RenamePlayer(player *Player, name string) error {
+	if err := ValidateDisplayName(name); err != nil {
+		return err
+	}
+
+	// each game checks and applies the name in one action so two players cannot
+	// both take it, a game that refuses it undoes the games renamed before
+	previous := player.Name
+	renamed := make(map[string]string)
+	for _, key := range e.GetPlayerGames(player) {
+		var err error
+		e.WithGame(key, func(game *Game) {
+			if game.nameTaken(player.ID, name) {
+				err = fmt.Errorf("name %q is already used in game %v", name, key)
+				return
+			}
+			if inGame, ok := game.Players[player.ID]; ok {
+				renamed[key] = inGame.Name
+				e.renameInGame(key, game, player.ID, name)
+			}
+		})
+		if err != nil {
+			for renamedKey, renamedFrom := range renamed {
+				e.WithGame(renamedKey, func(game *Game) {
+					e.renameInGame(renamedKey, game, player.ID, renamedFrom)
+				})
+			}
+			return err
+		}
+	}
+	player.Name = name
+	log.Printf("🏷️ Player %v renamed from %v to %v\n", player.ID, previous, name)
+	return nil
+}
+
+// renameInGame gives the player's copy in the game a new name, it runs on the game's loop
+func (e *GameService) renameInGame(key string, game *Game, id string, name string) {
+	inGame, ok := game.Players[id]
+	if !ok || inGame.Name == name {
+		return
+	}
+	event := RenameEvent{GameKey: key, PlayerID: id, Name: name, Previous: inGame.Name}
+	inGame.Name = name
+	game.record(RecordedEvent{Tick: game.Tick + 1, Kind: RecordRename, Player: id, Name: name})
+	e.notifyRename(event)
+}
+
+// notifyRename calls the rename listeners
+func (e *GameService) notifyRename(event RenameEvent) {
+	e.listenersMutex.Lock()
+	listeners := make([]func(RenameEvent), len(e.renameListeners))
+	copy(listeners, e.renameListeners)
+	e.listenersMutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
@@ -0,0 +1,95 @@
+package game
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestValidateDisplayName(t *testing.T) {
+	cases := map[string]bool{
+		"snake eyes":         true,
+		"Player_1":           true,
+		"éclair":             true,
+		"ab":                 false,
+		"a-very-long-name-x": false,
+		" padded":            false,
+		"two  spaces":        false,
+		"semi;colon":         false,
+		"Sh1tHead":           false,
+		"f.u.c.k":            false,
+		"dirty_sh1t":         false,
+		"Hancock":            true,
+		"Peacock":            true,
+		"Dickson":            true,
+		"Scunthorpe":         true,
+		"Ash Itzel":          true,
+	}
+	for name, valid := range cases {
+		if err := ValidateDisplayName(name); (err == nil) != valid {
+			t.Errorf("ValidateDisplayName failed for %q, expected valid %v, got %v", name, valid, err)
+		}
+	}
+}
+
+func TestRenamePlayer(t *testing.T) {
+	service := manualGameService()
+	var events []RenameEvent
+	service.OnPlayerRenamed(func(event RenameEvent) {
+		events = append(events, event)
+	})
+	service.AddGame("names", NewGame(DefaultLifecycleConfig()))
+	first, second := service.NewPlayer("first"), service.NewPlayer("second")
+	service.JoinGame("names", first)
+	service.JoinGame("names", second)
+
+	if err := service.RenamePlayer(first, "Snake"); err != nil {
+		t.Errorf("RenamePlayer failed, expected %v, got %v", nil, err)
+	}
+	if err := service.RenamePlayer(second, "snake"); err == nil {
+		t.Errorf("RenamePlayer failed, expected %v, got %v", "name taken", err)
+	}
+
+	var renamed *Player
+	service.WithGame("names", func(game *Game) {
+		renamed = game.Players["first"]
+	})
+	if renamed == nil || renamed.ID != "first" || renamed.Name != "Snake" {
+		t.Errorf("RenamePlayer failed, expected %v, got %v", "first named Snake", renamed)
+	}
+	if len(events) != 1 || events[0].PlayerID != "first" || events[0].Previous != "first" || events[0].Name != "Snake" {
+		t.Errorf("RenamePlayer failed, expected %v, got %v", "one rename event", events)
+	}
+}
+
+func TestRenamePlayerConcurrently(t *testing.T) {
+	service := manualGameService()
+	service.AddGame("names", NewGame(DefaultLifecycleConfig()))
+	var players []*Player
+	for _, id := range []string{"one", "two", "three", "four"} {
+		player := service.NewPlayer(id)
+		service.JoinGame("names", player)
+		players = append(players, player)
+	}
+
+	var wg sync.WaitGroup
+	for _, player := range players {
+		wg.Add(1)
+		go func(player *Player) {
+			defer wg.Done()
+			service.RenamePlayer(player, "Snake")
+		}(player)
+	}
+	wg.Wait()
+
+	snakes := 0
+	service.WithGame("names", func(game *Game) {
+		for _, player := range game.Players {
+			if player.Name == "Snake" {
+				snakes++
+			}
+		}
+	})
+	if snakes != 1 {
+		t.Errorf("RenamePlayer failed, expected %v, got %v", 1, snakes)
+	}
+}
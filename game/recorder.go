@@ -0,0 +1,104 @@
+package game
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// keyframeEvery is how much game time passes between the keyframes of a recording
+const keyframeEvery = 5 * time.Second
+
+// RecordKind is the kind of a recorded event
+type RecordKind string
+
+const (
+	RecordJoin  RecordKind = "join"
+	RecordLeave RecordKind = "leave"
+	RecordReady RecordKind = "ready"
+	// RecordRename is a player changing its display name
+	RecordRename RecordKind = "rename"
+	RecordInput  RecordKind = "input"
+	// RecordFinish is a game ended with EndGame
+	RecordFinish RecordKind = "finish"
+	// RecordSkip is a run of ticks the game skipped after falling behind
+	RecordSkip RecordKind = "skip"
+	// RecordKeyframe carries a snapshot to check a replay against
+	RecordKeyframe RecordKind = "keyframe"
+	// RecordClose is the last event of every recording
+	RecordClose RecordKind = "close"
+)
+
+// RecordedEvent is a single entry of a game's recording, Tick is the tick the
+// event takes effect on
+type RecordedEvent struct {
+	Tick uint64     `json:"t"`
+	Kind RecordKind `json:"k"`
+	// At is when the event happened in milliseconds since the game was created
+	At int64 `json:"a"`
+	// Player is the ID of the player, Name its display name on joins and
+	// renames and Bot its difficulty if it joined as a bot
+	Player   string          `json:"p,omitempty"`
+	Name     string          `json:"n,omitempty"`
+	Bot      string          `json:"b,omitempty"`
+	Rotation *float64        `json:"r,omitempty"`
+	Snapshot json.RawMessage `json:"s,omitempty"`
+	// Skipped is the number of ticks skipped from Tick on
+	Skipped uint64 `json:"sk,omitempty"`
+}
+
+// Recorder receives everything needed to replay a game: its joins, leaves and
+// inputs stamped with their tick, plus periodic keyframes. It is called on the
+// game's loop and closed when the loop stops.
+type Recorder interface {
+	Record(event RecordedEvent)
+	Close() error
+}
+
+// record stamps an event with the current time and passes it to the game's
+// recorder, if it has one
+func (g *Game) record(event RecordedEvent) {
+	if g.Recorder == nil {
+		return
+	}
+	event.At = g.now().Sub(g.CreatedAt).Milliseconds()
+	g.Recorder.Record(event)
+}
+
+// recordSkip records that the ticks after the current one are skipped
+func (g *Game) recordSkip(skipped uint64) {
+	if g.Recorder == nil {
+		return
+	}
+	g.Recorder.Record(RecordedEvent{
+		Tick:    g.Tick + 1,
+		Kind:    RecordSkip,
+		At:      g.now().Sub(g.CreatedAt).Milliseconds(),
+		Skipped: skipped,
+	})
+}
+
+// recordKeyframe records the game's snapshot every keyframeEvery
+func (g *Game) recordKeyframe(key string) {
+	if g.Recorder == nil {
+		return
+	}
+	every := uint64(keyframeEvery / g.Config.TickInterval())
+	if every == 0 || g.Tick%every != 0 {
+		return
+	}
+	g.Recorder.Record(RecordedEvent{
+		Tick:     g.Tick,
+		Kind:     RecordKeyframe,
+		At:       g.now().Sub(g.CreatedAt).Milliseconds(),
+		Snapshot: json.RawMessage(toJSON(map[string]*Game{key: g})),
+	})
+}
+
+// now returns the time of the game's last tick, so that everything happening
+// between ticks is replayed the same way, or the wall clock before the first tick
+func (g *Game) now() time.Time {
+	if g.simulatedAt.IsZero() {
+		return time.Now()
+	}
+	return g.simulatedAt
+}
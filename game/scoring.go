@@ -0,0 +1,276 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// ScoreMode is the win condition of a round
+type ScoreMode string
+
+const (
+	// ModeLastStanding eliminates players for the rest of the round when they are hit,
+	// the last player alive wins the round
+	ModeLastStanding ScoreMode = "lastStanding"
+	// ModeFirstToPoints respawns players that are hit, the first player to reach
+	// TargetPoints in the round wins it
+	ModeFirstToPoints ScoreMode = "firstToPoints"
+)
+
+// ScoringConfig holds the win condition and the number of rounds of a game
+type ScoringConfig struct {
+	Mode ScoreMode
+	// Rounds is the number of rounds to play, 0 plays rounds until the game times out.
+	// The game also ends early once a player won more than half of the rounds.
+	Rounds int
+	// TargetPoints a player needs within a round in ModeFirstToPoints
+	TargetPoints int
+	// KillPoints are awarded to the collider of every collision
+	KillPoints int
+	// RoundWinPoints are awarded to the winner of a round
+	RoundWinPoints int
+}
+
+// DefaultScoringConfig is used for custom games started by a player
+func DefaultScoringConfig() ScoringConfig {
+	return ScoringConfig{
+		Mode:         ModeFirstToPoints,
+		Rounds:       1,
+		TargetPoints: 10,
+		KillPoints:   1,
+	}
+}
+
+// RankedScoringConfig is used for games created by matchmaking
+func RankedScoringConfig() ScoringConfig {
+	return ScoringConfig{
+		Mode:           ModeLastStanding,
+		Rounds:         3,
+		KillPoints:     1,
+		RoundWinPoints: 3,
+	}
+}
+
+// PlayerScore is a player's tally across all rounds of a game
+type PlayerScore struct {
+	Kills     int `json:"kills"`
+	Deaths    int `json:"deaths"`
+	Points    int `json:"points"`
+	RoundsWon int `json:"roundsWon"`
+	// RoundPoints are the points scored in the current round
+	RoundPoints int `json:"roundPoints"`
+	// Survival is the time spent alive in milliseconds
+	Survival int64 `json:"survival"`
+	// TrailLength is the longest trail the player had when hit or at the end of a round
+	TrailLength float64 `json:"trailLength"`
+}
+
+// CollisionOutcome tells the caller of ScoreCollision what the collision changed
+type CollisionOutcome struct {
+	// Eliminated is true if the victim is out for the rest of the round
+	Eliminated bool
+	// RoundOver is true if the collision decided the round, a new one has started
+	// unless GameOver is true as well
+	RoundOver   bool
+	RoundWinner string
+	Round       int
+	GameOver    bool
+}
+
+// RoundEvent is reported to the round listeners whenever a round ends
+type RoundEvent struct {
+	GameKey string                 `json:"gameKey"`
+	Round   int                    `json:"round"`
+	Winner  string                 `json:"winner"`
+	Scores  map[string]PlayerScore `json:"scores"`
+	// GameOver is true if this was the last round, the game result follows
+	GameOver bool `json:"gameOver"`
+}
+
+// TrailLength returns the length of the player's trail up to its current position
+func (p *Player) TrailLength() float64 {
+	length := 0.0
+	for i := 1; i < len(p.PathPoints); i++ {
+		length += math.Hypot(p.PathPoints[i].X-p.PathPoints[i-1].X, p.PathPoints[i].Z-p.PathPoints[i-1].Z)
+	}
+	if len(p.PathPoints) > 0 {
+		last := p.PathPoints[len(p.PathPoints)-1]
+		length += math.Hypot(p.X-last.X, p.Z-last.Z)
+	}
+	return length
+}
+
+// score returns the score of a player, creating it on first use
+func (g *Game) score(name string) *PlayerScore {
+	if g.Scores == nil {
+		g.Scores = make(map[string]*PlayerScore)
+	}
+	score, ok := g.Scores[name]
+	if !ok {
+		score = &PlayerScore{}
+		g.Scores[name] = score
+	}
+	return score
+}
+
+// IsAlive reports whether a player is still in the current round
+func (g *Game) IsAlive(name string) bool {
+	if g.Alive == nil {
+		return true
+	}
+	return g.Alive[name]
+}
+
+// startRound brings every player back at a new position for the next round
+func (g *Game) startRound(now time.Time) {
+	g.Round++
+	g.RoundStartedAt = now
+	g.roundOpen = true
+	g.Alive = make(map[string]bool, len(g.Players))
+	for _, name := range g.PlayerIDs() {
+		g.Alive[name] = true
+		g.score(name).RoundPoints = 0
+		g.Players[name].respawn(g.Config, g.Rand())
+	}
+	if g.Mode != nil {
+		g.Mode.Init(g, now)
+	}
+}
+
+// respawn moves the player to a random position in the spawn area and clears its trail
+func (p *Player) respawn(config GameConfig, rng *rand.Rand) {
+	x, z := config.SpawnPosition(rng)
+	p.X, p.Y, p.Z = x, 0, z
+	p.Rotation, p.LastRotation = frontFacing, frontFacing
+	p.PathPoints = []PathPoint{{X: x, Y: 0, Z: z}}
+	p.JustSpawned = true
+}
+
+// ScoreCollision records a collision and updates the scores, in ModeLastStanding
+// the victim is eliminated for the rest of the round
+func (g *Game) ScoreCollision(collider string, victim string, now time.Time) bool {
+	g.RecordCollision(collider, victim, now.UnixNano()/int64(time.Millisecond))
+
+	colliderScore := g.score(collider)
+	colliderScore.Kills++
+	colliderScore.Points += g.Scoring.KillPoints
+	colliderScore.RoundPoints += g.Scoring.KillPoints
+
+	victimScore := g.score(victim)
+	victimScore.Deaths++
+	if player, ok := g.Players[victim]; ok {
+		victimScore.TrailLength = math.Max(victimScore.TrailLength, player.TrailLength())
+	}
+
+	if g.Scoring.Mode != ModeLastStanding || !g.roundOpen || !g.IsAlive(victim) {
+		return false
+	}
+	victimScore.Survival += now.Sub(g.RoundStartedAt).Milliseconds()
+	g.Alive[victim] = false
+	return true
+}
+
+// ScoreCollisionAt scores a collision like ScoreCollision and records where it happened
+func (g *Game) ScoreCollisionAt(collider string, victim string, at PathPoint, now time.Time) bool {
+	eliminated := g.ScoreCollision(collider, victim, now)
+	g.Collisions[len(g.Collisions)-1].At = &at
+	return eliminated
+}
+
+// RoundOver reports whether the win condition of the current round is met
+func (g *Game) RoundOver() bool {
+	if !g.roundOpen {
+		return false
+	}
+	switch g.Scoring.Mode {
+	case ModeLastStanding:
+		alive := 0
+		for name := range g.Players {
+			if g.IsAlive(name) {
+				alive++
+			}
+		}
+		return len(g.Players) > 1 && alive <= 1
+	case ModeFirstToPoints:
+		if g.Scoring.TargetPoints <= 0 {
+			return false
+		}
+		for name := range g.Players {
+			if g.score(name).RoundPoints >= g.Scoring.TargetPoints {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// closeRound credits survival time and trail length to the players still alive
+func (g *Game) closeRound(now time.Time) {
+	if !g.roundOpen {
+		return
+	}
+	g.roundOpen = false
+	for name, player := range g.Players {
+		if !g.IsAlive(name) {
+			continue
+		}
+		score := g.score(name)
+		score.Survival += now.Sub(g.RoundStartedAt).Milliseconds()
+		score.TrailLength = math.Max(score.TrailLength, player.TrailLength())
+	}
+}
+
+// endRound closes the current round and returns its winner
+func (g *Game) endRound(now time.Time) string {
+	g.closeRound(now)
+
+	candidates := make([]string, 0, len(g.Players))
+	for name := range g.Players {
+		if g.Scoring.Mode != ModeLastStanding || g.IsAlive(name) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		left, right := g.score(candidates[i]), g.score(candidates[j])
+		if left.RoundPoints == right.RoundPoints {
+			return candidates[i] < candidates[j]
+		}
+		return left.RoundPoints > right.RoundPoints
+	})
+
+	winner := candidates[0]
+	score := g.score(winner)
+	score.RoundsWon++
+	score.Points += g.Scoring.RoundWinPoints
+	return winner
+}
+
+// matchOver reports whether every round was played or a player can no longer be caught
+func (g *Game) matchOver() bool {
+	if g.Scoring.Rounds <= 0 {
+		return false
+	}
+	if g.Round >= g.Scoring.Rounds {
+		return true
+	}
+	for _, score := range g.Scores {
+		if score.RoundsWon > g.Scoring.Rounds/2 {
+			return true
+		}
+	}
+	return false
+}
+
+// finalScores copies the scores of every player that took part in the game
+func (g *Game) finalScores() map[string]PlayerScore {
+	scores := make(map[string]PlayerScore, len(g.Scores))
+	for name, score := range g.Scores {
+		scores[name] = *score
+	}
+	return scores
+}
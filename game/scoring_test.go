@@ -0,0 +1,105 @@
+package game
+
+import (
+	"testing"
+	"time"
+)
+
+// runningGame adds a running game with the given players to the service
+func runningGame(service *GameService, key string, scoring ScoringConfig, names ...string) *Game {
+	game := NewGame(RankedLifecycleConfig())
+	game.Scoring = scoring
+	service.AddGame(key, game)
+	for _, name := range names {
+		service.JoinGame(key, service.NewPlayer(name))
+	}
+	service.WithGame(key, func(game *Game) {
+		game.State = PhaseRunning
+		game.startRound(time.Now())
+	})
+	return game
+}
+
+func TestTrailLength(t *testing.T) {
+	player := &Player{
+		X: 3, Z: 4,
+		PathPoints: []PathPoint{{X: 0, Z: 0}, {X: 3, Z: 0}},
+	}
+	if length := player.TrailLength(); length != 7 {
+		t.Errorf("TrailLength failed, expected %v, got %v", 7, length)
+	}
+}
+
+func TestLastStandingRounds(t *testing.T) {
+	service := manualGameService()
+	var rounds []RoundEvent
+	service.OnRoundEnded(func(round RoundEvent) {
+		if round.GameKey == "last-standing-rounds" {
+			rounds = append(rounds, round)
+		}
+	})
+	var result *GameResult
+	service.OnGameEnded(func(gameResult GameResult) {
+		if gameResult.GameKey == "last-standing-rounds" {
+			result = &gameResult
+		}
+	})
+
+	game := runningGame(service, "last-standing-rounds", RankedScoringConfig(), "a", "b", "c")
+	now := time.Now()
+
+	outcome := service.ScoreCollision("last-standing-rounds", "a", "b", now)
+	if !outcome.Eliminated || outcome.RoundOver || game.IsAlive("b") {
+		t.Errorf("ScoreCollision failed, expected %v, got %v", "b eliminated", outcome)
+	}
+	outcome = service.ScoreCollision("last-standing-rounds", "a", "c", now)
+	if !outcome.RoundOver || outcome.RoundWinner != "a" || game.Round != 2 || !game.IsAlive("b") {
+		t.Errorf("ScoreCollision failed, expected %v, got %v", "a to win round 1", outcome)
+	}
+
+	service.ScoreCollision("last-standing-rounds", "a", "b", now)
+	outcome = service.ScoreCollision("last-standing-rounds", "a", "c", now)
+	if !outcome.GameOver || game.State != PhaseFinished {
+		t.Errorf("ScoreCollision failed, expected %v, got %v", "the game to end after a won 2 of 3 rounds", outcome)
+	}
+
+	if len(rounds) != 2 || !rounds[1].GameOver {
+		t.Errorf("OnRoundEnded failed, expected %v, got %v", 2, len(rounds))
+	}
+	if result == nil || result.Winner != "a" || result.Rounds != 2 {
+		t.Fatalf("OnGameEnded failed, expected %v, got %v", "a", result)
+	}
+	if score := result.Scores["a"]; score.Kills != 4 || score.RoundsWon != 2 || score.Points != 10 {
+		t.Errorf("OnGameEnded failed, expected %v, got %v", "4 kills, 2 rounds and 10 points", score)
+	}
+	if score := result.Scores["b"]; score.Deaths != 2 || score.Kills != 0 {
+		t.Errorf("OnGameEnded failed, expected %v, got %v", "2 deaths", score)
+	}
+}
+
+func TestFirstToPoints(t *testing.T) {
+	service := manualGameService()
+	scoring := DefaultScoringConfig()
+	scoring.TargetPoints = 2
+	game := runningGame(service, "first-to-points", scoring, "a", "b")
+	now := time.Now()
+
+	outcome := service.ScoreCollision("first-to-points", "b", "a", now)
+	if outcome.Eliminated || outcome.RoundOver || !game.IsAlive("a") {
+		t.Errorf("ScoreCollision failed, expected %v, got %v", "a to respawn", outcome)
+	}
+	outcome = service.ScoreCollision("first-to-points", "b", "a", now)
+	if !outcome.GameOver || outcome.RoundWinner != "b" {
+		t.Errorf("ScoreCollision failed, expected %v, got %v", "b to win", outcome)
+	}
+}
+
+func TestLeavingDecidesRound(t *testing.T) {
+	service := manualGameService()
+	game := runningGame(service, "leaving-round", RankedScoringConfig(), "a", "b", "c")
+	service.ScoreCollision("leaving-round", "a", "b", time.Now())
+	service.LeaveGame("leaving-round", game.Players["c"])
+	if game.Round != 2 || game.score("a").RoundsWon != 1 {
+		t.Errorf("LeaveGame failed, expected %v, got %v", "a to win round 1", game.Round)
+	}
+}
@@ -1,32 +1,156 @@
 package game
 
 import (
+	"drbh/partita/collision"
 	"fmt"
 	"log"
 	"math"
 	"math/rand"
+	"sort"
 	"sync"
+	"time"
 
 	"encoding/json"
 )
 
+// GameService keeps the registry of games. Every game runs in its own goroutine
+// that owns its state, GamesMutex only guards the registry itself and must never
+// be held while waiting on a game.
 type GameService struct {
 	Games      map[string]*Game
 	GamesMutex sync.Mutex
+	// playerGames indexes the keys of the games each player is in, guarded by GamesMutex
+	playerGames map[string]map[string]struct{}
+
+	listenersMutex  sync.Mutex
+	resultListeners []func(GameResult)
+	phaseListeners  []func(PhaseEvent)
+	renameListeners []func(RenameEvent)
+	roundListeners  []func(RoundEvent)
+	tickListeners   []func(TickEvent)
+
+	// Presets are the named configs games can be created from
+	Presets map[string]GameConfig
+	// ManualTicks stops games from ticking on their own so tests can drive them with Tick
+	ManualTicks bool
 }
 
 type Game struct {
-	State   string
+	State   Phase
 	Players map[string]*Player
+	// Config holds the arena, movement and spawn rules
+	Config GameConfig `json:"-"`
+	// Mode holds the rules the game is played by
+	Mode GameMode `json:"-"`
+	// ModeState is the mode specific state of the last tick
+	ModeState interface{} `json:",omitempty"`
+	// Lifecycle holds the rules and timeouts of the game's phases
+	Lifecycle LifecycleConfig `json:"-"`
+	// PhaseStartedAt is when the game entered its current phase
+	PhaseStartedAt time.Time `json:"-"`
+	// Ready tracks which players passed the ready check
+	Ready map[string]bool `json:"-"`
+	// Ranked games update the ratings of their players when they end
+	Ranked bool `json:"-"`
+	// Host is the player that started the game, it may add bots
+	Host string `json:"-"`
+	// Collisions records every collision of the game
+	Collisions []Collision `json:"-"`
+	// Eliminated lists players that left the game, in the order they left
+	Eliminated []string `json:"-"`
+	// Scoring holds the win condition and number of rounds
+	Scoring ScoringConfig `json:"-"`
+	// Round is the current round, starting at 1 once the game is running
+	Round int `json:",omitempty"`
+	// Scores tracks each player's kills, deaths and points across rounds
+	Scores map[string]*PlayerScore `json:",omitempty"`
+	// Alive tracks which players are still in the current round
+	Alive map[string]bool `json:"-"`
+	// RoundStartedAt is when the current round started
+	RoundStartedAt time.Time `json:"-"`
+	roundOpen      bool
+	// Seed seeds the game's RNG, the same seed and inputs replay the same game
+	Seed int64 `json:"-"`
+	rng  *rand.Rand
+	// CreatedAt is when the game was created, its ticks are counted from then
+	CreatedAt time.Time `json:"-"`
+	// Recorder records the game for replays, nil to not record it
+	Recorder Recorder `json:"-"`
+	// World holds the game's trails for collision checks, only the game's loop uses it
+	World *collision.World `json:"-"`
+	// simulatedAt is the time of the last tick
+	simulatedAt time.Time
+	// Tick counts the simulation steps of the game, every snapshot carries it
+	Tick uint64 `json:",omitempty"`
+
+	// loop state, see loop.go
+	actions chan loopAction
+	inputs  chan playerInput
+	// pendingInputs wait for the next tick
+	pendingInputs []playerInput
+	stop          chan struct{}
+	stopOnce      sync.Once
+	done          chan struct{}
+}
+
+// CollisionWorld returns the game's collision world with the epsilon of the
+// game's config, a game created without one gets a world with the default cell
+// size on first use
+func (g *Game) CollisionWorld() *collision.World {
+	if g.World == nil {
+		g.World = collision.NewWorld(collision.DefaultCellSize)
+	}
+	g.World.SetEpsilon(g.Config.TouchEpsilon())
+	return g.World
+}
+
+// SyncTrail adds the turns a player made since the last sync to the game's
+// collision world, or indexes the trail anew after a respawn reset it
+func (g *Game) SyncTrail(player *Player) {
+	g.CollisionWorld().SyncTrail(player.ID, len(player.PathPoints), func(i int) collision.Point {
+		return collision.NewPoint(player.PathPoints[i].X, player.PathPoints[i].Z)
+	})
+}
+
+// Collision records that Collider ran into the trail of Victim, who was respawned
+type Collision struct {
+	Collider string `json:"collider"`
+	Victim   string `json:"victim"`
+	Time     int64  `json:"time"`
+	// At is where the collider crossed the victim's trail, when the mode knows it
+	At *PathPoint `json:"at,omitempty"`
+}
+
+// GameResult is reported to the result listeners when a game ends
+type GameResult struct {
+	GameKey    string      `json:"gameKey"`
+	Ranked     bool        `json:"ranked"`
+	Winner     string      `json:"winner"`
+	Placements []string    `json:"placements"`
+	Collisions []Collision `json:"collisions"`
+	// Rounds is the number of rounds that were played
+	Rounds int                    `json:"rounds"`
+	Scores map[string]PlayerScore `json:"scores"`
 }
 
 type Player struct {
+	// ID identifies the player in every game, score and queue, it never changes
+	ID string
+	// Name is the validated display name, players may rename themselves
 	Name         string
 	X, Y, Z      float64
 	Rotation     float64
 	LastRotation float64
 	PathPoints   []PathPoint
 	JustSpawned  bool
+	// Bot is the difficulty of a computer controlled player, empty for humans
+	Bot string `json:",omitempty"`
+	// Brain picks the inputs of a bot, replays leave it nil and apply the
+	// recorded inputs instead
+	Brain Brain `json:"-"`
+	// Radius is half the width of the player's head and trail, collisions
+	// sweep the head along its path so it cannot slip through a trail
+	Radius float64 `json:",omitempty"`
 }
 
 type PathPoint struct {
@@ -37,8 +161,6 @@ var gameServiceInstance *GameService
 var once sync.Once
 
 const frontFacing = 2 * math.Pi
-const limit = 8.0
-const lowerLimit = -limit
 
 func ProvideGameService() *GameService {
 	log.Println("ProvideGameService")
@@ -47,147 +169,568 @@ func ProvideGameService() *GameService {
 
 func GetGameServiceInstance() *GameService {
 	once.Do(func() {
-		gameServiceInstance = &GameService{
-			Games:      make(map[string]*Game),
-			GamesMutex: sync.Mutex{},
-		}
+		gameServiceInstance = NewGameService()
 		log.Println("🎮 Successfully connected to Game Service")
 	})
 	return gameServiceInstance
 }
 
-func (e *GameService) PlayerFromConnectionID(connectionID string) *Player {
+// NewGameService creates an empty game service, mostly useful for tests
+func NewGameService() *GameService {
+	return &GameService{
+		Games:       make(map[string]*Game),
+		GamesMutex:  sync.Mutex{},
+		playerGames: make(map[string]map[string]struct{}),
+		Presets:     loadPresets(),
+	}
+}
 
-	// random player position
-	x := rand.Float64()*(limit-lowerLimit) + lowerLimit
-	z := rand.Float64()*(limit-lowerLimit) + lowerLimit
+func (e *GameService) PlayerFromConnectionID(connectionID string) *Player {
+	return e.NewPlayer(connectionID)
+}
 
+// NewPlayer creates a player with a stable ID at the center of the arena, the
+// game it joins spawns it with the game's RNG. The ID doubles as display name
+// until the player picks one.
+func (e *GameService) NewPlayer(id string) *Player {
 	return &Player{
-		Name:         connectionID,
-		X:            x,
-		Y:            0,
-		Z:            z,
+		ID:           id,
+		Name:         id,
 		LastRotation: frontFacing,
 		Rotation:     frontFacing,
-		PathPoints: []PathPoint{
-			{
-				X: x,
-				Y: 0,
-				Z: z,
-			},
-		},
+		PathPoints:   []PathPoint{{}},
 	}
 }
 
 func (e *GameService) PrintAllGames() {
-	for key, _ := range e.Games {
+	e.GamesMutex.Lock()
+	defer e.GamesMutex.Unlock()
+	for key := range e.Games {
 		fmt.Println(key)
 	}
 }
 
+// AddGame adds a game and starts its loop, a game already stored under key is stopped
 func (e *GameService) AddGame(key string, game *Game) {
 	e.GamesMutex.Lock()
 	defer e.GamesMutex.Unlock()
+	if existing, ok := e.Games[key]; ok {
+		if existing == game {
+			return
+		}
+		existing.stopLoop()
+	}
 	e.Games[key] = game
+	e.startLoop(key, game)
 }
 
-func (e *GameService) GetGame(key string) (*Game, bool) {
+// AddGameIfAbsent adds a game unless one already exists under key, it returns the game stored under key
+func (e *GameService) AddGameIfAbsent(key string, game *Game) *Game {
 	e.GamesMutex.Lock()
 	defer e.GamesMutex.Unlock()
-	game, ok := e.Games[key]
-	return game, ok
+	if existing, ok := e.Games[key]; ok {
+		return existing
+	}
+	e.Games[key] = game
+	e.startLoop(key, game)
+	return game
+}
+
+// GetPreset returns a copy of the named config
+func (e *GameService) GetPreset(name string) (GameConfig, bool) {
+	e.GamesMutex.Lock()
+	defer e.GamesMutex.Unlock()
+	config, ok := e.Presets[name]
+	return config, ok
 }
 
-// JoinGame
-func (e *GameService) JoinGame(key string, player *Player) {
+// GetGame returns the game stored under key. The game is owned by its loop,
+// use WithGame to read or change its state.
+func (e *GameService) GetGame(key string) (*Game, bool) {
 	e.GamesMutex.Lock()
 	defer e.GamesMutex.Unlock()
 	game, ok := e.Games[key]
+	return game, ok
+}
+
+// JoinGame adds a copy of the player to the game, the game owns the copy from then on
+func (e *GameService) JoinGame(key string, player *Player) error {
+	return e.JoinGameIf(key, player, nil)
+}
+
+// JoinGameIf joins like JoinGame once guard accepted the game. The guard runs on
+// the game's loop right before the join so nothing joins in between, players
+// rejoining a game skip it.
+func (e *GameService) JoinGameIf(key string, player *Player, guard func(*Game) error) error {
+	game, ok := e.GetGame(key)
 	if !ok {
 		log.Println("Game does not exist")
-		return
+		return fmt.Errorf("game %v does not exist", key)
+	}
+
+	var err error
+	running := game.do(func() {
+		if _, rejoining := game.Players[player.ID]; !rejoining {
+			if guard != nil {
+				if err = guard(game); err != nil {
+					return
+				}
+			}
+			if !game.CanJoin() {
+				err = fmt.Errorf("game %v cannot be joined while %v", key, game.State)
+				return
+			}
+			if game.nameTaken(player.ID, player.Name) {
+				err = fmt.Errorf("name %q is already used in game %v", player.Name, key)
+				return
+			}
+			joined := *player
+			joined.PathPoints = append([]PathPoint(nil), player.PathPoints...)
+			if game.Config.ArenaSize > 0 {
+				// place the player within this game's spawn area
+				joined.respawn(game.Config, game.Rand())
+			}
+			if joined.Radius <= 0 {
+				joined.Radius = game.Config.Radius()
+			}
+			game.Players[player.ID] = &joined
+			game.score(player.ID)
+			if game.Alive != nil {
+				// late joiners play the current round
+				game.Alive[player.ID] = true
+			}
+		}
+		if game.Ready == nil {
+			game.Ready = make(map[string]bool)
+		}
+		if !game.Lifecycle.ReadyCheck {
+			game.Ready[player.ID] = true
+		}
+		e.indexPlayer(key, player.ID)
+		game.record(RecordedEvent{Tick: game.Tick + 1, Kind: RecordJoin, Player: player.ID, Name: player.Name, Bot: player.Bot})
+	})
+	if !running {
+		return fmt.Errorf("game %v has ended", key)
+	}
+	return err
+}
+
+// SetReady marks a player as ready during the ready check
+func (e *GameService) SetReady(key string, player *Player) error {
+	game, ok := e.GetGame(key)
+	if !ok {
+		return fmt.Errorf("game %v does not exist", key)
+	}
+	var err error
+	running := game.do(func() {
+		if _, inGame := game.Players[player.ID]; !inGame {
+			err = fmt.Errorf("player %v is not in game %v", player.ID, key)
+			return
+		}
+		if game.Ready == nil {
+			game.Ready = make(map[string]bool)
+		}
+		game.Ready[player.ID] = true
+		game.record(RecordedEvent{Tick: game.Tick + 1, Kind: RecordReady, Player: player.ID})
+	})
+	if !running {
+		return fmt.Errorf("game %v has ended", key)
 	}
-	game.Players[player.Name] = player
+	return err
 }
 
 // LeaveGame
 func (e *GameService) LeaveGame(key string, player *Player) {
-	e.GamesMutex.Lock()
-	defer e.GamesMutex.Unlock()
-	game, ok := e.Games[key]
+	game, ok := e.GetGame(key)
 	if !ok {
 		log.Println("Game does not exist")
 		return
 	}
-	delete(game.Players, player.Name)
+	game.do(func() {
+		e.removePlayer(key, game, player.ID)
+
+		// if no human is left, close it and its loop removes it, bots never play on their own
+		if game.humans() == 0 && game.State != PhaseClosed {
+			if game.IsRunning() {
+				e.endGame(key, game, game.now())
+			}
+			previous := game.State
+			game.State = PhaseClosed
+			e.notifyPhase(game.phaseEvent(key, previous))
+		}
+	})
+}
+
+// removePlayer takes a player out of a game, its scores are kept for the
+// results. Expects to run on the game's loop.
+func (e *GameService) removePlayer(key string, game *Game, id string) {
+	if _, inGame := game.Players[id]; !inGame {
+		return
+	}
+	delete(game.Players, id)
+	delete(game.Ready, id)
+	e.unindexPlayer(key, id)
+	game.record(RecordedEvent{Tick: game.Tick + 1, Kind: RecordLeave, Player: id})
+	if !game.IsRunning() {
+		return
+	}
+	game.Eliminated = append(game.Eliminated, id)
+
+	now := game.now()
+	if game.Alive != nil && game.Alive[id] {
+		game.score(id).Survival += now.Sub(game.RoundStartedAt).Milliseconds()
+	}
+	delete(game.Alive, id)
+
+	// the last player standing after everyone else left wins, otherwise
+	// leaving may still decide the round
+	if len(game.Players) == 1 {
+		e.endGame(key, game, now)
+	} else if game.RoundOver() {
+		e.finishRound(key, game, now)
+	}
+}
+
+// RecordCollision adds a collision to the game's history
+func (g *Game) RecordCollision(collider string, victim string, time int64) {
+	g.Collisions = append(g.Collisions, Collision{Collider: collider, Victim: victim, Time: time})
+}
+
+// OnGameEnded registers a listener that receives the result of every game that ends.
+// Listeners run on the loop of the game and must not wait on the same game.
+func (e *GameService) OnGameEnded(listener func(GameResult)) {
+	e.listenersMutex.Lock()
+	defer e.listenersMutex.Unlock()
+	e.resultListeners = append(e.resultListeners, listener)
+}
+
+// OnPhaseChanged registers a listener that receives every lifecycle transition
+func (e *GameService) OnPhaseChanged(listener func(PhaseEvent)) {
+	e.listenersMutex.Lock()
+	defer e.listenersMutex.Unlock()
+	e.phaseListeners = append(e.phaseListeners, listener)
+}
+
+// OnRoundEnded registers a listener that receives the outcome of every round
+func (e *GameService) OnRoundEnded(listener func(RoundEvent)) {
+	e.listenersMutex.Lock()
+	defer e.listenersMutex.Unlock()
+	e.roundListeners = append(e.roundListeners, listener)
+}
+
+// EndGame finishes a running game and reports its result to the result listeners
+func (e *GameService) EndGame(key string) (GameResult, bool) {
+	game, ok := e.GetGame(key)
+	if !ok {
+		return GameResult{}, false
+	}
+	var result GameResult
+	ended := false
+	game.do(func() {
+		if !game.CanTransition(PhaseFinished) {
+			return
+		}
+		game.record(RecordedEvent{Tick: game.Tick + 1, Kind: RecordFinish})
+		result = e.endGame(key, game, game.now())
+		ended = true
+	})
+	return result, ended
+}
+
+// ScoreCollision scores a collision in a running game. When it decides the round the
+// next round is started, or the game is ended if it was the last one.
+func (e *GameService) ScoreCollision(key string, collider string, victim string, now time.Time) CollisionOutcome {
+	game, ok := e.GetGame(key)
+	if !ok {
+		return CollisionOutcome{}
+	}
+	var outcome CollisionOutcome
+	game.do(func() {
+		if !game.IsRunning() {
+			return
+		}
+		outcome = CollisionOutcome{Eliminated: game.ScoreCollision(collider, victim, now), Round: game.Round}
+		if game.RoundOver() {
+			round := e.finishRound(key, game, now)
+			outcome.RoundOver = true
+			outcome.RoundWinner = round.Winner
+			outcome.GameOver = round.GameOver
+		}
+	})
+	return outcome
+}
+
+// advance moves the game through its lifecycle: starting it once enough players
+// are ready and applying phase timeouts, it runs on the game's loop
+func (e *GameService) advance(key string, game *Game, now time.Time) {
+	// games created without a lifecycle (e.g. legacy literals) are left alone
+	if _, known := transitions[game.State]; !known {
+		return
+	}
+
+	// a single advance may pass several phases, e.g. waiting -> ready -> countdown
+	for {
+		previous := game.State
+		next, dropped := game.nextPhase(now)
+		for _, id := range dropped {
+			e.removePlayer(key, game, id)
+		}
+		if next == previous {
+			return
+		}
+		if next == PhaseFinished {
+			e.endGame(key, game, now)
+			continue
+		}
+		if err := game.Transition(next, now); err != nil {
+			log.Println(err)
+			return
+		}
+		if next == PhaseWaiting && game.Lifecycle.ReadyCheck {
+			game.Ready = make(map[string]bool)
+		}
+		if next == PhaseRunning {
+			game.startRound(now)
+		}
+		event := game.phaseEvent(key, previous)
+		event.Dropped = dropped
+		e.notifyPhase(event)
+		if next == PhaseClosed {
+			return
+		}
+	}
+}
+
+// finishRound ends the current round and either starts the next one or ends the game
+func (e *GameService) finishRound(key string, game *Game, now time.Time) RoundEvent {
+	round := RoundEvent{GameKey: key, Round: game.Round, Winner: game.endRound(now)}
+	round.Scores = game.finalScores()
+	round.GameOver = game.matchOver()
+	e.notifyRound(round)
+	if round.GameOver {
+		e.endGame(key, game, now)
+	} else {
+		game.startRound(now)
+	}
+	return round
+}
+
+// endGame marks a game finished and reports its result.
+// Remaining players are placed by rounds won, then points, then fewest deaths, followed
+// by the players that left with the last one to leave placed highest.
+func (e *GameService) endGame(key string, game *Game, now time.Time) GameResult {
+	game.closeRound(now)
+	previous := game.State
+	if err := game.Transition(PhaseFinished, now); err != nil {
+		log.Println(err)
+	}
+
+	deaths := make(map[string]int)
+	for _, collision := range game.Collisions {
+		deaths[collision.Victim]++
+	}
+
+	placements := make([]string, 0, len(game.Players)+len(game.Eliminated))
+	for name := range game.Players {
+		placements = append(placements, name)
+	}
+	sort.Slice(placements, func(i, j int) bool {
+		left, right := game.score(placements[i]), game.score(placements[j])
+		if left.RoundsWon != right.RoundsWon {
+			return left.RoundsWon > right.RoundsWon
+		}
+		if left.Points != right.Points {
+			return left.Points > right.Points
+		}
+		if deaths[placements[i]] == deaths[placements[j]] {
+			return placements[i] < placements[j]
+		}
+		return deaths[placements[i]] < deaths[placements[j]]
+	})
+	for i := len(game.Eliminated) - 1; i >= 0; i-- {
+		if _, stillPlaying := game.Players[game.Eliminated[i]]; !stillPlaying && !containsName(placements, game.Eliminated[i]) {
+			placements = append(placements, game.Eliminated[i])
+		}
+	}
+
+	result := GameResult{
+		GameKey:    key,
+		Ranked:     game.Ranked,
+		Placements: placements,
+		Collisions: append([]Collision(nil), game.Collisions...),
+		Rounds:     game.Round,
+		Scores:     game.finalScores(),
+	}
+	if len(placements) > 0 {
+		result.Winner = placements[0]
+	}
+
+	e.notifyPhase(game.phaseEvent(key, previous))
+	e.notifyResult(result)
+	return result
+}
+
+// notifyPhase calls the phase listeners
+func (e *GameService) notifyPhase(event PhaseEvent) {
+	e.listenersMutex.Lock()
+	listeners := make([]func(PhaseEvent), len(e.phaseListeners))
+	copy(listeners, e.phaseListeners)
+	e.listenersMutex.Unlock()
+
+	log.Printf("🚦 Game %v: %v -> %v\n", event.GameKey, event.Previous, event.Phase)
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// notifyRound calls the round listeners
+func (e *GameService) notifyRound(round RoundEvent) {
+	e.listenersMutex.Lock()
+	listeners := make([]func(RoundEvent), len(e.roundListeners))
+	copy(listeners, e.roundListeners)
+	e.listenersMutex.Unlock()
+
+	log.Printf("🔔 Game %v round %v won by %v\n", round.GameKey, round.Round, round.Winner)
+	for _, listener := range listeners {
+		listener(round)
+	}
+}
+
+// notifyResult calls the result listeners
+func (e *GameService) notifyResult(result GameResult) {
+	e.listenersMutex.Lock()
+	listeners := make([]func(GameResult), len(e.resultListeners))
+	copy(listeners, e.resultListeners)
+	e.listenersMutex.Unlock()
+
+	log.Printf("🏁 Game %v finished: %v\n", result.GameKey, result.Placements)
+	for _, listener := range listeners {
+		listener(result)
+	}
+}
+
+func containsName(names []string, name string) bool {
+	for _, item := range names {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+// indexPlayer records that a player is in a game
+func (e *GameService) indexPlayer(key string, name string) {
+	e.GamesMutex.Lock()
+	defer e.GamesMutex.Unlock()
+	if _, ok := e.playerGames[name]; !ok {
+		e.playerGames[name] = make(map[string]struct{})
+	}
+	e.playerGames[name][key] = struct{}{}
+}
 
-	// if game is empty, remove it
-	if len(game.Players) == 0 {
-		delete(e.Games, key)
+// unindexPlayer records that a player left a game
+func (e *GameService) unindexPlayer(key string, name string) {
+	e.GamesMutex.Lock()
+	defer e.GamesMutex.Unlock()
+	delete(e.playerGames[name], key)
+	if len(e.playerGames[name]) == 0 {
+		delete(e.playerGames, name)
 	}
 }
 
 // LeaveAllGames
 func (e *GameService) LeaveAllGames(player *Player) {
 	// use LeaveGame
-	keys := make([]string, 0, len(e.Games))
-	for k := range e.Games {
-		keys = append(keys, k)
-	}
-	for _, key := range keys {
+	for _, key := range e.GetPlayerGames(player) {
 		e.LeaveGame(key, player)
 	}
-	log.Printf("❌ Player %v left all games\n", player.Name)
+	log.Printf("❌ Player %v left all games\n", player.ID)
 }
 
-func (e *GameService) RotatePlayer(player *Player, rotation float64) error {
+// GetPlayerGames returns the keys of every game the player is in
+func (e *GameService) GetPlayerGames(player *Player) []string {
 	e.GamesMutex.Lock()
 	defer e.GamesMutex.Unlock()
-	for _, game := range e.Games {
-		for _, gamePlayer := range game.Players {
-			if gamePlayer.Name == player.Name {
-				gamePlayer.Rotation = rotation
-				return nil
-			}
-		}
+	var keys []string
+	for key := range e.playerGames[player.ID] {
+		keys = append(keys, key)
 	}
-	return fmt.Errorf("Player not found")
+	sort.Strings(keys)
+	return keys
 }
 
+// RotatePlayer turns the player in every game it is in
+func (e *GameService) RotatePlayer(player *Player, rotation float64) error {
+	return e.HandleInput(player, Input{Rotation: &rotation})
+}
+
+// RemoveGame removes a game and stops its loop
 func (e *GameService) RemoveGame(key string) {
 	e.GamesMutex.Lock()
 	defer e.GamesMutex.Unlock()
-	delete(e.Games, key)
+	if game, ok := e.Games[key]; ok {
+		game.stopLoop()
+		e.forgetLocked(key, game)
+	}
 }
 
 func (e *GameService) UpdateGame(key string, game *Game) {
-	e.GamesMutex.Lock()
-	defer e.GamesMutex.Unlock()
-	e.Games[key] = game
+	e.AddGame(key, game)
 }
 
+// GetGames returns a copy of the registry
 func (e *GameService) GetGames() map[string]*Game {
-	e.GamesMutex.Lock()
-	defer e.GamesMutex.Unlock()
-	return e.Games
+	return e.GetAllGames()
 }
 
+// GetAllGames returns a copy of the registry
 func (e *GameService) GetAllGames() map[string]*Game {
 	e.GamesMutex.Lock()
 	defer e.GamesMutex.Unlock()
-	return e.Games
+	games := make(map[string]*Game, len(e.Games))
+	for key, game := range e.Games {
+		games[key] = game
+	}
+	return games
 }
 
-func (e *GameService) UpdateAllGames(games map[string]*Game) {
-	e.GamesMutex.Lock()
-	defer e.GamesMutex.Unlock()
-	e.Games = games
+// GetAllGamesJSON serializes every game, each one on its own loop
+func (e *GameService) GetAllGamesJSON() string {
+	snapshots := make(map[string]json.RawMessage)
+	for key, game := range e.GetAllGames() {
+		var snapshot []byte
+		if game.do(func() { snapshot = game.snapshot() }) {
+			snapshots[key] = snapshot
+		}
+	}
+	jsonVersion, err := json.Marshal(snapshots)
+	if err != nil {
+		log.Println(err)
+	}
+	return string(jsonVersion)
 }
 
-func (e *GameService) GetAllGamesJSON() string {
-	e.GamesMutex.Lock()
-	defer e.GamesMutex.Unlock()
-	return toJSON(e.Games)
+// GetGameJSON serializes a single game keyed by its game key, the same shape
+// clients receive from GetAllGamesJSON
+func (e *GameService) GetGameJSON(key string) (string, bool) {
+	game, ok := e.GetGame(key)
+	if !ok {
+		return "", false
+	}
+	var jsonVersion string
+	if !game.do(func() { jsonVersion = toJSON(map[string]*Game{key: game}) }) {
+		return "", false
+	}
+	return jsonVersion, true
+}
+
+// snapshot serializes the game on its own
+func (g *Game) snapshot() []byte {
+	snapshot, err := json.Marshal(g)
+	if err != nil {
+		log.Println(err)
+	}
+	return snapshot
 }
 
 func toJSON(games map[string]*Game) string {
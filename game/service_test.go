@@ -1,7 +1,9 @@
 package game
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestProvideGameService(t *testing.T) {
@@ -11,8 +13,15 @@ func TestProvideGameService(t *testing.T) {
 	}
 }
 
+// manualGameService returns a service whose games only tick when the test calls Tick
+func manualGameService() *GameService {
+	service := NewGameService()
+	service.ManualTicks = true
+	return service
+}
+
 func TestAddGame(t *testing.T) {
-	service := ProvideGameService()
+	service := manualGameService()
 	game := &Game{
 		State:   "Test",
 		Players: make(map[string]*Player),
@@ -24,7 +33,7 @@ func TestAddGame(t *testing.T) {
 }
 
 func TestRemoveGame(t *testing.T) {
-	service := ProvideGameService()
+	service := manualGameService()
 	game := &Game{
 		State:   "Test",
 		Players: make(map[string]*Player),
@@ -37,7 +46,7 @@ func TestRemoveGame(t *testing.T) {
 }
 
 func TestUpdateGame(t *testing.T) {
-	service := ProvideGameService()
+	service := manualGameService()
 	game := &Game{
 		State:   "Test",
 		Players: make(map[string]*Player),
@@ -50,3 +59,78 @@ func TestUpdateGame(t *testing.T) {
 		t.Errorf("UpdateGame failed, expected %v, got %v", "Updated", updatedGame.State)
 	}
 }
+
+func TestGetGameJSON(t *testing.T) {
+	service := manualGameService()
+	game := &Game{
+		State:   "Test",
+		Players: make(map[string]*Player),
+	}
+	service.AddGame("json", game)
+	jsonVersion, ok := service.GetGameJSON("json")
+	if !ok || jsonVersion != `{"json":{"State":"Test","Players":{}}}` {
+		t.Errorf("GetGameJSON failed, expected %v, got %v", `{"json":{"State":"Test","Players":{}}}`, jsonVersion)
+	}
+	if _, ok := service.GetGameJSON("missing"); ok {
+		t.Errorf("GetGameJSON failed, expected %v, got %v", "false", "true")
+	}
+}
+
+func TestGetPlayerGames(t *testing.T) {
+	service := manualGameService()
+	player := service.NewPlayer("player")
+	service.AddGame("joined", NewGame(DefaultLifecycleConfig()))
+	if err := service.JoinGame("joined", player); err != nil {
+		t.Errorf("JoinGame failed, expected %v, got %v", nil, err)
+	}
+	games := service.GetPlayerGames(player)
+	if len(games) != 1 || games[0] != "joined" {
+		t.Errorf("GetPlayerGames failed, expected %v, got %v", []string{"joined"}, games)
+	}
+}
+
+func TestLeaveGameEndsWithLastPlayerStanding(t *testing.T) {
+	service := manualGameService()
+	results := make(chan GameResult, 1)
+	service.OnGameEnded(func(result GameResult) {
+		if result.GameKey == "last-standing" {
+			results <- result
+		}
+	})
+
+	game := NewGame(DefaultLifecycleConfig())
+	game.Ranked = true
+	service.AddGame("last-standing", game)
+	winner, loser := service.NewPlayer("winner"), service.NewPlayer("loser")
+	service.JoinGame("last-standing", winner)
+	service.JoinGame("last-standing", loser)
+	service.WithGame("last-standing", func(game *Game) {
+		game.State = PhaseRunning
+		game.RecordCollision("winner", "loser", 0)
+	})
+	service.LeaveGame("last-standing", loser)
+
+	select {
+	case result := <-results:
+		if result.Winner != "winner" || len(result.Placements) != 2 || result.Placements[1] != "loser" || len(result.Collisions) != 1 {
+			t.Errorf("LeaveGame failed, expected %v, got %v", "winner then loser", result)
+		}
+	default:
+		t.Errorf("LeaveGame failed, expected %v, got %v", "a result", "nothing")
+	}
+}
+
+func TestTickStampsSnapshots(t *testing.T) {
+	service := manualGameService()
+	var events []TickEvent
+	service.OnTick(func(event TickEvent) {
+		events = append(events, event)
+	})
+	service.AddGame("ticks", NewGame(DefaultLifecycleConfig()))
+	now := time.Now()
+	service.Tick("ticks", now)
+	service.Tick("ticks", now.Add(DefaultGameConfig().TickInterval()))
+	if len(events) != 2 || events[1].Tick != 2 || !strings.Contains(events[1].Snapshot, `"Tick":2`) {
+		t.Errorf("Tick failed, expected %v, got %v", "2 snapshots stamped with their tick", events)
+	}
+}
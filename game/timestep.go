@@ -0,0 +1,34 @@
+package game
+
+import "time"
+
+// fixedStep turns wall clock time into whole simulation steps, time that is not
+// a full step yet carries over to the next call of due
+type fixedStep struct {
+	step time.Duration
+	// simulated is the time the game has been simulated up to
+	simulated time.Time
+}
+
+// newFixedStep starts a clock that has simulated up to start
+func newFixedStep(step time.Duration, start time.Time) *fixedStep {
+	return &fixedStep{step: step, simulated: start}
+}
+
+// due returns how many steps to simulate to catch up with now and how many
+// were skipped because the game fell more than maxCatchUpSteps behind
+func (c *fixedStep) due(now time.Time) (steps int, skipped int) {
+	steps = int(now.Sub(c.simulated) / c.step)
+	if steps > maxCatchUpSteps {
+		skipped = steps - maxCatchUpSteps
+		steps = maxCatchUpSteps
+		c.simulated = c.simulated.Add(time.Duration(skipped) * c.step)
+	}
+	return steps, skipped
+}
+
+// next moves the clock one step ahead and returns the time of that step
+func (c *fixedStep) next() time.Time {
+	c.simulated = c.simulated.Add(c.step)
+	return c.simulated
+}
@@ -5,6 +5,8 @@ go 1.19
 require (
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/gofiber/fiber/v2 v2.49.2
+	github.com/gofiber/websocket/v2 v2.2.1
+	github.com/google/wire v0.5.0
 )
 
 require (
@@ -14,10 +16,8 @@ require (
 	github.com/fasthttp/websocket v1.5.3 // indirect
 	github.com/gofiber/fiber v1.14.6 // indirect
 	github.com/gofiber/utils v0.0.10 // indirect
-	github.com/gofiber/websocket/v2 v2.2.1 // indirect
 	github.com/google/subcommands v1.0.1 // indirect
 	github.com/google/uuid v1.3.1 // indirect
-	github.com/google/wire v0.5.0 // indirect
 	github.com/gorilla/schema v1.1.0 // indirect
 	github.com/klauspost/compress v1.16.7 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
@@ -14,6 +14,9 @@ func main() {
 	log.Println("🚚 Initializing services...")
 	matchMakingManager := InitializeMatch()
 	websocketManager := InitializeWebSocket()
+	connectionManager := InitializeConnectionController()
+	authManager := InitializeAuth()
+	replayManager := InitializeReplay()
 
 	// initialize multiple background services to run in parallel
 	backgroundServiceManager := InitializeBackgroundService()
@@ -22,9 +25,13 @@ func main() {
 	log.Println("🚥 Initializing routes...")
 	app.Static("/", "./app/build")
 
-	app.Use("/ws", websocket.UpgradeWebSocket)
+	app.Post("/auth/guest", authManager.PostGuest)
+	app.Use("/ws", websocket.UpgradeWebSocket, authManager.AuthService.RequireToken)
+	app.Get("/ws/replay/:id", replayManager.HandleReplay)
 	app.Get("/ws/:id", websocketManager.HandleWebSocketConnections)
-	app.Get("/matcher", matchMakingManager.Get)
+	app.Get("/matcher", authManager.AuthService.RequireToken, matchMakingManager.Get)
+	app.Get("/stats/connections", authManager.AuthService.RequireToken, connectionManager.Get)
+	app.Get("/replays", authManager.AuthService.RequireToken, replayManager.List)
 
 	log.Println("🍔 Starting background processes...")
 	backgroundServiceManager.Start()
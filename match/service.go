@@ -1,24 +1,99 @@
 package match
 
 import (
-	"drbh/partita/redis"
+	"context"
+	"drbh/partita/game"
+	"drbh/partita/rating"
 	"log"
+	"os"
 	"strconv"
 	"time"
 )
 
 type MatchmakingService struct {
-	RedisService *redis.MyRedisService
+	Store   MatchStore
+	Window  EloWindow
+	Ratings rating.Calculator
+	// BotFillAfter is how long a player waits before being matched with a bot, 0 never does
+	BotFillAfter time.Duration
 }
 
-func NewMatchmakingService(redisService *redis.MyRedisService) MatchmakingService {
+// DefaultBotFillAfter is used unless PARTITA_BOT_FILL_AFTER sets another duration
+const DefaultBotFillAfter = 30 * time.Second
+
+// QueueStatus describes a queued player's search, sent to clients while they wait
+type QueueStatus struct {
+	PlayerID string  `json:"playerId"`
+	Elo      float64 `json:"elo"`
+	Window   float64 `json:"window"`
+	MinElo   float64 `json:"minElo"`
+	MaxElo   float64 `json:"maxElo"`
+	Waited   float64 `json:"waited"`
+	Pending  int     `json:"pending"`
+}
+
+func NewMatchmakingService(store MatchStore) MatchmakingService {
 	return MatchmakingService{
-		RedisService: redisService,
+		Store:        store,
+		Window:       eloWindow(os.Getenv("PARTITA_ELO_WINDOW"), os.Getenv("PARTITA_ELO_WINDOW_MAX")),
+		Ratings:      rating.NewCalculator(os.Getenv("PARTITA_RATING")),
+		BotFillAfter: botFillAfter(os.Getenv("PARTITA_BOT_FILL_AFTER")),
 	}
 }
 
+// botFillAfter parses a duration such as "45s", "0" turns bots off and an
+// empty or invalid value falls back to DefaultBotFillAfter
+func botFillAfter(value string) time.Duration {
+	if value == "" {
+		return DefaultBotFillAfter
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		log.Printf("Invalid PARTITA_BOT_FILL_AFTER %q, using %v\n", value, DefaultBotFillAfter)
+		return DefaultBotFillAfter
+	}
+	return duration
+}
+
+// GetRating returns the stored rating of a player
+func (s *MatchmakingService) GetRating(playerId string) rating.Rating {
+	playerRating, err := s.Store.GetRating(playerId)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return playerRating
+}
+
+// EnqueuePlayer places a player in the queue with their stored rating
+func (s *MatchmakingService) EnqueuePlayer(playerId string) error {
+	return s.AddPlayer(playerId, s.GetRating(playerId).Value)
+}
+
+// RecordResult updates and stores the ratings of every player of a finished game
+func (s *MatchmakingService) RecordResult(result game.GameResult) (map[string]rating.Rating, error) {
+	if len(result.Placements) < 2 {
+		return nil, nil
+	}
+
+	ratings := make(map[string]rating.Rating, len(result.Placements))
+	for _, playerId := range result.Placements {
+		ratings[playerId] = s.GetRating(playerId)
+	}
+
+	updated := s.Ratings.Update(ratings, result.Placements)
+	for playerId, playerRating := range updated {
+		if err := s.Store.SetRating(playerId, playerRating); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+
+	return updated, nil
+}
+
 func (s *MatchmakingService) GetPlayerElo(playerId string) float64 {
-	playersElo, err := s.RedisService.GetPlayerElo(playerId)
+	playersElo, err := s.Store.GetPlayerElo(playerId)
 
 	if err != nil {
 		log.Println(err)
@@ -29,7 +104,7 @@ func (s *MatchmakingService) GetPlayerElo(playerId string) float64 {
 }
 
 func (s *MatchmakingService) AddPlayer(playerId string, playerElo float64) error {
-	err := s.RedisService.AddPlayer(playerId, playerElo)
+	err := s.Store.AddPlayer(playerId, playerElo)
 
 	if err != nil {
 		log.Println(err)
@@ -40,7 +115,7 @@ func (s *MatchmakingService) AddPlayer(playerId string, playerElo float64) error
 }
 
 func (s *MatchmakingService) GetPotentialMatchesByElo(playerElo float64, eloThreshold float64) []string {
-	matches, err := s.RedisService.Elo(playerElo, eloThreshold)
+	matches, err := s.Store.Elo(playerElo, eloThreshold)
 
 	if err != nil {
 		log.Println(err)
@@ -51,7 +126,7 @@ func (s *MatchmakingService) GetPotentialMatchesByElo(playerElo float64, eloThre
 }
 
 func (s *MatchmakingService) WasRecentlyPlayed(playerId, matchId string) bool {
-	timestamp, err := s.RedisService.GetLastPlayedWith(playerId, matchId)
+	timestamp, err := s.Store.GetLastPlayedWith(playerId, matchId)
 
 	if err != nil {
 		// log.Println(err)
@@ -75,7 +150,7 @@ func (s *MatchmakingService) WasRecentlyPlayed(playerId, matchId string) bool {
 }
 
 func (s *MatchmakingService) RemovePlayer(playerId string) error {
-	err := s.RedisService.RemovePlayer(playerId)
+	err := s.Store.RemovePlayer(playerId)
 
 	if err != nil {
 		log.Println(err)
@@ -86,7 +161,7 @@ func (s *MatchmakingService) RemovePlayer(playerId string) error {
 }
 
 func (s *MatchmakingService) UpdateLastPlayedWith(playerId, matchId string) error {
-	err := s.RedisService.UpdateLastPlayedWith(playerId, matchId)
+	err := s.Store.UpdateLastPlayedWith(playerId, matchId)
 
 	if err != nil {
 		log.Println(err)
@@ -97,7 +172,7 @@ func (s *MatchmakingService) UpdateLastPlayedWith(playerId, matchId string) erro
 }
 
 func (s *MatchmakingService) AddToRecentlyPlayed(playerId, matchId string) error {
-	err := s.RedisService.AddLastPlayedWith(playerId, matchId)
+	err := s.Store.AddLastPlayedWith(playerId, matchId)
 
 	if err != nil {
 		log.Println(err)
@@ -108,7 +183,7 @@ func (s *MatchmakingService) AddToRecentlyPlayed(playerId, matchId string) error
 }
 
 func (s *MatchmakingService) GetMatch(matchId string) (string, error) {
-	match, err := s.RedisService.GetMatch(matchId)
+	match, err := s.Store.GetMatch(matchId)
 
 	if err != nil {
 		log.Println(err)
@@ -119,7 +194,7 @@ func (s *MatchmakingService) GetMatch(matchId string) (string, error) {
 }
 
 func (s *MatchmakingService) IsBlocked(playerId, matchId string) bool {
-	isBlocked, err := s.RedisService.IsBlocked(playerId, matchId)
+	isBlocked, err := s.Store.IsBlocked(playerId, matchId)
 	if err != nil {
 		log.Println(err)
 		return false
@@ -131,13 +206,13 @@ func (s *MatchmakingService) IsBlocked(playerId, matchId string) bool {
 func (s *MatchmakingService) GetPendingPlayers() []string {
 
 	// check that the connection is still alive
-	err := s.RedisService.Rdb.Ping(s.RedisService.Ctx).Err()
+	err := s.Store.Ping()
 	if err != nil {
-		log.Println("Redis connection is not alive: ", err)
+		log.Println("Match store is not alive: ", err)
 		return nil
 	}
 
-	playerIds, errTwo := s.RedisService.GetPendingPlayers()
+	playerIds, errTwo := s.Store.GetPendingPlayers()
 
 	if errTwo != nil {
 		log.Println("Error getting pending players: ", errTwo)
@@ -165,7 +240,7 @@ func (s *MatchmakingService) FindMatch(playerId string, playerElo float64, eloTh
 	pendingPlayers := s.GetPendingPlayers()
 
 	for _, matchedPlayer := range potentialMatches {
-		if contains(pendingPlayers, matchedPlayer) && !s.WasRecentlyPlayed(playerId, matchedPlayer) && !s.IsBlocked(playerId, matchedPlayer) {
+		if matchedPlayer != playerId && contains(pendingPlayers, matchedPlayer) && !s.WasRecentlyPlayed(playerId, matchedPlayer) && !s.IsBlocked(playerId, matchedPlayer) {
 			return matchedPlayer
 		}
 	}
@@ -173,9 +248,91 @@ func (s *MatchmakingService) FindMatch(playerId string, playerElo float64, eloTh
 	return ""
 }
 
-// PublishMatch publishes a match to the Redis channel
+// CurrentWindow returns the Elo threshold of a queued player based on their time in queue
+func (s *MatchmakingService) CurrentWindow(playerId string) float64 {
+	enqueuedAt, err := s.Store.GetEnqueuedAt(playerId)
+	if err != nil {
+		return s.Window.Base
+	}
+
+	return s.Window.At(time.Since(enqueuedAt))
+}
+
+// GetQueueStatus returns the search status of a queued player, false once they left the queue
+func (s *MatchmakingService) GetQueueStatus(playerId string) (QueueStatus, bool) {
+	enqueuedAt, err := s.Store.GetEnqueuedAt(playerId)
+	if err != nil {
+		return QueueStatus{}, false
+	}
+
+	playerElo := s.GetPlayerElo(playerId)
+	waited := time.Since(enqueuedAt)
+	window := s.Window.At(waited)
+	pendingPlayers := s.GetPendingPlayers()
+
+	return QueueStatus{
+		PlayerID: playerId,
+		Elo:      playerElo,
+		Window:   window,
+		MinElo:   playerElo - window,
+		MaxElo:   playerElo + window,
+		Waited:   waited.Seconds(),
+		Pending:  len(pendingPlayers),
+	}, true
+}
+
+// ClaimMatch atomically takes two players out of the queue, it returns false if
+// another matcher already claimed either of them
+func (s *MatchmakingService) ClaimMatch(playerId, matchId string) bool {
+	claimed, err := s.Store.ClaimMatch(playerId, matchId)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return claimed
+}
+
+// ClaimBotMatch takes a player that waited longer than BotFillAfter out of the
+// queue so it can play a bot instead, it must only be called by the lease holder
+func (s *MatchmakingService) ClaimBotMatch(playerId string) bool {
+	if s.BotFillAfter <= 0 {
+		return false
+	}
+	enqueuedAt, err := s.Store.GetEnqueuedAt(playerId)
+	if err != nil || time.Since(enqueuedAt) < s.BotFillAfter {
+		return false
+	}
+
+	return s.RemovePlayer(playerId) == nil
+}
+
+// AcquireLease takes or renews a named lease, only the holder should run the work it guards
+func (s *MatchmakingService) AcquireLease(name, owner string, ttl time.Duration) bool {
+	acquired, err := s.Store.AcquireLease(name, owner, ttl)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+
+	return acquired
+}
+
+// ReleaseLease gives up a named lease
+func (s *MatchmakingService) ReleaseLease(name, owner string) error {
+	err := s.Store.ReleaseLease(name, owner)
+
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// PublishMatch publishes a match to the match store
 func (s *MatchmakingService) PublishMatch(match string) error {
-	err := s.RedisService.PublishMatch(match)
+	err := s.Store.PublishMatch(match)
 
 	if err != nil {
 		log.Println(err)
@@ -185,7 +342,7 @@ func (s *MatchmakingService) PublishMatch(match string) error {
 	return nil
 }
 
-// ListenForMatch listens for a match on the Redis channel
-func (s *MatchmakingService) ListenForMatch(playerId string, callback func(matchId string)) {
-	s.RedisService.ListenForMatches(playerId, callback)
+// ListenForMatch listens for a match on the match store until ctx is done
+func (s *MatchmakingService) ListenForMatch(ctx context.Context, playerId string, callback func(matchId string)) {
+	s.Store.ListenForMatches(ctx, playerId, callback)
 }
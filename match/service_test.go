@@ -0,0 +1,95 @@
+package match
+
+import (
+	"drbh/partita/game"
+	"drbh/partita/memory"
+	"testing"
+	"time"
+)
+
+func TestFindMatch(t *testing.T) {
+	service := NewMatchmakingService(memory.NewMemoryMatchStore())
+	service.AddPlayer("one", 100)
+	service.AddPlayer("two", 150)
+	service.AddPlayer("far", 900)
+
+	if match := service.FindMatch("two", 150, 100); match != "one" {
+		t.Errorf("FindMatch failed, expected %v, got %v", "one", match)
+	}
+	if match := service.FindMatch("far", 900, 100); match == "one" || match == "two" {
+		t.Errorf("FindMatch failed, expected %v, got %v", "no match in range", match)
+	}
+}
+
+func TestFindMatchSkipsBlocked(t *testing.T) {
+	store := memory.NewMemoryMatchStore()
+	service := NewMatchmakingService(store)
+	service.AddPlayer("one", 100)
+	service.AddPlayer("two", 100)
+	store.Block("one", "two")
+
+	if match := service.FindMatch("one", 100, 100); match == "two" {
+		t.Errorf("FindMatch failed, expected %v, got %v", "not two", match)
+	}
+}
+
+func TestGetQueueStatus(t *testing.T) {
+	service := NewMatchmakingService(memory.NewMemoryMatchStore())
+	service.AddPlayer("one", 1000)
+
+	service.Window.Interval = time.Hour
+	status, ok := service.GetQueueStatus("one")
+	if !ok || status.Window < service.Window.Base || status.Window > service.Window.Base+1 || status.Elo != 1000 {
+		t.Errorf("GetQueueStatus failed, expected %v, got %v", service.Window.Base, status)
+	}
+
+	service.RemovePlayer("one")
+	if _, ok := service.GetQueueStatus("one"); ok {
+		t.Errorf("GetQueueStatus failed, expected %v, got %v", false, ok)
+	}
+}
+
+func TestRecordResult(t *testing.T) {
+	service := NewMatchmakingService(memory.NewMemoryMatchStore())
+	service.RecordResult(game.GameResult{Placements: []string{"winner", "loser"}})
+
+	if winner, loser := service.GetRating("winner"), service.GetRating("loser"); winner.Value <= loser.Value {
+		t.Errorf("RecordResult failed, expected %v, got %v", "winner above loser", []float64{winner.Value, loser.Value})
+	}
+
+	// the queue uses the stored rating
+	service.EnqueuePlayer("winner")
+	if elo := service.GetPlayerElo("winner"); elo != service.GetRating("winner").Value {
+		t.Errorf("EnqueuePlayer failed, expected %v, got %v", service.GetRating("winner").Value, elo)
+	}
+}
+
+func TestClaimBotMatch(t *testing.T) {
+	service := NewMatchmakingService(memory.NewMemoryMatchStore())
+	service.AddPlayer("lonely", 1500)
+
+	service.BotFillAfter = time.Hour
+	if service.ClaimBotMatch("lonely") {
+		t.Errorf("ClaimBotMatch failed, expected %v, got %v", false, true)
+	}
+
+	service.BotFillAfter = time.Nanosecond
+	if !service.ClaimBotMatch("lonely") {
+		t.Errorf("ClaimBotMatch failed, expected %v, got %v", true, false)
+	}
+	if _, ok := service.GetQueueStatus("lonely"); ok {
+		t.Errorf("ClaimBotMatch failed, expected %v, got %v", "the player out of the queue", ok)
+	}
+}
+
+func TestBotFillAfter(t *testing.T) {
+	if after := botFillAfter(""); after != DefaultBotFillAfter {
+		t.Errorf("botFillAfter failed, expected %v, got %v", DefaultBotFillAfter, after)
+	}
+	if after := botFillAfter("0"); after != 0 {
+		t.Errorf("botFillAfter failed, expected %v, got %v", 0, after)
+	}
+	if after := botFillAfter("45s"); after != 45*time.Second {
+		t.Errorf("botFillAfter failed, expected %v, got %v", 45*time.Second, after)
+	}
+}
@@ -0,0 +1,78 @@
+package match
+
+import (
+	"context"
+	"drbh/partita/memory"
+	"drbh/partita/rating"
+	"drbh/partita/redis"
+	"log"
+	"os"
+	"time"
+)
+
+// MatchStore is the storage used by the MatchmakingService for the queue, the Elo
+// index, recently played pairs, the blocklist and match pub/sub
+type MatchStore interface {
+	// Ping checks that the store is reachable
+	Ping() error
+
+	// Elo returns the players within eloThreshold of playerElo
+	Elo(playerElo float64, eloThreshold float64) ([]string, error)
+	// GetPlayerElo returns the Elo of a queued player
+	GetPlayerElo(playerId string) (float64, error)
+
+	// AddPlayer adds a player to the Elo index and the pending queue
+	AddPlayer(playerId string, playerElo float64) error
+	// RemovePlayer removes a player from the Elo index and the pending queue
+	RemovePlayer(playerId string) error
+	// GetPendingPlayers returns the players waiting for a match
+	GetPendingPlayers() ([]string, error)
+	// GetEnqueuedAt returns when a player joined the queue
+	GetEnqueuedAt(playerId string) (time.Time, error)
+	// ClaimMatch atomically removes both players from the queue, only if both are still pending
+	ClaimMatch(playerId, matchId string) (bool, error)
+
+	// AcquireLease takes or renews a named lease so only one owner works on it across instances
+	AcquireLease(name, owner string, ttl time.Duration) (bool, error)
+	// ReleaseLease gives up a named lease held by owner
+	ReleaseLease(name, owner string) error
+
+	// GetLastPlayedWith returns the unix timestamp of the last match between two players
+	GetLastPlayedWith(playerId, matchId string) (string, error)
+	// AddLastPlayedWith records that two players just played
+	AddLastPlayedWith(playerId, matchId string) error
+	// UpdateLastPlayedWith records that two players just played
+	UpdateLastPlayedWith(playerId, matchId string) error
+
+	// GetRating returns a player's rating, the default rating for unknown players
+	GetRating(playerId string) (rating.Rating, error)
+	// SetRating stores a player's rating
+	SetRating(playerId string, playerRating rating.Rating) error
+
+	// IsBlocked checks if a player blocked another
+	IsBlocked(playerId, matchId string) (bool, error)
+
+	// GetMatch returns the stored data of a match
+	GetMatch(matchId string) (string, error)
+	// PublishMatch announces a match to every listener
+	PublishMatch(match string) error
+	// ListenForMatches blocks until a match containing playerId is published or ctx is done
+	ListenForMatches(ctx context.Context, playerId string, callback func(string))
+}
+
+var _ MatchStore = (*redis.MyRedisService)(nil)
+var _ MatchStore = (*memory.MemoryMatchStore)(nil)
+
+// ProvideMatchStore returns the MatchStore selected by PARTITA_MATCH_STORE,
+// "memory" for a single node in-process store and "redis" (default) otherwise
+func ProvideMatchStore() MatchStore {
+	switch os.Getenv("PARTITA_MATCH_STORE") {
+	case "memory":
+		return memory.GetMemoryMatchStoreInstance()
+	case "", "redis":
+		return redis.GetMyRedisServiceInstance()
+	default:
+		log.Printf("Unknown PARTITA_MATCH_STORE %q, using redis\n", os.Getenv("PARTITA_MATCH_STORE"))
+		return redis.GetMyRedisServiceInstance()
+	}
+}
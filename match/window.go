@@ -0,0 +1,85 @@
+package match
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"time"
+)
+
+// WindowCurve is the shape of the Elo search window growth
+type WindowCurve int
+
+const (
+	// LinearWindow grows the window continuously by Growth per Interval
+	LinearWindow WindowCurve = iota
+	// SteppedWindow grows the window by Growth once every full Interval
+	SteppedWindow
+)
+
+// EloWindow describes how the acceptable Elo range widens with time in queue
+type EloWindow struct {
+	Curve WindowCurve
+	// Base is the window of a player who just joined the queue
+	Base float64
+	// Growth is added to the window every Interval
+	Growth float64
+	// Interval is the period the Growth applies to
+	Interval time.Duration
+	// Max caps the window
+	Max float64
+}
+
+// DefaultEloWindow starts at ±100 and widens by 50 every 5 seconds up to ±500
+func DefaultEloWindow() EloWindow {
+	return EloWindow{
+		Curve:    LinearWindow,
+		Base:     100,
+		Growth:   50,
+		Interval: 5 * time.Second,
+		Max:      500,
+	}
+}
+
+// eloWindow is the DefaultEloWindow with the curve ("linear" or "stepped") and
+// the cap read from PARTITA_ELO_WINDOW and PARTITA_ELO_WINDOW_MAX, a cap of 0
+// lets the window grow forever and empty or invalid values keep the default
+func eloWindow(curve string, max string) EloWindow {
+	window := DefaultEloWindow()
+	switch curve {
+	case "":
+	case "linear":
+		window.Curve = LinearWindow
+	case "stepped":
+		window.Curve = SteppedWindow
+	default:
+		log.Printf("Invalid PARTITA_ELO_WINDOW %q, using linear\n", curve)
+	}
+	if max != "" {
+		parsed, err := strconv.ParseFloat(max, 64)
+		if err != nil || math.IsNaN(parsed) || parsed < 0 {
+			log.Printf("Invalid PARTITA_ELO_WINDOW_MAX %q, using %v\n", max, window.Max)
+		} else {
+			window.Max = parsed
+		}
+	}
+	return window
+}
+
+// At returns the Elo threshold after waiting in the queue for waited
+func (w EloWindow) At(waited time.Duration) float64 {
+	if waited < 0 || w.Interval <= 0 {
+		return w.Base
+	}
+
+	intervals := float64(waited) / float64(w.Interval)
+	if w.Curve == SteppedWindow {
+		intervals = math.Floor(intervals)
+	}
+
+	window := w.Base + intervals*w.Growth
+	if w.Max > 0 && window > w.Max {
+		return w.Max
+	}
+	return window
+}
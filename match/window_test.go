@@ -0,0 +1,41 @@
+package match
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLinearWindow(t *testing.T) {
+	window := EloWindow{Curve: LinearWindow, Base: 100, Growth: 50, Interval: 10 * time.Second, Max: 300}
+	if got := window.At(0); got != 100 {
+		t.Errorf("At failed, expected %v, got %v", 100, got)
+	}
+	if got := window.At(5 * time.Second); got != 125 {
+		t.Errorf("At failed, expected %v, got %v", 125, got)
+	}
+	if got := window.At(time.Hour); got != 300 {
+		t.Errorf("At failed, expected %v, got %v", 300, got)
+	}
+}
+
+func TestSteppedWindow(t *testing.T) {
+	window := EloWindow{Curve: SteppedWindow, Base: 100, Growth: 50, Interval: 10 * time.Second, Max: 300}
+	if got := window.At(9 * time.Second); got != 100 {
+		t.Errorf("At failed, expected %v, got %v", 100, got)
+	}
+	if got := window.At(25 * time.Second); got != 200 {
+		t.Errorf("At failed, expected %v, got %v", 200, got)
+	}
+}
+
+func TestEloWindowFromEnv(t *testing.T) {
+	if window := eloWindow("", ""); window != DefaultEloWindow() {
+		t.Errorf("eloWindow failed, expected %v, got %v", DefaultEloWindow(), window)
+	}
+	if window := eloWindow("stepped", "800"); window.Curve != SteppedWindow || window.Max != 800 {
+		t.Errorf("eloWindow failed, expected %v, got %v", []interface{}{SteppedWindow, 800}, []interface{}{window.Curve, window.Max})
+	}
+	if window := eloWindow("exponential", "-1"); window != DefaultEloWindow() {
+		t.Errorf("eloWindow failed, expected %v, got %v", DefaultEloWindow(), window)
+	}
+}
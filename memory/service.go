@@ -0,0 +1,363 @@
+// Package memory provides an in-process match store for single node deployments and tests
+package memory
+
+import (
+	"context"
+	"drbh/partita/rating"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrNotFound mirrors redis.Nil for lookups of missing keys
+var ErrNotFound = errors.New("memory: key not found")
+
+// MemoryMatchStore keeps the matchmaking queue, Elo index, recently played pairs,
+// blocklist and match pub/sub in process memory
+type MemoryMatchStore struct {
+	eloScores      map[string]float64
+	enqueuedAt     map[string]time.Time
+	pendingPlayers []string
+	lastPlayedWith map[string]map[string]int64
+	blocked        map[string]map[string]bool
+	matches        map[string]string
+	subscribers    map[int]*subscriber
+	nextSubscriber int
+	leases         map[string]lease
+	ratings        map[string]rating.Rating
+	mu             sync.Mutex
+}
+
+// subscriber is a listener waiting for the match of a player
+type subscriber struct {
+	playerId string
+	matches  chan string
+	// stopped is closed once the listener no longer receives
+	stopped chan struct{}
+}
+
+type lease struct {
+	owner   string
+	expires time.Time
+}
+
+var memoryMatchStoreInstance *MemoryMatchStore
+var once sync.Once
+
+// ProvideMemoryMatchStore returns the singleton instance of MemoryMatchStore
+func ProvideMemoryMatchStore() *MemoryMatchStore {
+	return GetMemoryMatchStoreInstance()
+}
+
+// GetMemoryMatchStoreInstance returns the singleton instance of MemoryMatchStore
+func GetMemoryMatchStoreInstance() *MemoryMatchStore {
+	once.Do(func() {
+		memoryMatchStoreInstance = NewMemoryMatchStore()
+		log.Println("🧠 Successfully connected to Memory Match Store")
+	})
+	return memoryMatchStoreInstance
+}
+
+// NewMemoryMatchStore creates an empty store, mostly useful for tests
+func NewMemoryMatchStore() *MemoryMatchStore {
+	return &MemoryMatchStore{
+		eloScores:      make(map[string]float64),
+		enqueuedAt:     make(map[string]time.Time),
+		lastPlayedWith: make(map[string]map[string]int64),
+		blocked:        make(map[string]map[string]bool),
+		matches:        make(map[string]string),
+		subscribers:    make(map[int]*subscriber),
+		leases:         make(map[string]lease),
+		ratings:        make(map[string]rating.Rating),
+	}
+}
+
+// Ping always succeeds since the store lives in process
+func (s *MemoryMatchStore) Ping() error {
+	return nil
+}
+
+// Elo returns the players within a certain Elo range ordered by Elo
+func (s *MemoryMatchStore) Elo(playerElo float64, eloThreshold float64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lowerBound := playerElo - eloThreshold
+	upperBound := playerElo + eloThreshold
+
+	var players []string
+	for playerId, elo := range s.eloScores {
+		if elo >= lowerBound && elo <= upperBound {
+			players = append(players, playerId)
+		}
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if s.eloScores[players[i]] == s.eloScores[players[j]] {
+			return players[i] < players[j]
+		}
+		return s.eloScores[players[i]] < s.eloScores[players[j]]
+	})
+	return players, nil
+}
+
+// GetPlayerElo returns the Elo score of a given player
+func (s *MemoryMatchStore) GetPlayerElo(playerId string) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elo, ok := s.eloScores[playerId]
+	if !ok {
+		return -1.0, ErrNotFound
+	}
+	return elo, nil
+}
+
+// AddPlayer adds a player to the Elo scores and pending players lists
+func (s *MemoryMatchStore) AddPlayer(playerId string, playerElo float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.eloScores[playerId] = playerElo
+	s.enqueuedAt[playerId] = time.Now()
+	// a player queueing again moves to the front instead of being queued twice
+	pending := []string{playerId}
+	for _, pendingPlayer := range s.pendingPlayers {
+		if pendingPlayer != playerId {
+			pending = append(pending, pendingPlayer)
+		}
+	}
+	// newest first, like LPUSH
+	s.pendingPlayers = pending
+	return nil
+}
+
+// RemovePlayer removes a player from the Elo scores and pending players lists
+func (s *MemoryMatchStore) RemovePlayer(playerId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.eloScores, playerId)
+	delete(s.enqueuedAt, playerId)
+	pending := s.pendingPlayers[:0]
+	for _, pendingPlayer := range s.pendingPlayers {
+		if pendingPlayer != playerId {
+			pending = append(pending, pendingPlayer)
+		}
+	}
+	s.pendingPlayers = pending
+	return nil
+}
+
+// ClaimMatch atomically removes two players from the pending queue and Elo scores,
+// it returns false if either of them was already claimed
+func (s *MemoryMatchStore) ClaimMatch(playerId, matchId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first, second := false, false
+	for _, pendingPlayer := range s.pendingPlayers {
+		first = first || pendingPlayer == playerId
+		second = second || pendingPlayer == matchId
+	}
+	if !first || !second {
+		return false, nil
+	}
+
+	pending := s.pendingPlayers[:0]
+	for _, pendingPlayer := range s.pendingPlayers {
+		if pendingPlayer != playerId && pendingPlayer != matchId {
+			pending = append(pending, pendingPlayer)
+		}
+	}
+	s.pendingPlayers = pending
+	delete(s.eloScores, playerId)
+	delete(s.eloScores, matchId)
+	delete(s.enqueuedAt, playerId)
+	delete(s.enqueuedAt, matchId)
+	return true, nil
+}
+
+// GetEnqueuedAt returns when a player joined the pending players list
+func (s *MemoryMatchStore) GetEnqueuedAt(playerId string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enqueuedAt, ok := s.enqueuedAt[playerId]
+	if !ok {
+		return time.Time{}, ErrNotFound
+	}
+	return enqueuedAt, nil
+}
+
+// AcquireLease takes or renews the named lease for owner, it returns false while
+// another owner holds it
+func (s *MemoryMatchStore) AcquireLease(name, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.leases[name]
+	if ok && current.owner != owner && time.Now().Before(current.expires) {
+		return false, nil
+	}
+	s.leases[name] = lease{owner: owner, expires: time.Now().Add(ttl)}
+	return true, nil
+}
+
+// ReleaseLease gives up the named lease if owner holds it
+func (s *MemoryMatchStore) ReleaseLease(name, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.leases[name]; ok && current.owner == owner {
+		delete(s.leases, name)
+	}
+	return nil
+}
+
+// GetPendingPlayers returns a list of players who are waiting for a match
+func (s *MemoryMatchStore) GetPendingPlayers() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.pendingPlayers...), nil
+}
+
+// GetLastPlayedWith returns the unix timestamp of the last match between two players
+func (s *MemoryMatchStore) GetLastPlayedWith(playerId, matchId string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	timestamp, ok := s.lastPlayedWith[playerId][matchId]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return strconv.FormatInt(timestamp, 10), nil
+}
+
+// AddLastPlayedWith adds a player to the list of players a given player has played with
+func (s *MemoryMatchStore) AddLastPlayedWith(playerId, matchId string) error {
+	return s.UpdateLastPlayedWith(playerId, matchId)
+}
+
+// UpdateLastPlayedWith updates the last played with list for a given player
+func (s *MemoryMatchStore) UpdateLastPlayedWith(playerId, matchId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.lastPlayedWith[playerId]; !ok {
+		s.lastPlayedWith[playerId] = make(map[string]int64)
+	}
+	s.lastPlayedWith[playerId][matchId] = time.Now().Unix()
+	return nil
+}
+
+// GetRating returns the stored rating of a player, or the default rating of a new player
+func (s *MemoryMatchStore) GetRating(playerId string) (rating.Rating, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	playerRating, ok := s.ratings[playerId]
+	if !ok {
+		return rating.NewRating(), nil
+	}
+	return playerRating, nil
+}
+
+// SetRating stores the rating of a player
+func (s *MemoryMatchStore) SetRating(playerId string, playerRating rating.Rating) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ratings[playerId] = playerRating
+	return nil
+}
+
+// Block adds matchId to the blocklist of playerId
+func (s *MemoryMatchStore) Block(playerId, matchId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.blocked[playerId]; !ok {
+		s.blocked[playerId] = make(map[string]bool)
+	}
+	s.blocked[playerId][matchId] = true
+}
+
+// IsBlocked checks if a player is blocked from a given match
+func (s *MemoryMatchStore) IsBlocked(playerId, matchId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blocked[playerId][matchId], nil
+}
+
+// GetMatch returns the match data for a given match ID
+func (s *MemoryMatchStore) GetMatch(matchId string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	match, ok := s.matches[matchId]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return match, nil
+}
+
+// PublishMatch publishes a match to the listeners of its players. The players
+// already left the queue, so it waits for each listener until it receives the
+// match or stops listening.
+func (s *MemoryMatchStore) PublishMatch(match string) error {
+	var players []string
+	if err := json.Unmarshal([]byte(match), &players); err != nil {
+		return err
+	}
+	inMatch := make(map[string]bool, len(players))
+	for _, player := range players {
+		inMatch[player] = true
+	}
+
+	s.mu.Lock()
+	var listeners []*subscriber
+	for _, listener := range s.subscribers {
+		if inMatch[listener.playerId] {
+			listeners = append(listeners, listener)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, listener := range listeners {
+		select {
+		case listener.matches <- match:
+		case <-listener.stopped:
+		}
+	}
+	return nil
+}
+
+// ListenForMatches listens for matches and call the callback function when a match
+// is received, it returns once ctx is done
+func (s *MemoryMatchStore) ListenForMatches(ctx context.Context, playerId string, callback func(string)) {
+	listener := &subscriber{playerId: playerId, matches: make(chan string, 16), stopped: make(chan struct{})}
+	s.mu.Lock()
+	id := s.nextSubscriber
+	s.nextSubscriber++
+	s.subscribers[id] = listener
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, id)
+		s.mu.Unlock()
+		close(listener.stopped)
+	}()
+
+	for {
+		var payload string
+		select {
+		case <-ctx.Done():
+			return
+		case payload = <-listener.matches:
+		}
+		var matchDataSlice []string
+		json.Unmarshal([]byte(payload), &matchDataSlice)
+
+		// check if player is in the match
+		for _, player := range matchDataSlice {
+			if player == playerId {
+				callback(payload)
+				log.Printf("Match found for: %v\n", playerId)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAddPlayer(t *testing.T) {
+	store := NewMemoryMatchStore()
+	store.AddPlayer("one", 100)
+	store.AddPlayer("two", 150)
+	pending, _ := store.GetPendingPlayers()
+	if len(pending) != 2 || pending[0] != "two" {
+		t.Errorf("AddPlayer failed, expected %v, got %v", []string{"two", "one"}, pending)
+	}
+	elo, err := store.GetPlayerElo("one")
+	if err != nil || elo != 100 {
+		t.Errorf("GetPlayerElo failed, expected %v, got %v", 100, elo)
+	}
+}
+
+func TestAddPlayerTwice(t *testing.T) {
+	store := NewMemoryMatchStore()
+	store.AddPlayer("one", 100)
+	store.AddPlayer("two", 150)
+	store.AddPlayer("one", 120)
+	pending, _ := store.GetPendingPlayers()
+	if len(pending) != 2 || pending[0] != "one" || pending[1] != "two" {
+		t.Errorf("AddPlayer failed, expected %v, got %v", []string{"one", "two"}, pending)
+	}
+}
+
+func TestRemovePlayer(t *testing.T) {
+	store := NewMemoryMatchStore()
+	store.AddPlayer("one", 100)
+	store.RemovePlayer("one")
+	pending, _ := store.GetPendingPlayers()
+	if len(pending) != 0 {
+		t.Errorf("RemovePlayer failed, expected %v, got %v", 0, len(pending))
+	}
+	if _, err := store.GetPlayerElo("one"); err != ErrNotFound {
+		t.Errorf("GetPlayerElo failed, expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestElo(t *testing.T) {
+	store := NewMemoryMatchStore()
+	store.AddPlayer("low", 10)
+	store.AddPlayer("mid", 100)
+	store.AddPlayer("high", 1000)
+	players, _ := store.Elo(50, 60)
+	if len(players) != 2 || players[0] != "low" || players[1] != "mid" {
+		t.Errorf("Elo failed, expected %v, got %v", []string{"low", "mid"}, players)
+	}
+}
+
+func TestListenForMatches(t *testing.T) {
+	store := NewMemoryMatchStore()
+	found := make(chan string, 1)
+	go store.ListenForMatches(context.Background(), "one", func(match string) { found <- match })
+
+	// wait for the listener to subscribe
+	for {
+		store.mu.Lock()
+		subscribed := len(store.subscribers) > 0
+		store.mu.Unlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	store.PublishMatch(`["two","three"]`)
+	store.PublishMatch(`["one","two"]`)
+	select {
+	case match := <-found:
+		if match != `["one","two"]` {
+			t.Errorf("ListenForMatches failed, expected %v, got %v", `["one","two"]`, match)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("ListenForMatches failed, expected %v, got %v", "a match", "nothing")
+	}
+}
+
+func TestListenForMatchesStopsWithContext(t *testing.T) {
+	store := NewMemoryMatchStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		store.ListenForMatches(ctx, "one", func(string) {})
+		close(stopped)
+	}()
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Errorf("ListenForMatches failed, expected %v, got %v", "to stop", "still listening")
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.subscribers) != 0 {
+		t.Errorf("ListenForMatches failed, expected %v, got %v", 0, len(store.subscribers))
+	}
+}
+
+func TestPublishMatchWaitsForSlowListener(t *testing.T) {
+	store := NewMemoryMatchStore()
+	slow := &subscriber{playerId: "one", matches: make(chan string), stopped: make(chan struct{})}
+	other := &subscriber{playerId: "three", matches: make(chan string), stopped: make(chan struct{})}
+	store.subscribers[0] = slow
+	store.subscribers[1] = other
+
+	published := make(chan struct{})
+	go func() {
+		store.PublishMatch(`["one","two"]`)
+		close(published)
+	}()
+	select {
+	case <-published:
+		t.Fatalf("PublishMatch failed, expected %v, got %v", "to wait for the listener", "a dropped match")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if match := <-slow.matches; match != `["one","two"]` {
+		t.Errorf("PublishMatch failed, expected %v, got %v", `["one","two"]`, match)
+	}
+	<-published
+
+	// a listener that stopped no longer holds up the publisher
+	close(slow.stopped)
+	store.PublishMatch(`["one","two"]`)
+}
+
+func TestClaimMatch(t *testing.T) {
+	store := NewMemoryMatchStore()
+	store.AddPlayer("one", 100)
+	store.AddPlayer("two", 100)
+	store.AddPlayer("three", 100)
+
+	if claimed, _ := store.ClaimMatch("one", "two"); !claimed {
+		t.Errorf("ClaimMatch failed, expected %v, got %v", true, claimed)
+	}
+	// "two" is already claimed so "three" must stay in the queue
+	if claimed, _ := store.ClaimMatch("three", "two"); claimed {
+		t.Errorf("ClaimMatch failed, expected %v, got %v", false, claimed)
+	}
+	pending, _ := store.GetPendingPlayers()
+	if len(pending) != 1 || pending[0] != "three" {
+		t.Errorf("ClaimMatch failed, expected %v, got %v", []string{"three"}, pending)
+	}
+}
+
+func TestAcquireLease(t *testing.T) {
+	store := NewMemoryMatchStore()
+	if acquired, _ := store.AcquireLease("matcher", "a", time.Minute); !acquired {
+		t.Errorf("AcquireLease failed, expected %v, got %v", true, acquired)
+	}
+	if acquired, _ := store.AcquireLease("matcher", "b", time.Minute); acquired {
+		t.Errorf("AcquireLease failed, expected %v, got %v", false, acquired)
+	}
+	if acquired, _ := store.AcquireLease("matcher", "a", time.Minute); !acquired {
+		t.Errorf("AcquireLease renew failed, expected %v, got %v", true, acquired)
+	}
+	store.ReleaseLease("matcher", "a")
+	if acquired, _ := store.AcquireLease("matcher", "b", time.Minute); !acquired {
+		t.Errorf("AcquireLease after release failed, expected %v, got %v", true, acquired)
+	}
+}
+
+func TestAcquireExpiredLease(t *testing.T) {
+	store := NewMemoryMatchStore()
+	store.AcquireLease("matcher", "a", -time.Second)
+	if acquired, _ := store.AcquireLease("matcher", "b", time.Minute); !acquired {
+		t.Errorf("AcquireLease failed, expected %v, got %v", true, acquired)
+	}
+}
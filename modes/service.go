@@ -0,0 +1,106 @@
+// Package modes provides the game modes a game can be played in
+package modes
+
+import (
+	"drbh/partita/collision"
+	"drbh/partita/game"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultMode is used when a game is created without naming a mode
+const DefaultMode = "snake"
+
+// factories creates a fresh mode for every game by name
+var factories = map[string]func() game.GameMode{
+	"snake": func() game.GameMode {
+		return NewSnakeMode()
+	},
+	"zen": func() game.GameMode {
+		return NewZenMode(DefaultZenDuration)
+	},
+}
+
+// New returns a new instance of the named mode, or the default mode for an empty name
+func New(name string) (game.GameMode, error) {
+	if name == "" {
+		name = DefaultMode
+	}
+	factory, ok := factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown game mode %q", name)
+	}
+	return factory(), nil
+}
+
+// Names returns the names of every mode in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Headings a player is turned to when hitting the boundary
+const frontFacing = 2 * math.Pi
+const leftFacing = -math.Pi / 2
+const backFacing = frontFacing + math.Pi
+const rightFacing = frontFacing + math.Pi/2
+
+// calculateNextPosition calculates the next position of a player
+func calculateNextPosition(player *game.Player, config game.GameConfig) (float64, float64) {
+	nextX := math.Round((player.X+math.Sin(player.Rotation)*config.Step())*10000) / 10000
+	nextZ := math.Round((player.Z+math.Cos(player.Rotation)*config.Step())*10000) / 10000
+	return nextX, nextZ
+}
+
+// checkBoundaryCollision checks if a player hits the boundary and reverses its direction
+func checkBoundaryCollision(player *game.Player, config game.GameConfig) {
+	boundary := config.ArenaSize
+	if math.Abs(player.X) > boundary {
+		if player.X > 0 {
+			player.Rotation = leftFacing
+		} else {
+			player.Rotation = rightFacing
+		}
+		if player.X > 0 {
+			player.X = boundary
+		} else {
+			player.X = -boundary
+		}
+	}
+	if math.Abs(player.Z) > boundary {
+		if player.Z > 0 {
+			player.Rotation = backFacing
+		} else {
+			player.Rotation = frontFacing
+		}
+		if player.Z > 0 {
+			player.Z = boundary
+		} else {
+			player.Z = -boundary
+		}
+	}
+}
+
+// rotate applies a rotation input, the only input both modes understand
+func rotate(player *game.Player, input game.Input) error {
+	if input.Rotation == nil {
+		return game.ErrUnsupportedInput
+	}
+	player.Rotation = *input.Rotation
+	return nil
+}
+
+// sortedColliders returns the colliders of a set of hits in a stable order
+func sortedColliders(hits map[string]collision.Hit) []string {
+	names := make([]string, 0, len(hits))
+	for name := range hits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,208 @@
+package modes
+
+import (
+	"drbh/partita/collision"
+	"drbh/partita/game"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	mode, err := New("")
+	if err != nil || mode.Name() != DefaultMode {
+		t.Errorf("New failed, expected %v, got %v", DefaultMode, mode)
+	}
+	if _, err := New("chess"); err == nil {
+		t.Errorf("New failed, expected %v, got %v", "an error", nil)
+	}
+	if names := Names(); len(names) != 2 || names[0] != "snake" || names[1] != "zen" {
+		t.Errorf("Names failed, expected %v, got %v", []string{"snake", "zen"}, names)
+	}
+}
+
+func TestCheckBoundaryCollision(t *testing.T) {
+	player := &game.Player{X: 9, Z: 0, Rotation: math.Pi / 2}
+	checkBoundaryCollision(player, game.DefaultGameConfig())
+	if player.X != 8 || player.Rotation != leftFacing {
+		t.Errorf("checkBoundaryCollision failed, expected %v, got %v", []float64{8, leftFacing}, []float64{player.X, player.Rotation})
+	}
+}
+
+func TestSnakeModeStep(t *testing.T) {
+	g := game.NewGame(game.DefaultLifecycleConfig())
+	g.Mode = NewSnakeMode()
+	g.Players["a"] = &game.Player{ID: "a", Name: "a", PathPoints: []game.PathPoint{{}}}
+
+	rotation := math.Pi / 2
+	if err := g.Mode.HandleInput(g, g.Players["a"], game.Input{Rotation: &rotation}); err != nil {
+		t.Fatalf("HandleInput failed, expected %v, got %v", nil, err)
+	}
+	g.Mode.Step(g, time.Now())
+	player := g.Players["a"]
+	if player.X <= 0 || len(player.PathPoints) != 2 {
+		t.Errorf("Step failed, expected %v, got %v", "a move to the right with a new path point", player)
+	}
+}
+
+func TestSnakeModeScoresTrailCrossings(t *testing.T) {
+	g := game.NewGame(game.DefaultLifecycleConfig())
+	g.Mode = NewSnakeMode()
+	// a turned once and its trail runs across the path of b
+	g.Players["a"] = &game.Player{ID: "a", X: 2, Z: 2, Rotation: math.Pi / 2, LastRotation: math.Pi / 2,
+		PathPoints: []game.PathPoint{{X: 0, Z: -2}, {X: 0, Z: 2}}}
+	g.Players["b"] = &game.Player{ID: "b", X: -0.03, Rotation: math.Pi / 2, LastRotation: math.Pi / 2,
+		PathPoints: []game.PathPoint{{X: -1, Z: -1}, {X: -1, Z: 0}}}
+
+	g.Mode.Step(g, time.Now())
+	if len(g.Collisions) != 1 || g.Collisions[0].Collider != "b" || g.Collisions[0].Victim != "a" {
+		t.Fatalf("Step failed, expected %v, got %v", "b to cross the trail of a", g.Collisions)
+	}
+	if at := g.Collisions[0].At; at == nil || math.Abs(at.X) > 1e-9 || math.Abs(at.Z) > 1e-9 {
+		t.Errorf("Step failed, expected %v, got %v", game.PathPoint{}, at)
+	}
+	// the respawned trail of a replaces the indexed one on the next step
+	g.Mode.Step(g, time.Now())
+	if _, ok := g.World.TrailHit(collision.NewSegmentFromCoords(-1, 0, 1, 0), "a"); ok {
+		t.Errorf("Step failed, expected %v, got %v", "the old trail of a to be gone", g.World.Trails())
+	}
+}
+
+func TestSnakeModeSweepsWideHeads(t *testing.T) {
+	for _, radius := range []float64{0, game.DefaultPlayerRadius} {
+		g := game.NewGame(game.DefaultLifecycleConfig())
+		g.Mode = NewSnakeMode()
+		// b runs alongside the trail of a, closer than their widths but never crossing it
+		g.Players["a"] = &game.Player{ID: "a", X: 2, Z: 2, Rotation: math.Pi / 2, LastRotation: math.Pi / 2, Radius: radius,
+			PathPoints: []game.PathPoint{{X: 0, Z: -2}, {X: 0, Z: 2}}}
+		g.Players["b"] = &game.Player{ID: "b", X: -0.03, Z: -1, Rotation: 0, LastRotation: 0, Radius: radius,
+			PathPoints: []game.PathPoint{{X: -1, Z: -1}, {X: -0.03, Z: -1}}}
+
+		g.Mode.Step(g, time.Now())
+		if hit := len(g.Collisions) == 1; hit != (radius > 0) {
+			t.Errorf("Step failed with radius %v, expected %v, got %v", radius, radius > 0, g.Collisions)
+		}
+	}
+}
+
+func TestSnakeGamesTickInParallel(t *testing.T) {
+	service := game.NewGameService()
+	service.ManualTicks = true
+	keys := []string{"first", "second", "third", "fourth"}
+	for _, key := range keys {
+		g := game.NewGame(game.DefaultLifecycleConfig())
+		g.Lifecycle.CountdownDuration = 0
+		g.Mode = NewSnakeMode()
+		g.World = collision.ProvideWorldFactory()()
+		service.AddGame(key, g)
+		for _, name := range []string{"a", "b"} {
+			service.JoinGame(key, service.NewPlayer(name))
+		}
+	}
+
+	// every game checks its trails in its own world, so the loops never wait on each other
+	start := time.Now()
+	var wait sync.WaitGroup
+	for _, key := range keys {
+		wait.Add(1)
+		go func(key string) {
+			defer wait.Done()
+			for tick := 0; tick < 100; tick++ {
+				if tick == 5 {
+					// both players turn so their trails reach the world
+					service.WithGame(key, func(g *game.Game) {
+						for _, player := range g.Players {
+							player.Rotation = math.Pi / 2
+						}
+					})
+				}
+				service.Tick(key, start.Add(time.Duration(tick)*game.DefaultGameConfig().TickInterval()))
+			}
+		}(key)
+	}
+	wait.Wait()
+
+	for _, key := range keys {
+		var trails []string
+		service.WithGame(key, func(g *game.Game) {
+			trails = g.World.Synced()
+		})
+		if len(trails) != 2 {
+			t.Errorf("Tick failed, expected %v, got %v", 2, trails)
+		}
+	}
+}
+
+func TestZenModeFinishes(t *testing.T) {
+	service := game.NewGameService()
+	service.ManualTicks = true
+	collisions := 0
+	service.OnTick(func(event game.TickEvent) {
+		collisions += len(event.Collisions)
+	})
+	g := game.NewGame(game.DefaultLifecycleConfig())
+	g.Lifecycle.CountdownDuration = 0
+	g.Mode = NewZenMode(time.Second)
+	service.AddGame("zen", g)
+	service.JoinGame("zen", service.NewPlayer("zen-player"))
+
+	now := time.Now()
+	service.Tick("zen", now)
+	if !g.IsRunning() {
+		t.Fatalf("Tick failed, expected %v, got %v", game.PhaseRunning, g.State)
+	}
+	if collisions != 0 {
+		t.Errorf("Tick failed, expected %v, got %v", 0, collisions)
+	}
+	service.Tick("zen", now.Add(time.Second))
+	if g.State != game.PhaseFinished {
+		t.Errorf("Tick failed, expected %v, got %v", game.PhaseFinished, g.State)
+	}
+}
+
+// playSeededGame plays a snake game with scripted inputs and returns its snapshots
+func playSeededGame(seed int64, start time.Time) []string {
+	service := game.NewGameService()
+	service.ManualTicks = true
+	var snapshots []string
+	service.OnTick(func(event game.TickEvent) {
+		snapshots = append(snapshots, event.Snapshot)
+	})
+
+	g := game.NewGame(game.DefaultLifecycleConfig())
+	g.Lifecycle.CountdownDuration = 0
+	g.Seed = seed
+	g.Mode = NewSnakeMode()
+	service.AddGame("seeded", g)
+	for _, name := range []string{"a", "b", "c"} {
+		service.JoinGame("seeded", service.NewPlayer(name))
+	}
+
+	step := game.DefaultGameConfig().TickInterval()
+	for tick := 0; tick < 200; tick++ {
+		if tick%15 == 0 {
+			rotation := float64(tick/15%4) * math.Pi / 2
+			service.HandleInput(&game.Player{ID: []string{"a", "b", "c"}[tick%3]}, game.Input{Rotation: &rotation})
+		}
+		service.Tick("seeded", start.Add(time.Duration(tick)*step))
+	}
+	return snapshots
+}
+
+func TestSeededGamesReplay(t *testing.T) {
+	start := time.Now()
+	first, second := playSeededGame(42, start), playSeededGame(42, start)
+	if len(first) != len(second) {
+		t.Fatalf("Tick failed, expected %v, got %v", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Tick failed, expected %v, got %v", first[i], second[i])
+		}
+	}
+	other := playSeededGame(43, start)
+	if other[len(other)-1] == first[len(first)-1] {
+		t.Errorf("Tick failed, expected %v, got %v", "another seed to play differently", other[len(other)-1])
+	}
+}
@@ -0,0 +1,182 @@
+package modes
+
+import (
+	"drbh/partita/collision"
+	"drbh/partita/game"
+	"log"
+	"time"
+)
+
+// SnakeMode is the original game: players leave a trail behind them and running
+// into a trail scores a kill for the collider. The trails are kept in the
+// game's collision world.
+type SnakeMode struct{}
+
+// NewSnakeMode creates a snake mode
+func NewSnakeMode() *SnakeMode {
+	return &SnakeMode{}
+}
+
+func (m *SnakeMode) Name() string {
+	return "snake"
+}
+
+// Init does nothing, respawning the players already cleared their trails
+func (m *SnakeMode) Init(g *game.Game, now time.Time) {}
+
+func (m *SnakeMode) HandleInput(g *game.Game, player *game.Player, input game.Input) error {
+	return rotate(player, input)
+}
+
+func (m *SnakeMode) Step(g *game.Game, now time.Time) []game.Collision {
+	// trails of players that left are dropped
+	world := g.CollisionWorld()
+	for _, id := range world.Synced() {
+		if _, ok := g.Players[id]; !ok {
+			world.RemoveTrail(id)
+		}
+	}
+
+	var collisions []game.Collision
+	for _, name := range g.PlayerIDs() {
+		player := g.Players[name]
+		// a collision may have decided the round during this tick
+		if g.RoundOver() {
+			break
+		}
+		// eliminated players sit out the rest of the round
+		if !g.IsAlive(player.ID) {
+			continue
+		}
+		collisions = append(collisions, m.processPlayerMovement(player, g, now)...)
+	}
+	return collisions
+}
+
+// IsFinished is always false, snake games end by their scoring rules
+func (m *SnakeMode) IsFinished(g *game.Game, now time.Time) bool {
+	return false
+}
+
+func (m *SnakeMode) Serialize(g *game.Game) interface{} {
+	return nil
+}
+
+// processPlayerMovement processes the movement of a single player
+func (m *SnakeMode) processPlayerMovement(player *game.Player, g *game.Game, now time.Time) []game.Collision {
+	originalX, originalY, originalZ := player.X, player.Y, player.Z
+
+	// move the player
+	nextX, nextZ := calculateNextPosition(player, g.Config)
+
+	var collisions []game.Collision
+	if len(player.PathPoints) > 1 {
+		playersWhoInitatedCollision := m.checkPlayerCollision(player, g)
+
+		eliminated := false
+		for _, collider := range sortedColliders(playersWhoInitatedCollision) {
+			hit := playersWhoInitatedCollision[collider]
+			log.Printf("%v has collided with %v at %v\n", player.ID, collider, hit.Point)
+			at := game.PathPoint{X: hit.Point.X, Y: originalY, Z: hit.Point.Y}
+			eliminated = g.ScoreCollisionAt(collider, player.ID, at, now) || eliminated
+			collisions = append(collisions, g.Collisions[len(g.Collisions)-1])
+		}
+
+		if len(playersWhoInitatedCollision) > 0 {
+			// eliminated players stay where they were hit until the next round
+			if eliminated {
+				return collisions
+			}
+			m.resetPlayerPosition(player, g)
+		}
+	}
+
+	// if player has just spawned, don't add a new path point
+	if player.JustSpawned {
+		player.JustSpawned = false
+		log.Printf("🐘🐘🐘 Player has a total of %v path points\n", len(player.PathPoints))
+		return collisions
+	}
+
+	// move the player
+	player.X = nextX
+	player.Z = nextZ
+
+	// check if player hits the boundary and reverse its direction
+	checkBoundaryCollision(player, g.Config)
+
+	// only add a new path point if the player has turned
+	if player.Rotation != player.LastRotation {
+		player.PathPoints = append(player.PathPoints, game.PathPoint{
+			X: originalX,
+			Y: originalY,
+			Z: originalZ,
+		})
+		log.Printf("Player has a total of %v path points\n", len(player.PathPoints))
+	}
+
+	player.LastRotation = player.Rotation
+	return collisions
+}
+
+// checkPlayerCollision returns the players whose next move crosses the trail of
+// player, with where each of them first crosses it
+func (m *SnakeMode) checkPlayerCollision(player *game.Player, g *game.Game) map[string]collision.Hit {
+	playersToReset := make(map[string]collision.Hit)
+	if len(player.PathPoints) < 2 {
+		return playersToReset
+	}
+
+	// the turns of the trail are indexed, the stretch since the last turn is
+	// checked on its own since it grows every tick
+	world := g.CollisionWorld()
+	g.SyncTrail(player)
+	last := player.PathPoints[len(player.PathPoints)-1]
+	stretch := collision.NewSegmentFromCoords(last.X, last.Z, player.X, player.Z)
+
+	for _, name := range g.PlayerIDs() {
+		otherPlayer := g.Players[name]
+		if player.ID == otherPlayer.ID || len(otherPlayer.PathPoints) < 2 {
+			continue
+		}
+		if !g.IsAlive(otherPlayer.ID) {
+			continue
+		}
+		otherPlayerNextX, otherPlayerNextZ := calculateNextPosition(otherPlayer, g.Config)
+		otherLast := otherPlayer.PathPoints[len(otherPlayer.PathPoints)-1]
+		head := collision.NewSegmentFromCoords(otherLast.X, otherLast.Z, otherPlayerNextX, otherPlayerNextZ)
+		// the head is swept along its path with the width of both players, so
+		// it touches the trail however far it moves in a tick
+		radius := otherPlayer.Radius + player.Radius
+		// the head always touches its own trail, only hits on player's trail count
+		hit, ok := world.SweptTrailHit(head, radius, player.ID)
+		if stretchHit, crosses := world.SweptIntersection(head, radius, stretch); crosses && (!ok || stretchHit.T < hit.T) {
+			stretchHit.Owner = player.ID
+			hit, ok = stretchHit, true
+		}
+		if ok {
+			playersToReset[otherPlayer.ID] = hit
+		}
+	}
+
+	return playersToReset
+}
+
+// resetPlayerPosition resets the position of a player after a collision
+func (m *SnakeMode) resetPlayerPosition(player *game.Player, g *game.Game) {
+
+	// random player position
+	x, z := g.Config.SpawnPosition(g.Rand())
+
+	// reset player's path points
+	player.PathPoints = []game.PathPoint{
+		{X: x, Y: 0.0, Z: z},
+		{X: x, Y: 0.0, Z: z},
+	}
+	player.X = x
+	player.Y = 0
+	player.Z = z
+	player.Rotation = frontFacing
+	player.LastRotation = frontFacing
+	player.JustSpawned = true
+}
@@ -0,0 +1,67 @@
+package modes
+
+import (
+	"drbh/partita/game"
+	"time"
+)
+
+// DefaultZenDuration is how long a zen game lasts
+const DefaultZenDuration = 60 * time.Second
+
+// ZenMode lets players glide around the arena without trails or collisions until
+// the time is up, it mostly exists to prove modes are interchangeable
+type ZenMode struct {
+	Duration time.Duration
+	// startedAt is when the first round started
+	startedAt time.Time
+	// lastStep is the time of the last step, the remaining time is counted from it
+	lastStep time.Time
+}
+
+// NewZenMode creates a zen mode that ends after duration
+func NewZenMode(duration time.Duration) *ZenMode {
+	return &ZenMode{Duration: duration}
+}
+
+func (m *ZenMode) Name() string {
+	return "zen"
+}
+
+func (m *ZenMode) Init(g *game.Game, now time.Time) {
+	if m.startedAt.IsZero() {
+		m.startedAt = now
+	}
+	m.lastStep = now
+}
+
+func (m *ZenMode) HandleInput(g *game.Game, player *game.Player, input game.Input) error {
+	return rotate(player, input)
+}
+
+// Step moves every player and bounces them off the boundary
+func (m *ZenMode) Step(g *game.Game, now time.Time) []game.Collision {
+	m.lastStep = now
+	for _, name := range g.PlayerIDs() {
+		player := g.Players[name]
+		player.X, player.Z = calculateNextPosition(player, g.Config)
+		checkBoundaryCollision(player, g.Config)
+		player.LastRotation = player.Rotation
+	}
+	return nil
+}
+
+func (m *ZenMode) IsFinished(g *game.Game, now time.Time) bool {
+	return !m.startedAt.IsZero() && now.Sub(m.startedAt) >= m.Duration
+}
+
+// Serialize tells clients how much time is left in milliseconds
+func (m *ZenMode) Serialize(g *game.Game) interface{} {
+	remaining := m.Duration - m.lastStep.Sub(m.startedAt)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return map[string]interface{}{
+		"mode":      m.Name(),
+		"remaining": remaining.Milliseconds(),
+	}
+}
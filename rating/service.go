@@ -0,0 +1,186 @@
+// Package rating updates player ratings from game placements with Elo or Glicko-2
+package rating
+
+import (
+	"math"
+)
+
+// Defaults for a player without any rated games, on the Glicko scale
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+)
+
+// Rating is a player's skill estimate. Elo only uses Value, Glicko-2 also tracks
+// the rating deviation and volatility.
+type Rating struct {
+	Value      float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// NewRating returns the rating of a new player
+func NewRating() Rating {
+	return Rating{
+		Value:      DefaultRating,
+		Deviation:  DefaultDeviation,
+		Volatility: DefaultVolatility,
+	}
+}
+
+// Calculator turns the placements of a finished game into new ratings.
+// placements is ordered from winner to last place and every player in it must
+// have an entry in ratings.
+type Calculator interface {
+	Update(ratings map[string]Rating, placements []string) map[string]Rating
+}
+
+// NewCalculator returns the calculator for a system name, "glicko2" or "elo" (default)
+func NewCalculator(system string) Calculator {
+	if system == "glicko2" {
+		return Glicko2{Tau: 0.5}
+	}
+	return Elo{K: 32}
+}
+
+// outcome is a single pairwise result from the point of view of one player
+type outcome struct {
+	opponent Rating
+	score    float64
+}
+
+// pairwiseOutcomes splits placements into one win or loss per pair of players
+func pairwiseOutcomes(ratings map[string]Rating, placements []string) map[string][]outcome {
+	outcomes := make(map[string][]outcome, len(placements))
+	for i, player := range placements {
+		for j, opponent := range placements {
+			if i == j {
+				continue
+			}
+			score := 0.0
+			if i < j {
+				score = 1.0
+			}
+			outcomes[player] = append(outcomes[player], outcome{opponent: ratings[opponent], score: score})
+		}
+	}
+	return outcomes
+}
+
+// Elo is the classic Elo system, multiplayer games are scored pairwise with K
+// split across opponents
+type Elo struct {
+	K float64
+}
+
+func (e Elo) Update(ratings map[string]Rating, placements []string) map[string]Rating {
+	updated := make(map[string]Rating, len(placements))
+	for player, outcomes := range pairwiseOutcomes(ratings, placements) {
+		current := ratings[player]
+		delta := 0.0
+		for _, o := range outcomes {
+			expected := 1.0 / (1.0 + math.Pow(10, (o.opponent.Value-current.Value)/400))
+			delta += o.score - expected
+		}
+		current.Value += e.K * delta / float64(len(outcomes))
+		updated[player] = current
+	}
+	return updated
+}
+
+// glicko2Scale converts between the Glicko and Glicko-2 scales
+const glicko2Scale = 173.7178
+
+// glicko2Epsilon is the convergence tolerance of the volatility iteration
+const glicko2Epsilon = 0.000001
+
+// Glicko2 is Glickman's Glicko-2 system, every game is treated as a rating period
+// in which each pair of players played once
+type Glicko2 struct {
+	// Tau constrains the change in volatility over time, typically 0.3 to 1.2
+	Tau float64
+}
+
+func (g Glicko2) Update(ratings map[string]Rating, placements []string) map[string]Rating {
+	updated := make(map[string]Rating, len(placements))
+	for player, outcomes := range pairwiseOutcomes(ratings, placements) {
+		updated[player] = g.rate(ratings[player], outcomes)
+	}
+	return updated
+}
+
+func (g Glicko2) rate(current Rating, outcomes []outcome) Rating {
+	mu := (current.Value - DefaultRating) / glicko2Scale
+	phi := current.Deviation / glicko2Scale
+	sigma := current.Volatility
+
+	if len(outcomes) == 0 {
+		current.Deviation = math.Sqrt(phi*phi+sigma*sigma) * glicko2Scale
+		return current
+	}
+
+	// estimated variance and improvement from the game outcomes
+	varianceInverse, improvement := 0.0, 0.0
+	for _, o := range outcomes {
+		muJ := (o.opponent.Value - DefaultRating) / glicko2Scale
+		phiJ := o.opponent.Deviation / glicko2Scale
+		gPhi := glicko2G(phiJ)
+		expected := 1.0 / (1.0 + math.Exp(-gPhi*(mu-muJ)))
+		varianceInverse += gPhi * gPhi * expected * (1 - expected)
+		improvement += gPhi * (o.score - expected)
+	}
+	v := 1.0 / varianceInverse
+	delta := v * improvement
+
+	sigma = g.volatility(phi, sigma, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1.0 / math.Sqrt(1.0/(phiStar*phiStar)+1.0/v)
+	mu = mu + phi*phi*improvement
+
+	return Rating{
+		Value:      mu*glicko2Scale + DefaultRating,
+		Deviation:  phi * glicko2Scale,
+		Volatility: sigma,
+	}
+}
+
+// volatility finds the new volatility with the Illinois algorithm
+func (g Glicko2) volatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	tau := g.Tau
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		return ex*(delta*delta-phi*phi-v-ex)/(2*math.Pow(phi*phi+v+ex, 2)) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glicko2Epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA = fA / 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+func glicko2G(phi float64) float64 {
+	return 1.0 / math.Sqrt(1.0+3.0*phi*phi/(math.Pi*math.Pi))
+}
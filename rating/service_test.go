@@ -0,0 +1,43 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+// the worked example from Glickman's "Example of the Glicko-2 system"
+func TestGlicko2Example(t *testing.T) {
+	player := Rating{Value: 1500, Deviation: 200, Volatility: 0.06}
+	outcomes := []outcome{
+		{opponent: Rating{Value: 1400, Deviation: 30}, score: 1},
+		{opponent: Rating{Value: 1550, Deviation: 100}, score: 0},
+		{opponent: Rating{Value: 1700, Deviation: 300}, score: 0},
+	}
+	updated := Glicko2{Tau: 0.5}.rate(player, outcomes)
+	if !near(updated.Value, 1464.06, 0.01) || !near(updated.Deviation, 151.52, 0.01) || !near(updated.Volatility, 0.05999, 0.00001) {
+		t.Errorf("Glicko2 failed, expected %v, got %v", "1464.06/151.52/0.05999", updated)
+	}
+}
+
+func TestGlicko2Placements(t *testing.T) {
+	ratings := map[string]Rating{"winner": NewRating(), "loser": NewRating()}
+	updated := Glicko2{Tau: 0.5}.Update(ratings, []string{"winner", "loser"})
+	if updated["winner"].Value <= DefaultRating || updated["loser"].Value >= DefaultRating {
+		t.Errorf("Glicko2 failed, expected %v, got %v", "winner up and loser down", updated)
+	}
+	if updated["winner"].Deviation >= DefaultDeviation {
+		t.Errorf("Glicko2 failed, expected %v, got %v", "smaller deviation", updated["winner"].Deviation)
+	}
+}
+
+func TestEloPlacements(t *testing.T) {
+	ratings := map[string]Rating{"a": NewRating(), "b": NewRating(), "c": NewRating()}
+	updated := Elo{K: 32}.Update(ratings, []string{"a", "b", "c"})
+	if !near(updated["a"].Value, 1516, 0.001) || !near(updated["b"].Value, 1500, 0.001) || !near(updated["c"].Value, 1484, 0.001) {
+		t.Errorf("Elo failed, expected %v, got %v", "1516/1500/1484", updated)
+	}
+}
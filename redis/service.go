@@ -2,9 +2,11 @@ package redis
 
 import (
 	"context"
+	"drbh/partita/rating"
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 	"sync"
 	"time"
 
@@ -43,6 +45,11 @@ func GetMyRedisServiceInstance() *MyRedisService {
 	return myRedisServiceInstance
 }
 
+// Ping checks that the Redis connection is alive
+func (s *MyRedisService) Ping() error {
+	return s.Rdb.Ping(s.Ctx).Err()
+}
+
 // Elo returns a list of players within a certain Elo range
 func (s *MyRedisService) Elo(playerElo float64, eloThreshold float64) ([]string, error) {
 
@@ -105,12 +112,25 @@ func (s *MyRedisService) AddPlayer(playerId string, playerElo float64) error {
 		return err
 	}
 
+	// a player queueing again moves to the front instead of being queued twice
+	err = s.Rdb.LRem(s.Ctx, "pending_players", 0, playerId).Err()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
 	err = s.Rdb.LPush(s.Ctx, "pending_players", playerId).Err()
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 
+	err = s.Rdb.HSet(s.Ctx, "enqueued_at", playerId, time.Now().UnixMilli()).Err()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
 	return nil
 }
 
@@ -128,9 +148,25 @@ func (s *MyRedisService) RemovePlayer(playerId string) error {
 		return err
 	}
 
+	err = s.Rdb.HDel(s.Ctx, "enqueued_at", playerId).Err()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
 	return nil
 }
 
+// GetEnqueuedAt returns when a player joined the pending players list
+func (s *MyRedisService) GetEnqueuedAt(playerId string) (time.Time, error) {
+	enqueuedAt, err := s.Rdb.HGet(s.Ctx, "enqueued_at", playerId).Int64()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.UnixMilli(enqueuedAt), nil
+}
+
 // UpdateLastPlayedWith updates the last played with list for a given player
 func (s *MyRedisService) UpdateLastPlayedWith(playerId, matchId string) error {
 	err := s.Rdb.HSet(s.Ctx, "last_played_with:"+playerId, matchId, time.Now().Unix()).Err()
@@ -153,11 +189,137 @@ func (s *MyRedisService) PublishMatch(match string) error {
 	return nil
 }
 
-// ListenForMatches listens for matches and call the callback function when a match is received
-func (s *MyRedisService) ListenForMatches(playerId string, callback func(string)) {
-	pubsub := s.Rdb.Subscribe(s.Ctx, "matches")
+// GetRating returns the stored rating of a player, or the default rating of a new player
+func (s *MyRedisService) GetRating(playerId string) (rating.Rating, error) {
+	fields, err := s.Rdb.HGetAll(s.Ctx, "rating:"+playerId).Result()
+	if err != nil {
+		log.Println(err)
+		return rating.NewRating(), err
+	}
+	if len(fields) == 0 {
+		return rating.NewRating(), nil
+	}
+
+	playerRating := rating.NewRating()
+	for field, target := range map[string]*float64{
+		"rating":     &playerRating.Value,
+		"deviation":  &playerRating.Deviation,
+		"volatility": &playerRating.Volatility,
+	} {
+		if value, ok := fields[field]; ok {
+			if *target, err = strconv.ParseFloat(value, 64); err != nil {
+				log.Println(err)
+				return rating.NewRating(), err
+			}
+		}
+	}
+	return playerRating, nil
+}
+
+// SetRating stores the rating of a player
+func (s *MyRedisService) SetRating(playerId string, playerRating rating.Rating) error {
+	err := s.Rdb.HSet(s.Ctx, "rating:"+playerId,
+		"rating", playerRating.Value,
+		"deviation", playerRating.Deviation,
+		"volatility", playerRating.Volatility,
+	).Err()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// claimMatchScript removes both players from the queue only if both are still pending
+var claimMatchScript = redis.NewScript(`
+local pending = redis.call('LRANGE', KEYS[1], 0, -1)
+local first, second = false, false
+for _, player in ipairs(pending) do
+	if player == ARGV[1] then first = true end
+	if player == ARGV[2] then second = true end
+end
+if not (first and second) then
+	return 0
+end
+redis.call('LREM', KEYS[1], 0, ARGV[1])
+redis.call('LREM', KEYS[1], 0, ARGV[2])
+redis.call('ZREM', KEYS[2], ARGV[1], ARGV[2])
+redis.call('HDEL', KEYS[3], ARGV[1], ARGV[2])
+return 1
+`)
+
+// ClaimMatch atomically removes two players from the pending queue and Elo scores,
+// it returns false if either of them was already claimed
+func (s *MyRedisService) ClaimMatch(playerId, matchId string) (bool, error) {
+	claimed, err := claimMatchScript.Run(s.Ctx, s.Rdb, []string{"pending_players", "elo_scores", "enqueued_at"}, playerId, matchId).Int()
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return claimed == 1, nil
+}
+
+// acquireLeaseScript takes a free lease or renews one already held by the owner
+var acquireLeaseScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner == false then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if owner == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseLeaseScript deletes a lease only if it is held by the owner
+var releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// AcquireLease takes or renews the named lease for owner, it returns false while
+// another owner holds it
+func (s *MyRedisService) AcquireLease(name, owner string, ttl time.Duration) (bool, error) {
+	acquired, err := acquireLeaseScript.Run(s.Ctx, s.Rdb, []string{"lease:" + name}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	return acquired == 1, nil
+}
+
+// ReleaseLease gives up the named lease if owner holds it
+func (s *MyRedisService) ReleaseLease(name, owner string) error {
+	err := releaseLeaseScript.Run(s.Ctx, s.Rdb, []string{"lease:" + name}, owner).Err()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	return nil
+}
+
+// ListenForMatches listens for matches and call the callback function when a match
+// is received, it returns once ctx is done
+func (s *MyRedisService) ListenForMatches(ctx context.Context, playerId string, callback func(string)) {
+	pubsub := s.Rdb.Subscribe(ctx, "matches")
+	defer pubsub.Close()
 	ch := pubsub.Channel()
-	for msg := range ch {
+	for {
+		var msg *redis.Message
+		select {
+		case <-ctx.Done():
+			return
+		case received, ok := <-ch:
+			if !ok {
+				return
+			}
+			msg = received
+		}
 
 		log.Printf("\nReceived: %s\n", string(msg.Payload))
 
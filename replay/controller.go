@@ -0,0 +1,229 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// maxSpeed is the fastest a replay can be played
+const maxSpeed = 16
+
+type ReplayController struct {
+	ReplayService *ReplayService
+}
+
+func NewReplayController(replayService *ReplayService) ReplayController {
+	return ReplayController{
+		ReplayService: replayService,
+	}
+}
+
+// List returns the IDs of the recorded games
+func (e *ReplayController) List(c *fiber.Ctx) error {
+	ids, err := e.ReplayService.List()
+	if err != nil {
+		return err
+	}
+	return c.JSON(ids)
+}
+
+// HandleReplay streams a recorded game over a websocket in the snapshot format of
+// live games. The client controls playback with the "play", "pause", "speed" and
+// "seek" controls, see DecodeControl.
+func (e *ReplayController) HandleReplay(c *fiber.Ctx) error {
+	recording, err := e.ReplayService.Load(c.Params("id"))
+	if errors.Is(err, ErrUnknownReplay) {
+		return fiber.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	speed, err := parseSpeed(c.Query("speed", "normal"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	handler := func(conn *websocket.Conn) {
+		playback, err := e.ReplayService.NewPlayback(recording)
+		if err != nil {
+			log.Printf("Error starting replay %v: %v\n", recording.ID, err)
+			conn.Close()
+			return
+		}
+		defer playback.Close()
+
+		// the reader only forwards control messages, all writes happen on this goroutine
+		controls := make(chan []byte)
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				select {
+				case controls <- msg:
+				case <-time.After(time.Second):
+					log.Printf("Dropping replay control %q\n", msg)
+				}
+			}
+		}()
+
+		newStream(conn, recording, playback, speed).run(controls, closed)
+	}
+	return websocket.New(handler)(c)
+}
+
+// stream plays a recording to a single connection
+type stream struct {
+	conn      *websocket.Conn
+	recording *Recording
+	playback  *Playback
+	speed     float64
+	paused    bool
+	// snapshotEvery is how many ticks pass between two snapshots
+	snapshotEvery uint64
+}
+
+func newStream(conn *websocket.Conn, recording *Recording, playback *Playback, speed float64) *stream {
+	config := recording.Header.Config
+	snapshotEvery := uint64(1)
+	if config.SnapshotInterval() > config.TickInterval() {
+		snapshotEvery = uint64(config.SnapshotInterval() / config.TickInterval())
+	}
+	return &stream{
+		conn:          conn,
+		recording:     recording,
+		playback:      playback,
+		speed:         speed,
+		snapshotEvery: snapshotEvery,
+	}
+}
+
+// run plays the recording until the connection closes, a finished replay waits
+// for a seek
+func (s *stream) run(controls <-chan []byte, closed <-chan struct{}) {
+	if !s.sendStatus("replay") {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case control := <-controls:
+			if err := s.handleControl(control); err != nil {
+				s.send(map[string]interface{}{"command": "replayError", "message": err.Error()})
+				continue
+			}
+			ticker.Reset(s.interval())
+			if !s.sendStatus("replayStatus") || !s.sendSnapshot() {
+				return
+			}
+		case <-ticker.C:
+			if s.paused || s.playback.Ended() {
+				continue
+			}
+			s.playback.Step()
+			if s.playback.Tick()%s.snapshotEvery == 0 || s.playback.Ended() {
+				if !s.sendSnapshot() {
+					return
+				}
+			}
+			if s.playback.Ended() && !s.sendStatus("replayEnd") {
+				return
+			}
+		}
+	}
+}
+
+// interval is the wall clock time between two replayed ticks
+func (s *stream) interval() time.Duration {
+	return time.Duration(float64(s.recording.Header.Config.TickInterval()) / s.speed)
+}
+
+// handleControl decodes and applies a control message from the client
+func (s *stream) handleControl(msg []byte) error {
+	control, err := DecodeControl(msg)
+	if err != nil {
+		return err
+	}
+	switch control := control.(type) {
+	case *PlayControl:
+		s.paused = false
+	case *PauseControl:
+		s.paused = true
+	case *SpeedControl:
+		s.speed = *control.Speed
+	case *SeekControl:
+		return s.playback.Seek(*control.Tick)
+	}
+	return nil
+}
+
+// sendStatus tells the client where the replay is
+func (s *stream) sendStatus(command string) bool {
+	return s.send(map[string]interface{}{
+		"command": command,
+		"id":      s.recording.ID,
+		"gameKey": s.recording.Header.GameKey,
+		"config":  s.recording.Header.Config,
+		"tick":    s.playback.Tick(),
+		"endTick": s.recording.EndTick(),
+		"speed":   s.speed,
+		"paused":  s.paused,
+	})
+}
+
+// sendSnapshot sends the replayed game like a live snapshot
+func (s *stream) sendSnapshot() bool {
+	if s.playback.Snapshot() == "" {
+		return true
+	}
+	return s.write([]byte(s.playback.Snapshot()))
+}
+
+func (s *stream) send(payload map[string]interface{}) bool {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error marshalling %v payload: %v\n", payload["command"], err)
+		return true
+	}
+	return s.write(payloadBytes)
+}
+
+func (s *stream) write(message []byte) bool {
+	if err := s.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		log.Printf("Error writing replay: %v\n", err)
+		return false
+	}
+	return true
+}
+
+// parseSpeed reads a playback speed, either a factor or "slow", "normal" or "fast"
+func parseSpeed(value string) (float64, error) {
+	switch value {
+	case "slow":
+		return 0.5, nil
+	case "normal", "":
+		return 1, nil
+	case "fast":
+		return 2, nil
+	}
+	speed, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(speed) || speed <= 0 || speed > maxSpeed {
+		return 0, fmt.Errorf("invalid speed %q", value)
+	}
+	return speed, nil
+}
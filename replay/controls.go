@@ -0,0 +1,148 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// controlVersion is the envelope version replay controls are sent with. It
+// follows websocket.ProtocolVersion, the websocket package records games
+// through this one so it cannot be imported here.
+const controlVersion = 1
+
+// controlEnvelope is the versioned wrapper of a replay control, it has the
+// shape of the game protocol's envelope
+// e.g. {"v":1,"type":"seek","payload":{"tick":120}}
+type controlEnvelope struct {
+	V       int             `json:"v"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Control is implemented by every typed replay control
+type Control interface {
+	Validate() error
+}
+
+// PlayControl resumes the replay
+type PlayControl struct{}
+
+func (c *PlayControl) Validate() error { return nil }
+
+// PauseControl pauses the replay
+type PauseControl struct{}
+
+func (c *PauseControl) Validate() error { return nil }
+
+// SpeedControl changes how fast the replay plays, as a factor of real time
+type SpeedControl struct {
+	Speed *float64 `json:"speed"`
+}
+
+func (c *SpeedControl) Validate() error {
+	if c.Speed == nil {
+		return errors.New("speed is required")
+	}
+	if math.IsNaN(*c.Speed) || *c.Speed <= 0 || *c.Speed > maxSpeed {
+		return fmt.Errorf("speed must be above 0 and at most %v", maxSpeed)
+	}
+	return nil
+}
+
+// SeekControl jumps to a tick of the replay
+type SeekControl struct {
+	Tick *uint64 `json:"tick"`
+}
+
+func (c *SeekControl) Validate() error {
+	if c.Tick == nil {
+		return errors.New("tick is required")
+	}
+	return nil
+}
+
+// controlTypes creates the typed control of every known control type
+var controlTypes = map[string]func() Control{
+	"play":  func() Control { return &PlayControl{} },
+	"pause": func() Control { return &PauseControl{} },
+	"speed": func() Control { return &SpeedControl{} },
+	"seek":  func() Control { return &SeekControl{} },
+}
+
+// DecodeControl decodes and validates a replay control. Frames that do not look
+// like JSON are read in the legacy "play", "pause", "speed:<x>" and "seek:<tick>"
+// format.
+func DecodeControl(msg []byte) (Control, error) {
+	trimmed := bytes.TrimSpace(msg)
+	var control Control
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		decoded, err := decodeControlEnvelope(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		control = decoded
+	} else {
+		decoded, err := parseLegacyControl(string(trimmed))
+		if err != nil {
+			return nil, err
+		}
+		control = decoded
+	}
+	if err := control.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid replay control: %w", err)
+	}
+	return control, nil
+}
+
+// decodeControlEnvelope decodes a control sent in the versioned envelope
+func decodeControlEnvelope(msg []byte) (Control, error) {
+	var envelope controlEnvelope
+	if err := json.Unmarshal(msg, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid JSON envelope: %v", err)
+	}
+	if envelope.V != controlVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d", envelope.V)
+	}
+	newControl, ok := controlTypes[envelope.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown replay control %q", envelope.Type)
+	}
+	control := newControl()
+	if len(envelope.Payload) > 0 && !bytes.Equal(envelope.Payload, []byte("null")) {
+		decoder := json.NewDecoder(bytes.NewReader(envelope.Payload))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(control); err != nil {
+			return nil, fmt.Errorf("invalid payload for %s: %v", envelope.Type, err)
+		}
+	}
+	return control, nil
+}
+
+// parseLegacyControl converts the legacy "control:argument" format into a typed control
+func parseLegacyControl(msg string) (Control, error) {
+	command, argument, _ := strings.Cut(msg, ":")
+	switch command {
+	case "play":
+		return &PlayControl{}, nil
+	case "pause":
+		return &PauseControl{}, nil
+	case "speed":
+		speed, err := parseSpeed(argument)
+		if err != nil {
+			return nil, err
+		}
+		return &SpeedControl{Speed: &speed}, nil
+	case "seek":
+		tick, err := strconv.ParseUint(argument, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tick %q", argument)
+		}
+		return &SeekControl{Tick: &tick}, nil
+	}
+	return nil, fmt.Errorf("unknown replay control %q", command)
+}
@@ -0,0 +1,32 @@
+package replay
+
+import "testing"
+
+func TestDecodeControl(t *testing.T) {
+	control, err := DecodeControl([]byte(`{"v":1,"type":"seek","payload":{"tick":120}}`))
+	if seek, ok := control.(*SeekControl); err != nil || !ok || *seek.Tick != 120 {
+		t.Errorf("DecodeControl failed, expected %v, got %v", 120, err)
+	}
+	control, err = DecodeControl([]byte("speed:fast"))
+	if speed, ok := control.(*SpeedControl); err != nil || !ok || *speed.Speed != 2 {
+		t.Errorf("DecodeControl failed, expected %v, got %v", 2, err)
+	}
+	if control, err := DecodeControl([]byte("pause")); err != nil {
+		t.Errorf("DecodeControl failed, expected %v, got %v", &PauseControl{}, control)
+	}
+
+	for _, msg := range []string{
+		`{"v":1,"type":"speed","payload":{"speed":0}}`,
+		`{"v":1,"type":"speed","payload":{}}`,
+		`{"v":1,"type":"seek","payload":{"tick":-1}}`,
+		`{"v":1,"type":"seek","payload":{"tick":1,"extra":true}}`,
+		`{"v":2,"type":"play"}`,
+		`{"v":1,"type":"rewind"}`,
+		"speed:NaN",
+		"seek:soon",
+	} {
+		if _, err := DecodeControl([]byte(msg)); err == nil {
+			t.Errorf("DecodeControl failed for %v, expected %v, got %v", msg, "an error", nil)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package replay
+
+import (
+	"drbh/partita/game"
+	"drbh/partita/modes"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Playback re-simulates a recording tick by tick from its seed and inputs, on a
+// game service of its own so the replayed game never reaches live players
+type Playback struct {
+	recording     *Recording
+	replayService *ReplayService
+	gameService   *game.GameService
+	tick          uint64
+	next          int
+	snapshot      string
+	ended         bool
+	// Desyncs counts the keyframes the replay did not match
+	Desyncs int
+}
+
+// NewPlayback prepares a recording for playback at its first tick
+func (e *ReplayService) NewPlayback(recording *Recording) (*Playback, error) {
+	playback := &Playback{recording: recording, replayService: e}
+	if err := playback.reset(); err != nil {
+		return nil, err
+	}
+	return playback, nil
+}
+
+// reset recreates the game as it was created
+func (p *Playback) reset() error {
+	header := p.recording.Header
+	mode, err := modes.New(header.Mode)
+	if err != nil {
+		return fmt.Errorf("error replaying %v: %w", p.recording.ID, err)
+	}
+
+	if p.gameService != nil {
+		p.gameService.RemoveGame(header.GameKey)
+	}
+	p.gameService = game.NewGameService()
+	p.gameService.ManualTicks = true
+	p.gameService.OnTick(func(event game.TickEvent) {
+		p.snapshot = event.Snapshot
+	})
+
+	replayed := game.NewGame(header.Lifecycle)
+	replayed.Seed = header.Seed
+	replayed.Config = header.Config
+	replayed.Scoring = header.Scoring
+	replayed.Ranked = header.Ranked
+	replayed.Mode = mode
+	replayed.World = p.replayService.newWorld()
+	replayed.CreatedAt = header.CreatedAt
+	replayed.PhaseStartedAt = header.CreatedAt
+	p.gameService.AddGame(header.GameKey, replayed)
+
+	p.tick, p.next, p.snapshot, p.ended = 0, 0, "", false
+	return nil
+}
+
+// Tick is the last replayed tick
+func (p *Playback) Tick() uint64 {
+	return p.tick
+}
+
+// Snapshot is the game after the last replayed tick, in the format live games are sent in
+func (p *Playback) Snapshot() string {
+	return p.snapshot
+}
+
+// Ended reports whether the whole recording was replayed
+func (p *Playback) Ended() bool {
+	return p.ended
+}
+
+// Step replays the next tick and returns false once the recording ended
+func (p *Playback) Step() bool {
+	if p.ended {
+		return false
+	}
+	key := p.recording.Header.GameKey
+	p.tick++
+
+	var keyframes []game.RecordedEvent
+	for ; p.next < len(p.recording.Events) && p.recording.Events[p.next].Tick <= p.tick; p.next++ {
+		event := p.recording.Events[p.next]
+		switch event.Kind {
+		case game.RecordJoin:
+			player := p.gameService.NewPlayer(event.Player)
+			player.Name = event.Name
+			player.Bot = event.Bot
+			p.gameService.JoinGame(key, player)
+		case game.RecordRename:
+			p.gameService.RenamePlayer(&game.Player{ID: event.Player}, event.Name)
+		case game.RecordLeave:
+			p.gameService.LeaveGame(key, &game.Player{ID: event.Player})
+		case game.RecordReady:
+			p.gameService.SetReady(key, &game.Player{ID: event.Player})
+		case game.RecordInput:
+			p.gameService.HandleInput(&game.Player{ID: event.Player}, game.Input{Rotation: event.Rotation})
+		case game.RecordFinish:
+			p.gameService.EndGame(key)
+		case game.RecordSkip:
+			p.gameService.WithGame(key, func(g *game.Game) {
+				g.Tick += event.Skipped
+			})
+			p.tick += event.Skipped
+		case game.RecordKeyframe:
+			keyframes = append(keyframes, event)
+		}
+	}
+
+	header := p.recording.Header
+	now := header.CreatedAt.Add(time.Duration(p.tick) * header.Config.TickInterval())
+	if !p.gameService.Tick(key, now) || p.tick >= p.recording.EndTick() {
+		p.ended = true
+	}
+
+	for _, keyframe := range keyframes {
+		if keyframe.Tick == p.tick && string(keyframe.Snapshot) != p.snapshot {
+			p.Desyncs++
+			log.Printf("📼 Replay %v left its recording at tick %v\n", p.recording.ID, p.tick)
+		}
+	}
+	return !p.ended
+}
+
+// Seek replays up to tick, seeking backwards replays from the start
+func (p *Playback) Seek(tick uint64) error {
+	if tick < p.tick {
+		if err := p.reset(); err != nil {
+			return err
+		}
+	}
+	for p.tick < tick && p.Step() {
+	}
+	return nil
+}
+
+// Close releases the replayed game
+func (p *Playback) Close() {
+	p.gameService.RemoveGame(p.recording.Header.GameKey)
+}
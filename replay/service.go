@@ -0,0 +1,361 @@
+// Package replay records games to disk and plays them back
+package replay
+
+import (
+	"bufio"
+	"compress/gzip"
+	"drbh/partita/collision"
+	"drbh/partita/game"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDir is where recordings are stored unless PARTITA_REPLAY_DIR is set
+const DefaultDir = "replays"
+
+// DefaultMaxRecordings is how many recordings are kept unless PARTITA_REPLAY_LIMIT
+// is set, the oldest are removed once a game beyond it was recorded
+const DefaultMaxRecordings = 1000
+
+// formatVersion is written to every recording, recordings of another version are not played
+const formatVersion = 1
+
+// fileExtension of recordings, each is a gzipped stream of JSON lines
+const fileExtension = ".replay"
+
+var ErrUnknownReplay = errors.New("unknown replay")
+
+// validID matches the IDs of recordings, which are also their file names
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Header is the first line of a recording, it holds everything needed to
+// recreate the game before its events are replayed
+type Header struct {
+	Version   int                  `json:"version"`
+	GameKey   string               `json:"gameKey"`
+	Seed      int64                `json:"seed"`
+	Mode      string               `json:"mode"`
+	Config    game.GameConfig      `json:"config"`
+	Scoring   game.ScoringConfig   `json:"scoring"`
+	Lifecycle game.LifecycleConfig `json:"lifecycle"`
+	Ranked    bool                 `json:"ranked"`
+	CreatedAt time.Time            `json:"createdAt"`
+}
+
+// Recording is a game read back from disk
+type Recording struct {
+	ID     string
+	Header Header
+	Events []game.RecordedEvent
+}
+
+type ReplayService struct {
+	// Dir holds the recordings
+	Dir string
+	// MaxRecordings is how many recordings Dir keeps, 0 keeps all of them
+	MaxRecordings int
+	// newWorld creates the collision worlds of replayed games
+	newWorld collision.WorldFactory
+
+	// pruneMutex lets a single prune run at a time
+	pruneMutex     sync.Mutex
+	recordingMutex sync.Mutex
+	// recording holds the IDs of the recordings still being written, they are
+	// never removed
+	recording map[string]bool
+}
+
+var replayServiceInstance *ReplayService
+var once sync.Once
+
+func ProvideReplayService() *ReplayService {
+	log.Println("ProvideReplayService")
+	return GetReplayServiceInstance()
+}
+
+func GetReplayServiceInstance() *ReplayService {
+	once.Do(func() {
+		dir := os.Getenv("PARTITA_REPLAY_DIR")
+		if dir == "" {
+			dir = DefaultDir
+		}
+		replayServiceInstance = NewReplayService(dir, collision.ProvideWorldFactory())
+		replayServiceInstance.MaxRecordings = maxRecordings(os.Getenv("PARTITA_REPLAY_LIMIT"))
+		log.Println("📼 Successfully connected to Replay Service")
+	})
+	return replayServiceInstance
+}
+
+// NewReplayService creates a replay service that keeps its recordings in dir
+// and replays games in worlds from newWorld
+func NewReplayService(dir string, newWorld collision.WorldFactory) *ReplayService {
+	return &ReplayService{
+		Dir:           dir,
+		MaxRecordings: DefaultMaxRecordings,
+		newWorld:      newWorld,
+		recording:     make(map[string]bool),
+	}
+}
+
+// maxRecordings reads PARTITA_REPLAY_LIMIT, 0 keeps every recording
+func maxRecordings(value string) int {
+	if value == "" {
+		return DefaultMaxRecordings
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		log.Printf("Invalid PARTITA_REPLAY_LIMIT %q, using %v\n", value, DefaultMaxRecordings)
+		return DefaultMaxRecordings
+	}
+	return limit
+}
+
+// Recorder returns a recorder for a game that is about to be added under key.
+// The game's seed, mode and configs must already be set. The file is only
+// created once the game records its first event.
+func (e *ReplayService) Recorder(key string, g *game.Game) game.Recorder {
+	header := Header{
+		Version:   formatVersion,
+		GameKey:   key,
+		Seed:      g.Seed,
+		Config:    g.Config,
+		Scoring:   g.Scoring,
+		Lifecycle: g.Lifecycle,
+		Ranked:    g.Ranked,
+		CreatedAt: g.CreatedAt,
+	}
+	if g.Mode != nil {
+		header.Mode = g.Mode.Name()
+	}
+	id := fmt.Sprintf("%s-%d", sanitize(key), g.CreatedAt.UnixNano()/int64(time.Millisecond))
+	return &fileRecorder{service: e, id: id, path: filepath.Join(e.Dir, id+fileExtension), header: header}
+}
+
+// prune removes the oldest finished recordings beyond MaxRecordings
+func (e *ReplayService) prune() {
+	if e.MaxRecordings <= 0 {
+		return
+	}
+	e.pruneMutex.Lock()
+	defer e.pruneMutex.Unlock()
+	ids, err := e.List()
+	if err != nil {
+		log.Printf("Error listing replays: %v\n", err)
+		return
+	}
+	e.recordingMutex.Lock()
+	defer e.recordingMutex.Unlock()
+	remaining := len(ids)
+	for _, id := range ids {
+		if remaining <= e.MaxRecordings {
+			return
+		}
+		if e.recording[id] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(e.Dir, id+fileExtension)); err != nil {
+			log.Printf("Error removing replay %v: %v\n", id, err)
+			continue
+		}
+		log.Printf("🧹 Removed replay %v\n", id)
+		remaining--
+	}
+}
+
+// setRecording marks a recording as being written or finished
+func (e *ReplayService) setRecording(id string, recording bool) {
+	e.recordingMutex.Lock()
+	defer e.recordingMutex.Unlock()
+	if recording {
+		e.recording[id] = true
+	} else {
+		delete(e.recording, id)
+	}
+}
+
+// List returns the IDs of all recordings, oldest first
+func (e *ReplayService) List() ([]string, error) {
+	entries, err := os.ReadDir(e.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	recordedAt := make(map[string]int64)
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), fileExtension)
+		if id == entry.Name() {
+			continue
+		}
+		// files that were not written by a recorder are left alone
+		millis, ok := parseRecordedAt(id)
+		if !ok {
+			continue
+		}
+		ids = append(ids, id)
+		recordedAt[id] = millis
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if recordedAt[ids[i]] == recordedAt[ids[j]] {
+			return ids[i] < ids[j]
+		}
+		return recordedAt[ids[i]] < recordedAt[ids[j]]
+	})
+	return ids, nil
+}
+
+// parseRecordedAt reads the creation time in milliseconds from the end of a
+// recording's ID
+func parseRecordedAt(id string) (int64, bool) {
+	index := strings.LastIndex(id, "-")
+	if index < 0 || !validID.MatchString(id) {
+		return 0, false
+	}
+	millis, err := strconv.ParseInt(id[index+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return millis, true
+}
+
+// Load reads the recording with the given ID
+func (e *ReplayService) Load(id string) (*Recording, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrUnknownReplay
+	}
+	file, err := os.Open(filepath.Join(e.Dir, id+fileExtension))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUnknownReplay
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading replay %v: %w", id, err)
+	}
+	decoder := json.NewDecoder(reader)
+	recording := &Recording{ID: id}
+	if err := decoder.Decode(&recording.Header); err != nil {
+		return nil, fmt.Errorf("error reading replay %v: %w", id, err)
+	}
+	if recording.Header.Version != formatVersion {
+		return nil, fmt.Errorf("replay %v has unsupported version %v", id, recording.Header.Version)
+	}
+	for {
+		var event game.RecordedEvent
+		err := decoder.Decode(&event)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// a recording cut short by a crash is played up to where it ends
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading replay %v: %w", id, err)
+		}
+		recording.Events = append(recording.Events, event)
+	}
+	return recording, nil
+}
+
+// EndTick is the last tick of the recording
+func (r *Recording) EndTick() uint64 {
+	var end uint64
+	for _, event := range r.Events {
+		if event.Tick > end {
+			end = event.Tick
+		}
+	}
+	return end
+}
+
+// fileRecorder writes a game's recording as gzipped JSON lines
+type fileRecorder struct {
+	service *ReplayService
+	id      string
+	path    string
+	header  Header
+	file    *os.File
+	gzip    *gzip.Writer
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+	failed  bool
+}
+
+// Record appends an event, the first event creates the file
+func (r *fileRecorder) Record(event game.RecordedEvent) {
+	if r.failed {
+		return
+	}
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			log.Printf("Error creating replay %v: %v\n", r.path, err)
+			r.failed = true
+			return
+		}
+	}
+	if err := r.encoder.Encode(event); err != nil {
+		log.Printf("Error writing replay %v: %v\n", r.path, err)
+		r.failed = true
+	}
+}
+
+// open creates the file and writes the header
+func (r *fileRecorder) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.Create(r.path)
+	if err != nil {
+		return err
+	}
+	r.service.setRecording(r.id, true)
+	r.file = file
+	r.gzip = gzip.NewWriter(file)
+	r.buffer = bufio.NewWriter(r.gzip)
+	r.encoder = json.NewEncoder(r.buffer)
+	return r.encoder.Encode(r.header)
+}
+
+// Close flushes the recording to disk and makes room for it in the replay directory
+func (r *fileRecorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	// the recorder closes on the game's loop, listing the directory does not
+	defer func() { go r.service.prune() }()
+	defer r.service.setRecording(r.id, false)
+	defer r.file.Close()
+	if err := r.buffer.Flush(); err != nil {
+		return err
+	}
+	if err := r.gzip.Close(); err != nil {
+		return err
+	}
+	log.Printf("📼 Recorded %v\n", r.path)
+	return nil
+}
+
+// sanitize turns a game key into something safe to use in a file name
+func sanitize(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, key)
+}
@@ -0,0 +1,137 @@
+package replay
+
+import (
+	"drbh/partita/collision"
+	"drbh/partita/game"
+	"drbh/partita/modes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// recordGame plays and records a scripted snake game, it returns the recording's
+// ID and the snapshot of every tick
+func recordGame(t *testing.T, replayService *ReplayService) (string, []string) {
+	service := game.NewGameService()
+	service.ManualTicks = true
+	var snapshots []string
+	service.OnTick(func(event game.TickEvent) {
+		snapshots = append(snapshots, event.Snapshot)
+	})
+
+	g := game.NewGame(game.DefaultLifecycleConfig())
+	g.Lifecycle.CountdownDuration = 0
+	g.Seed = 7
+	g.Mode = modes.NewSnakeMode()
+	g.Recorder = replayService.Recorder("recorded", g)
+	service.AddGame("recorded", g)
+	players := []*game.Player{service.NewPlayer("a"), service.NewPlayer("b")}
+	for _, player := range players {
+		service.JoinGame("recorded", player)
+	}
+
+	step := g.Config.TickInterval()
+	for tick := 1; tick <= 700; tick++ {
+		if tick%20 == 0 {
+			rotation := float64(tick/20%4) * math.Pi / 2
+			service.HandleInput(players[tick/20%2], game.Input{Rotation: &rotation})
+		}
+		service.Tick("recorded", g.CreatedAt.Add(time.Duration(tick)*step))
+	}
+	for _, player := range players {
+		service.LeaveGame("recorded", player)
+	}
+
+	ids, err := replayService.List()
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("List failed, expected %v, got %v", 1, ids)
+	}
+	return ids[0], snapshots
+}
+
+func TestReplayMatchesRecording(t *testing.T) {
+	replayService := NewReplayService(t.TempDir(), collision.ProvideWorldFactory())
+	id, snapshots := recordGame(t, replayService)
+
+	recording, err := replayService.Load(id)
+	if err != nil {
+		t.Fatalf("Load failed, expected %v, got %v", nil, err)
+	}
+	if recording.Header.Seed != 7 || recording.Header.Mode != "snake" {
+		t.Errorf("Load failed, expected %v, got %v", "seed 7 and snake", recording.Header)
+	}
+
+	playback, err := replayService.NewPlayback(recording)
+	if err != nil {
+		t.Fatalf("NewPlayback failed, expected %v, got %v", nil, err)
+	}
+	defer playback.Close()
+	for playback.Step() {
+		if tick := playback.Tick(); playback.Snapshot() != snapshots[tick-1] {
+			t.Fatalf("Step failed at tick %v, expected %v, got %v", tick, snapshots[tick-1], playback.Snapshot())
+		}
+	}
+	if playback.Tick() != uint64(len(snapshots)+1) || playback.Desyncs != 0 {
+		t.Errorf("Step failed, expected %v, got %v", len(snapshots)+1, playback.Tick())
+	}
+
+	if err := playback.Seek(350); err != nil || playback.Snapshot() != snapshots[349] {
+		t.Errorf("Seek failed, expected %v, got %v", snapshots[349], playback.Snapshot())
+	}
+}
+
+func TestLoadRejectsUnknownIDs(t *testing.T) {
+	replayService := NewReplayService(t.TempDir(), collision.ProvideWorldFactory())
+	for _, id := range []string{"missing", "../secret", ""} {
+		if _, err := replayService.Load(id); err != ErrUnknownReplay {
+			t.Errorf("Load failed, expected %v, got %v", ErrUnknownReplay, err)
+		}
+	}
+}
+
+func TestPruneKeepsNewestRecordings(t *testing.T) {
+	replayService := NewReplayService(t.TempDir(), collision.ProvideWorldFactory())
+	replayService.MaxRecordings = 2
+	for _, id := range []string{"a-1", "b-2", "c-3", "d-4"} {
+		if err := os.WriteFile(filepath.Join(replayService.Dir, id+fileExtension), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replayService.setRecording("a-1", true)
+	replayService.prune()
+
+	ids, err := replayService.List()
+	expected := []string{"a-1", "d-4"}
+	if err != nil || len(ids) != len(expected) || ids[0] != expected[0] || ids[1] != expected[1] {
+		t.Errorf("prune failed, expected %v, got %v", expected, ids)
+	}
+}
+
+func TestListSkipsForeignFiles(t *testing.T) {
+	replayService := NewReplayService(t.TempDir(), collision.ProvideWorldFactory())
+	for _, name := range []string{"stray", "game-soon", "b-20", "a-10"} {
+		if err := os.WriteFile(filepath.Join(replayService.Dir, name+fileExtension), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids, err := replayService.List()
+	expected := []string{"a-10", "b-20"}
+	if err != nil || len(ids) != len(expected) || ids[0] != expected[0] || ids[1] != expected[1] {
+		t.Errorf("List failed, expected %v, got %v", expected, ids)
+	}
+}
+
+func TestParseSpeed(t *testing.T) {
+	for value, expected := range map[string]float64{"slow": 0.5, "normal": 1, "fast": 2, "4": 4} {
+		if speed, err := parseSpeed(value); err != nil || speed != expected {
+			t.Errorf("parseSpeed failed, expected %v, got %v", expected, speed)
+		}
+	}
+	for _, value := range []string{"0", "-1", "100", "NaN", "quick"} {
+		if _, err := parseSpeed(value); err == nil {
+			t.Errorf("parseSpeed failed, expected %v, got %v", "an error", nil)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Package session issues signed session tokens and keeps players alive across reconnects
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"drbh/partita/game"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGracePeriod is how long a disconnected player is kept in its games
+const DefaultGracePeriod = 30 * time.Second
+
+var ErrInvalidToken = errors.New("invalid session token")
+var ErrSessionExpired = errors.New("session expired")
+
+// Signer signs and verifies values with HMAC-SHA256
+type Signer struct {
+	secret []byte
+}
+
+// NewSigner creates a Signer for the given secret
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+// Sign returns a token of the form base64(value).base64(signature)
+func (s *Signer) Sign(value string) string {
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(value))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac(encoded))
+}
+
+// Verify checks the signature of a token and returns the signed value
+func (s *Signer) Verify(token string) (string, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", ErrInvalidToken
+	}
+	expected, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, s.mac(encoded)) {
+		return "", ErrInvalidToken
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	return string(value), nil
+}
+
+func (s *Signer) mac(value string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// PlayerSession ties a stable session ID to a player and the connection currently driving it
+type PlayerSession struct {
+	ID           string
+	Token        string
+	PlayerID     string
+	Player       *game.Player
+	ConnectionID string
+	expiry       *time.Timer
+}
+
+type SessionService struct {
+	Sessions      map[string]*PlayerSession
+	SessionsMutex sync.Mutex
+	GracePeriod   time.Duration
+	signer        *Signer
+}
+
+var sessionServiceInstance *SessionService
+var once sync.Once
+
+func ProvideSessionService() *SessionService {
+	log.Println("ProvideSessionService")
+	return GetSessionServiceInstance()
+}
+
+// GetSessionServiceInstance returns the singleton SessionService. The signing secret
+// is read from PARTITA_SESSION_SECRET and the grace period from PARTITA_SESSION_GRACE,
+// without a secret tokens are only valid for the lifetime of the process.
+func GetSessionServiceInstance() *SessionService {
+	once.Do(func() {
+		secret := []byte(os.Getenv("PARTITA_SESSION_SECRET"))
+		if len(secret) == 0 {
+			secret = []byte(randomID())
+		}
+		gracePeriod := DefaultGracePeriod
+		if value := os.Getenv("PARTITA_SESSION_GRACE"); value != "" {
+			if parsed, err := time.ParseDuration(value); err == nil {
+				gracePeriod = parsed
+			} else {
+				log.Printf("Invalid PARTITA_SESSION_GRACE %q: %v\n", value, err)
+			}
+		}
+		sessionServiceInstance = NewSessionService(NewSigner(secret), gracePeriod)
+		log.Println("🎟️ Successfully connected to Session Service")
+	})
+	return sessionServiceInstance
+}
+
+// NewSessionService creates a SessionService, mostly useful for tests
+func NewSessionService(signer *Signer, gracePeriod time.Duration) *SessionService {
+	return &SessionService{
+		Sessions:    make(map[string]*PlayerSession),
+		GracePeriod: gracePeriod,
+		signer:      signer,
+	}
+}
+
+// Signer returns the signer used for session tokens
+func (e *SessionService) Signer() *Signer {
+	return e.signer
+}
+
+// Create starts a new session for a connection, newPlayer builds the player from
+// the player ID. Without a player ID the session ID is used.
+func (e *SessionService) Create(connectionID string, playerID string, newPlayer func(id string) *game.Player) *PlayerSession {
+	id := randomID()
+	if playerID == "" {
+		playerID = id
+	}
+	session := &PlayerSession{
+		ID:           id,
+		Token:        e.signer.Sign(id),
+		PlayerID:     playerID,
+		Player:       newPlayer(playerID),
+		ConnectionID: connectionID,
+	}
+
+	e.SessionsMutex.Lock()
+	defer e.SessionsMutex.Unlock()
+	e.Sessions[id] = session
+	return session
+}
+
+// Resume reattaches a new connection to the session identified by token, the
+// session must belong to playerID
+func (e *SessionService) Resume(token string, connectionID string, playerID string) (*PlayerSession, error) {
+	id, err := e.signer.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	e.SessionsMutex.Lock()
+	defer e.SessionsMutex.Unlock()
+	session, ok := e.Sessions[id]
+	if !ok {
+		return nil, ErrSessionExpired
+	}
+	if playerID != "" && session.PlayerID != playerID {
+		return nil, ErrInvalidToken
+	}
+	if session.expiry != nil {
+		session.expiry.Stop()
+		session.expiry = nil
+	}
+	session.ConnectionID = connectionID
+	return session, nil
+}
+
+// Detach marks the session as disconnected when connectionID still owns it. If no
+// connection resumes it within the grace period the session is dropped and onExpire
+// is called so the player can be removed from its games.
+func (e *SessionService) Detach(id string, connectionID string, onExpire func(session *PlayerSession)) {
+	e.SessionsMutex.Lock()
+	defer e.SessionsMutex.Unlock()
+	session, ok := e.Sessions[id]
+	if !ok || session.ConnectionID != connectionID {
+		// another connection has taken over the session
+		return
+	}
+	session.ConnectionID = ""
+	session.expiry = time.AfterFunc(e.GracePeriod, func() {
+		e.SessionsMutex.Lock()
+		current, ok := e.Sessions[id]
+		if !ok || current != session || session.ConnectionID != "" {
+			e.SessionsMutex.Unlock()
+			return
+		}
+		delete(e.Sessions, id)
+		e.SessionsMutex.Unlock()
+
+		log.Printf("⌛ Session %v expired\n", id)
+		onExpire(session)
+	})
+}
+
+// GetSession returns the session with the given ID
+func (e *SessionService) GetSession(id string) (*PlayerSession, bool) {
+	e.SessionsMutex.Lock()
+	defer e.SessionsMutex.Unlock()
+	session, ok := e.Sessions[id]
+	return session, ok
+}
+
+func randomID() string {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		log.Fatalf("Error generating session ID: %v", err)
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"drbh/partita/game"
+	"testing"
+	"time"
+)
+
+func newTestPlayer(id string) *game.Player {
+	return &game.Player{ID: id, Name: id}
+}
+
+func TestSignerRoundTrip(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	value, err := signer.Verify(signer.Sign("player"))
+	if err != nil || value != "player" {
+		t.Errorf("Verify failed, expected %v, got %v (%v)", "player", value, err)
+	}
+}
+
+func TestSignerRejectsTamperedToken(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	other := NewSigner([]byte("other"))
+	if _, err := signer.Verify(other.Sign("player")); err != ErrInvalidToken {
+		t.Errorf("Verify failed, expected %v, got %v", ErrInvalidToken, err)
+	}
+	if _, err := signer.Verify("garbage"); err != ErrInvalidToken {
+		t.Errorf("Verify failed, expected %v, got %v", ErrInvalidToken, err)
+	}
+}
+
+func TestResumeWithinGracePeriod(t *testing.T) {
+	service := NewSessionService(NewSigner([]byte("secret")), 50*time.Millisecond)
+	created := service.Create("conn-1", "player", newTestPlayer)
+
+	expired := make(chan bool, 1)
+	service.Detach(created.ID, "conn-1", func(session *PlayerSession) { expired <- true })
+
+	resumed, err := service.Resume(created.Token, "conn-2", "player")
+	if err != nil || resumed.Player != created.Player {
+		t.Fatalf("Resume failed, expected %v, got %v (%v)", created.Player, resumed, err)
+	}
+
+	select {
+	case <-expired:
+		t.Errorf("Resume failed, expected %v, got %v", "no expiry", "expired")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSessionExpiresAfterGracePeriod(t *testing.T) {
+	service := NewSessionService(NewSigner([]byte("secret")), 10*time.Millisecond)
+	created := service.Create("conn-1", "player", newTestPlayer)
+
+	expired := make(chan bool, 1)
+	service.Detach(created.ID, "conn-1", func(session *PlayerSession) { expired <- true })
+
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatalf("Detach failed, expected %v, got %v", "expired", "no expiry")
+	}
+	if _, err := service.Resume(created.Token, "conn-2", "player"); err != ErrSessionExpired {
+		t.Errorf("Resume failed, expected %v, got %v", ErrSessionExpired, err)
+	}
+}
+
+func TestResumeRejectsOtherPlayer(t *testing.T) {
+	service := NewSessionService(NewSigner([]byte("secret")), time.Second)
+	created := service.Create("conn-1", "player", newTestPlayer)
+	if _, err := service.Resume(created.Token, "conn-2", "intruder"); err != ErrInvalidToken {
+		t.Errorf("Resume failed, expected %v, got %v", ErrInvalidToken, err)
+	}
+}
@@ -0,0 +1,221 @@
+package websocket
+
+import (
+	"bytes"
+	"drbh/partita/game"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Command is implemented by every typed command payload
+type Command interface {
+	Validate() error
+}
+
+// commandHandler decodes, validates and executes a single envelope
+type commandHandler func(s *Session, envelope Envelope) error
+
+// CommandRegistry maps message types to typed command handlers
+type CommandRegistry struct {
+	handlers map[string]commandHandler
+	// spectatorCommands are the only commands spectators may send
+	spectatorCommands map[string]bool
+}
+
+// NewCommandRegistry creates an empty CommandRegistry
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		handlers:          make(map[string]commandHandler),
+		spectatorCommands: make(map[string]bool),
+	}
+}
+
+// Register adds a typed handler for the given message type. The payload is decoded
+// into T and validated before the handler is called.
+func Register[T Command](r *CommandRegistry, msgType string, handler func(s *Session, id string, cmd T) error) {
+	r.handlers[msgType] = func(s *Session, envelope Envelope) error {
+		var cmd T
+		if len(envelope.Payload) > 0 && !bytes.Equal(envelope.Payload, []byte("null")) {
+			decoder := json.NewDecoder(bytes.NewReader(envelope.Payload))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&cmd); err != nil {
+				return newProtocolError(ErrCodeInvalidPayload, "invalid payload for %s: %v", msgType, err)
+			}
+		}
+		if err := cmd.Validate(); err != nil {
+			return newProtocolError(ErrCodeInvalidPayload, "invalid payload for %s: %v", msgType, err)
+		}
+		return handler(s, envelope.ID, cmd)
+	}
+}
+
+// AllowSpectators lets spectator sessions send the given message types, they
+// may not send any other command
+func (r *CommandRegistry) AllowSpectators(msgTypes ...string) {
+	for _, msgType := range msgTypes {
+		r.spectatorCommands[msgType] = true
+	}
+}
+
+// Dispatch routes an envelope to its registered handler
+func (r *CommandRegistry) Dispatch(s *Session, envelope Envelope) error {
+	handler, ok := r.handlers[envelope.Type]
+	if !ok {
+		return newProtocolError(ErrCodeUnknownCommand, "unknown command %q", envelope.Type)
+	}
+	if s.Spectator && !r.spectatorCommands[envelope.Type] {
+		return newProtocolError(ErrCodeForbidden, "spectators cannot send %q", envelope.Type)
+	}
+	err := handler(s, envelope)
+	if err == nil {
+		return nil
+	}
+	var protocolError *ProtocolError
+	if errors.As(err, &protocolError) {
+		return protocolError
+	}
+	return newProtocolError(ErrCodeCommandFailed, "%v", err)
+}
+
+// PingCommand is a keepalive with no payload
+type PingCommand struct{}
+
+func (c PingCommand) Validate() error { return nil }
+
+// AddPlayerCommand places a player in the matchmaking queue with an Elo
+type AddPlayerCommand struct {
+	PlayerID string  `json:"playerId"`
+	Elo      float64 `json:"elo"`
+}
+
+func (c AddPlayerCommand) Validate() error {
+	if strings.TrimSpace(c.PlayerID) == "" {
+		return errors.New("playerId is required")
+	}
+	return nil
+}
+
+// JoinGameCommand joins an existing game
+type JoinGameCommand struct {
+	GameKey string `json:"gameKey"`
+}
+
+func (c JoinGameCommand) Validate() error {
+	return validateGameKey(c.GameKey)
+}
+
+// LeaveGameCommand leaves a game
+type LeaveGameCommand struct {
+	GameKey string `json:"gameKey"`
+}
+
+func (c LeaveGameCommand) Validate() error {
+	return validateGameKey(c.GameKey)
+}
+
+// SpectateCommand switches a spectator to another game
+type SpectateCommand struct {
+	GameKey string `json:"gameKey"`
+}
+
+func (c SpectateCommand) Validate() error {
+	return validateGameKey(c.GameKey)
+}
+
+// StartGameCommand creates a game and joins it
+type StartGameCommand struct {
+	GameKey string `json:"gameKey"`
+	// ReadyCheck makes every player confirm with "ready" before the countdown
+	ReadyCheck bool `json:"readyCheck,omitempty"`
+	// Mode names the game mode, see modes.Names, "snake" when empty
+	Mode string `json:"mode,omitempty"`
+	// Scoring is "lastStanding" or "firstToPoints" (default)
+	Scoring string `json:"scoring,omitempty"`
+	// Rounds to play, the mode's default when 0
+	Rounds int `json:"rounds,omitempty"`
+	// Preset names the arena config to use, "default" when empty
+	Preset string `json:"preset,omitempty"`
+	// Config overrides the preset with a custom arena config
+	Config *game.GameConfig `json:"config,omitempty"`
+	// Seed makes the game reproducible, a random seed is used when 0
+	Seed int64 `json:"seed,omitempty"`
+	// Record saves the game for replays
+	Record bool `json:"record,omitempty"`
+}
+
+func (c StartGameCommand) Validate() error {
+	switch game.ScoreMode(c.Scoring) {
+	case "", game.ModeLastStanding, game.ModeFirstToPoints:
+	default:
+		return fmt.Errorf("unknown scoring %q", c.Scoring)
+	}
+	if c.Rounds < 0 {
+		return errors.New("rounds must not be negative")
+	}
+	if c.Config != nil {
+		if err := c.Config.Validate(); err != nil {
+			return err
+		}
+	}
+	return validateGameKey(c.GameKey)
+}
+
+// AddBotCommand adds a bot to a game started by the connected player
+type AddBotCommand struct {
+	GameKey string `json:"gameKey"`
+	// Level is "easy", "medium" (default) or "hard"
+	Level string `json:"level,omitempty"`
+}
+
+func (c AddBotCommand) Validate() error {
+	return validateGameKey(c.GameKey)
+}
+
+// ReadyCommand confirms the connected player is ready to start a game
+type ReadyCommand struct {
+	GameKey string `json:"gameKey"`
+}
+
+func (c ReadyCommand) Validate() error {
+	return validateGameKey(c.GameKey)
+}
+
+// SetPlayerNameCommand changes the display name of the connected player, its ID stays the same
+type SetPlayerNameCommand struct {
+	Name string `json:"name"`
+}
+
+func (c SetPlayerNameCommand) Validate() error {
+	return game.ValidateDisplayName(c.Name)
+}
+
+// RotateCommand sets the heading of the connected player in radians
+type RotateCommand struct {
+	Rotation *float64 `json:"rotation"`
+}
+
+func (c RotateCommand) Validate() error {
+	if c.Rotation == nil {
+		return errors.New("rotation is required")
+	}
+	// a NaN or infinite heading would spread into positions and break every snapshot
+	if math.IsNaN(*c.Rotation) || math.IsInf(*c.Rotation, 0) {
+		return errors.New("rotation must be a finite number")
+	}
+	return nil
+}
+
+// FindGameCommand places the connected player in the matchmaking queue
+type FindGameCommand struct{}
+
+func (c FindGameCommand) Validate() error { return nil }
+
+func validateGameKey(gameKey string) error {
+	if strings.TrimSpace(gameKey) == "" {
+		return errors.New("gameKey is required")
+	}
+	return nil
+}
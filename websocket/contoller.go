@@ -1,15 +1,23 @@
 package websocket
 
 import (
+	"context"
+	"drbh/partita/auth"
+	"drbh/partita/bot"
 	"drbh/partita/collision"
 	"drbh/partita/connection"
 	"drbh/partita/game"
 	"drbh/partita/match"
+	"drbh/partita/modes"
+	"drbh/partita/replay"
+	"drbh/partita/session"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
@@ -19,21 +27,55 @@ type WebsocketController struct {
 	connectionService  *connection.ConnectionService
 	matchmakingService match.MatchmakingService
 	gameService        *game.GameService
-	collisionService   *collision.LineSegmentManager
+	sessionService     *session.SessionService
+	replayService      *replay.ReplayService
+	botService         *bot.BotService
+	// newWorld creates the collision world of every game the controller starts
+	newWorld collision.WorldFactory
+	commands *CommandRegistry
+}
+
+// Session holds the state of a single websocket connection
+type Session struct {
+	ConnectionID string
+	// PlayerID is the identity verified by the auth middleware
+	PlayerID    string
+	Player      *game.Player
+	connections *connection.ConnectionService
+	// legacy is frozen from the first frame, replies keep that format for the
+	// rest of the session even though other goroutines send them
+	legacy       atomic.Bool
+	protocolOnce sync.Once
+	// hello is sent once the first frame told which protocol the client speaks
+	hello func()
+	// Spectator sessions watch a game without a player and cannot send gameplay commands
+	Spectator bool
+	// ctx is done once the connection closed
+	ctx context.Context
+	// cancelMatch stops the listener of the session's last match search
+	cancelMatch context.CancelFunc
 }
 
 func NewWebsocketController(
 	connectionService *connection.ConnectionService,
 	matchmakingService match.MatchmakingService,
 	gameService *game.GameService,
-	collisionService *collision.LineSegmentManager,
+	sessionService *session.SessionService,
+	replayService *replay.ReplayService,
+	botService *bot.BotService,
+	newWorld collision.WorldFactory,
 ) WebsocketController {
-	return WebsocketController{
+	controller := WebsocketController{
 		connectionService:  connectionService,
 		matchmakingService: matchmakingService,
 		gameService:        gameService,
-		collisionService:   collisionService,
+		sessionService:     sessionService,
+		replayService:      replayService,
+		botService:         botService,
+		newWorld:           newWorld,
 	}
+	controller.commands = controller.newCommandRegistry()
+	return controller
 }
 
 // static method
@@ -50,17 +92,105 @@ func (e *WebsocketController) Get(c *fiber.Ctx) error {
 	return nil
 }
 
+// matchContext returns the context of a new match search, it cancels the
+// session's previous search and is done once the connection closed
+func (s *Session) matchContext() context.Context {
+	if s.cancelMatch != nil {
+		s.cancelMatch()
+	}
+	parent := s.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	s.cancelMatch = cancel
+	return ctx
+}
+
+// Send writes a reply to the client, as an Envelope or in the legacy format
+func (s *Session) Send(msgType string, id string, payload interface{}) error {
+	var message []byte
+	var err error
+	if s.legacy.Load() {
+		message, err = encodeLegacyReply(msgType, payload)
+	} else {
+		message, err = encodeReply(msgType, id, payload)
+	}
+	if err != nil {
+		return fmt.Errorf("error marshalling %v payload: %w", msgType, err)
+	}
+	return s.write(message)
+}
+
+// write queues a raw message on the connection's writer
+func (s *Session) write(message []byte) error {
+	if !s.connections.SendTo(s.ConnectionID, string(message)) {
+		return fmt.Errorf("connection %v is closed", s.ConnectionID)
+	}
+	return nil
+}
+
+// SendError writes a structured error reply to the client
+func (s *Session) SendError(id string, err error) {
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) {
+		protocolError = newProtocolError(ErrCodeCommandFailed, "%v", err)
+	}
+
+	if s.legacy.Load() {
+		// legacy clients only ever received plain text errors
+		if writeErr := s.write([]byte(protocolError.Message)); writeErr != nil {
+			log.Printf("Error writing error reply: %v", writeErr)
+		}
+		return
+	}
+
+	if writeErr := s.Send("error", id, ErrorPayload{Code: protocolError.Code, Message: protocolError.Message}); writeErr != nil {
+		log.Printf("Error writing error reply: %v", writeErr)
+	}
+}
+
 func (e *WebsocketController) HandleWebSocketConnections(c *fiber.Ctx) error {
 	handler := func(c *websocket.Conn) {
 		// get a unique connection ID from the websocket connection
 		// we use the remote address and port to generate a unique ID
 		connectionID := fmt.Sprintf("%s-%s", c.RemoteAddr(), c.LocalAddr().String())
 		log.Printf("New connection: %v\n", connectionID)
+
+		// the auth middleware binds the verified player ID before the upgrade
+		playerID, _ := c.Locals(auth.PlayerIDLocal).(string)
+		if playerID == "" {
+			log.Printf("Rejecting unauthenticated connection: %v\n", connectionID)
+			c.Close()
+			return
+		}
+
 		e.connectionService.AddConnection(connectionID, c)
 		defer e.connectionService.RemoveConnection(connectionID)
+		// stops the match listeners of the connection when it closes
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		clientSession := &Session{
+			ConnectionID: connectionID,
+			PlayerID:     playerID,
+			connections:  e.connectionService,
+			ctx:          ctx,
+		}
 
-		player := e.gameService.PlayerFromConnectionID(connectionID)
-		defer e.gameService.LeaveAllGames(player)
+		if gameKey := c.Query("spectate"); gameKey != "" {
+			// spectators get no player, so they never take part in a game
+			clientSession.Spectator = true
+			if err := e.handleSpectate(clientSession, "", SpectateCommand{GameKey: gameKey}); err != nil {
+				clientSession.SendError("", err)
+			}
+		} else {
+			// the player outlives the socket for the session's grace period
+			playerSession := e.openSession(clientSession, c.Query("session"))
+			defer e.sessionService.Detach(playerSession.ID, connectionID, func(expired *session.PlayerSession) {
+				e.gameService.LeaveAllGames(expired.Player)
+			})
+		}
 
 		for {
 			_, msg, err := c.ReadMessage()
@@ -69,167 +199,384 @@ func (e *WebsocketController) HandleWebSocketConnections(c *fiber.Ctx) error {
 				c.Close()
 				break
 			}
+			e.handleFrame(clientSession, msg)
+		}
+	}
 
-			// print the message to the console
-			log.Printf("Received: %s", string(msg))
+	return websocket.New(handler)(c)
+}
 
-			if string(msg) == "ping" {
-				if err := c.WriteMessage(websocket.TextMessage, []byte("pong")); err != nil {
-					log.Printf("Error writing pong: %v", err)
-				}
-			}
+// handleFrame decodes and dispatches a frame read from the session's connection,
+// the first frame decides whether the session speaks the legacy protocol
+func (e *WebsocketController) handleFrame(s *Session, msg []byte) {
+	// print the message to the console
+	log.Printf("Received: %s", string(msg))
+
+	envelope, legacy, err := DecodeMessage(msg)
+	s.setProtocol(legacy)
+	if err != nil {
+		log.Printf("Invalid message: %v", err)
+		s.SendError(envelope.ID, err)
+		return
+	}
 
-			msgParts := strings.Split(string(msg), ":")
-			if len(msgParts) < 1 {
-				log.Println("Invalid message format")
-				// return
-				c.WriteMessage(websocket.TextMessage, []byte("Invalid message format"))
-				continue
+	if err := e.commands.Dispatch(s, envelope); err != nil {
+		log.Printf("Error handling %v: %v", envelope.Type, err)
+		s.SendError(envelope.ID, err)
+	}
+}
+
+// setProtocol freezes the reply format of the session and sends its hello, only
+// the first call counts
+func (s *Session) setProtocol(legacy bool) {
+	s.protocolOnce.Do(func() {
+		s.legacy.Store(legacy)
+		if s.hello != nil {
+			s.hello()
+			s.hello = nil
+		}
+	})
+}
+
+// openSession resumes the session identified by token or starts a new one, and
+// tells the client which token to present when it reconnects once its first
+// frame showed which protocol it speaks
+func (e *WebsocketController) openSession(s *Session, token string) *session.PlayerSession {
+	if token != "" {
+		playerSession, err := e.sessionService.Resume(token, s.ConnectionID, s.PlayerID)
+		if err == nil {
+			s.Player = playerSession.Player
+			e.resyncSession(s, playerSession)
+			return playerSession
+		}
+		log.Printf("Could not resume session: %v\n", err)
+	}
+
+	playerSession := e.sessionService.Create(s.ConnectionID, s.PlayerID, e.gameService.NewPlayer)
+	s.Player = playerSession.Player
+	s.hello = func() {
+		if err := s.Send("session", "", map[string]interface{}{
+			"token":    playerSession.Token,
+			"playerId": playerSession.PlayerID,
+			"resumed":  false,
+		}); err != nil {
+			log.Printf("Error writing session: %v\n", err)
+		}
+	}
+	return playerSession
+}
+
+// resyncSession subscribes a resumed connection to its player's games, its hello
+// is followed by a full snapshot of each of them
+func (e *WebsocketController) resyncSession(s *Session, playerSession *session.PlayerSession) {
+	gameKeys := e.gameService.GetPlayerGames(playerSession.Player)
+	log.Printf("♻️ Resuming session %v in games %v\n", playerSession.ID, gameKeys)
+
+	for _, gameKey := range gameKeys {
+		e.connectionService.JoinRoom(gameKey, s.ConnectionID)
+	}
+	s.hello = func() {
+		if err := s.Send("session", "", map[string]interface{}{
+			"token":    playerSession.Token,
+			"playerId": playerSession.PlayerID,
+			"resumed":  true,
+			"games":    gameKeys,
+		}); err != nil {
+			log.Printf("Error writing session: %v\n", err)
+		}
+		for _, gameKey := range gameKeys {
+			if jsonVersion, ok := e.gameService.GetGameJSON(gameKey); ok {
+				s.write([]byte(jsonVersion))
 			}
+		}
+	}
+}
+
+// newCommandRegistry registers the handlers for every supported command
+func (e *WebsocketController) newCommandRegistry() *CommandRegistry {
+	registry := NewCommandRegistry()
+	Register(registry, "ping", e.handlePing)
+	Register(registry, "addPlayer", e.handleAddPlayer)
+	Register(registry, "joinGame", e.handleJoinGame)
+	Register(registry, "leaveGame", e.handleLeaveGame)
+	Register(registry, "setPlayerName", e.handleSetPlayerName)
+	Register(registry, "rotate", e.handleRotate)
+	Register(registry, "findGame", e.handleFindGame)
+	Register(registry, "startGame", e.handleStartGame)
+	Register(registry, "ready", e.handleReady)
+	Register(registry, "spectate", e.handleSpectate)
+	Register(registry, "addBot", e.handleAddBot)
+	registry.AllowSpectators("ping", "spectate")
+	return registry
+}
+
+func (e *WebsocketController) handlePing(s *Session, id string, cmd PingCommand) error {
+	if s.legacy.Load() {
+		return s.write([]byte("pong"))
+	}
+	return s.Send("pong", id, nil)
+}
+
+// handleSpectate attaches a spectator to a game, it receives the game's
+// snapshots and events after the game's spectator delay
+func (e *WebsocketController) handleSpectate(s *Session, id string, cmd SpectateCommand) error {
+	if !s.Spectator {
+		return newProtocolError(ErrCodeForbidden, "players cannot spectate, connect with ?spectate=<gameKey>")
+	}
+	if _, ok := e.gameService.GetGame(cmd.GameKey); !ok {
+		return newProtocolError(ErrCodeInvalidPayload, "game %v does not exist", cmd.GameKey)
+	}
+	log.Printf("👀 Spectating game: %v\n", cmd.GameKey)
+	e.connectionService.AddSpectator(cmd.GameKey, s.ConnectionID)
+	return s.Send("spectating", id, map[string]interface{}{
+		"gameKey":    cmd.GameKey,
+		"delay":      e.connectionService.SpectatorDelay(cmd.GameKey).Milliseconds(),
+		"spectators": e.connectionService.SpectatorCount(cmd.GameKey),
+	})
+}
+
+func (e *WebsocketController) handleAddPlayer(s *Session, id string, cmd AddPlayerCommand) error {
+	if cmd.PlayerID != s.PlayerID {
+		return newProtocolError(ErrCodeForbidden, "cannot queue as another player")
+	}
+	// the stored rating is used, the client supplied Elo is ignored
+	log.Printf("Adding player: %v (ignoring client Elo %v)\n", cmd.PlayerID, cmd.Elo)
+	if err := e.matchmakingService.EnqueuePlayer(cmd.PlayerID); err != nil {
+		return err
+	}
+
+	ctx := s.matchContext()
+	go e.streamQueueStatus(ctx, s, id, cmd.PlayerID)
+	go e.matchmakingService.ListenForMatch(ctx, cmd.PlayerID, func(matchId string) {
+		log.Printf("Match found for: %v\n", cmd.PlayerID)
+		// the game is created like for findGame so bot matches get their bot
+		_, gameKeyForMatch, ok := e.createMatchGame(matchId)
+		if !ok {
+			return
+		}
+		if s.legacy.Load() {
+			s.write([]byte("matchFound:" + matchId))
+			return
+		}
+		if err := s.Send("matchFound", id, map[string]interface{}{"matchId": matchId, "gameKey": gameKeyForMatch}); err != nil {
+			log.Printf("Error writing matchFound: %v\n", err)
+		}
+	})
+	return nil
+}
+
+func (e *WebsocketController) handleJoinGame(s *Session, id string, cmd JoinGameCommand) error {
+	log.Printf("Joining game: %v\n", cmd.GameKey)
+	if err := e.gameService.JoinGame(cmd.GameKey, s.Player); err != nil {
+		return newProtocolError(ErrCodeForbidden, "%v", err)
+	}
+	e.connectionService.JoinRoom(cmd.GameKey, s.ConnectionID)
+	return nil
+}
+
+func (e *WebsocketController) handleReady(s *Session, id string, cmd ReadyCommand) error {
+	log.Printf("Ready for game: %v\n", cmd.GameKey)
+	if err := e.gameService.SetReady(cmd.GameKey, s.Player); err != nil {
+		return newProtocolError(ErrCodeForbidden, "%v", err)
+	}
+	return nil
+}
+
+func (e *WebsocketController) handleLeaveGame(s *Session, id string, cmd LeaveGameCommand) error {
+	log.Printf("Leaving game: %v\n", cmd.GameKey)
+	e.gameService.LeaveGame(cmd.GameKey, s.Player)
+	e.connectionService.LeaveRoom(cmd.GameKey, s.ConnectionID)
+	return nil
+}
+
+func (e *WebsocketController) handleSetPlayerName(s *Session, id string, cmd SetPlayerNameCommand) error {
+	if err := e.gameService.RenamePlayer(s.Player, cmd.Name); err != nil {
+		return newProtocolError(ErrCodeForbidden, "%v", err)
+	}
+	log.Printf("Setting player name: %v\n", cmd.Name)
+	return s.Send("playerNameSet", id, map[string]interface{}{
+		"playerId": s.Player.ID,
+		"name":     cmd.Name,
+	})
+}
+
+func (e *WebsocketController) handleRotate(s *Session, id string, cmd RotateCommand) error {
+	log.Printf("Rotating: %v\n", *cmd.Rotation)
+
+	// the modes of the player's games decide what the rotation does
+	if err := e.gameService.HandleInput(s.Player, game.Input{Rotation: cmd.Rotation}); err != nil {
+		return newProtocolError(ErrCodeCommandFailed, "Error rotating player")
+	}
+	return nil
+}
+
+func (e *WebsocketController) handleFindGame(s *Session, id string, cmd FindGameCommand) error {
+	player := s.Player
+	log.Printf("Finding game for: %v\n", player.ID)
+
+	// place player in matchmaking queue with their stored rating
+	if err := e.matchmakingService.EnqueuePlayer(player.ID); err != nil {
+		return err
+	}
+
+	// listen for match
+	ctx := s.matchContext()
+	go e.streamQueueStatus(ctx, s, id, player.ID)
+	go e.matchmakingService.ListenForMatch(ctx, player.ID, func(matchId string) {
+		log.Printf("Match found for: %v\n", player.ID)
+
+		matchList, gameKeyForMatch, ok := e.createMatchGame(matchId)
+		if !ok {
+			return
+		}
+
+		if err := s.Send("matchFound", id, map[string]interface{}{
+			"matchList": matchList,
+			"gameKey":   gameKeyForMatch,
+		}); err != nil {
+			log.Printf("Error writing matchFound: %v\n", err)
+		}
+	})
+
+	log.Printf("Matchmaking queue: %v\n", e.matchmakingService.GetPendingPlayers())
+	return nil
+}
 
-			switch msgParts[0] {
-			case "addPlayer":
-				if len(msgParts) < 3 {
-					log.Println("Invalid message format for addPlayer")
-					return
-				}
-				playerElo, err := strconv.ParseFloat(strings.TrimSpace(msgParts[2]), 64)
-				if err != nil {
-					log.Println("Invalid playerElo: \"", msgParts[2], "\"")
-					return
-				}
-				log.Printf("Adding player: %v with Elo: %v\n", msgParts[1], playerElo)
-				e.matchmakingService.AddPlayer(msgParts[1], playerElo)
-
-				e.matchmakingService.ListenForMatch(msgParts[1], func(matchId string) {
-					log.Printf("Match found for: %v\n", msgParts[1])
-					c.WriteMessage(websocket.TextMessage, []byte("matchFound:"+matchId))
-				})
-
-			// joinGame, gameKey
-			case "joinGame":
-				if len(msgParts) < 2 {
-					log.Println("Invalid message format for joinGame")
-					return
-				}
-				gameKey := msgParts[1]
-				log.Printf("Joining game: %v\n", gameKey)
-				e.gameService.JoinGame(gameKey, player)
-
-			// leaveGame, gameKey
-			case "leaveGame":
-				if len(msgParts) < 2 {
-					log.Println("Invalid message format for leaveGame")
-					return
-				}
-				gameKey := msgParts[1]
-				log.Printf("Leaving game: %v\n", gameKey)
-				e.gameService.LeaveGame(gameKey, player)
-
-			// setPlayerName, name
-			case "setPlayerName":
-				if len(msgParts) < 2 {
-					log.Println("Invalid message format for setPlayerName")
-					return
-				}
-				name := msgParts[1]
-				player.Name = name
-				log.Printf("Setting player name: %v\n", name)
-				var response = map[string]interface{}{
-					"command": "playerNameSet",
-					"name":    name,
-				}
-				payload, err := json.Marshal(response)
-				if err != nil {
-					log.Printf("Error marshalling playerNameSet payload: %v\n", err)
-					return
-				}
-
-				c.WriteMessage(websocket.TextMessage, payload)
-
-			// rotate, direction
-			case "rotate":
-				if len(msgParts) < 2 {
-					log.Println("Invalid message format for rotate")
-					return
-				}
-				rotation := msgParts[1]
-
-				log.Printf("Rotating: %v\n", rotation)
-
-				// convert string rotation to float64
-				rotationFloat, err := strconv.ParseFloat(rotation, 64)
-				if err != nil {
-					log.Printf("Invalid rotation value: %v\n", rotation)
-					c.WriteMessage(websocket.TextMessage, []byte("Invalid rotation value"))
-					continue
-				}
-
-				// Apply rotation to the player's game object
-				err = e.gameService.RotatePlayer(player, rotationFloat)
-				if err != nil {
-					log.Printf("Error rotating player: %v\n", err)
-					c.WriteMessage(websocket.TextMessage, []byte("Error rotating player"))
-					continue
-				}
-
-			case "findGame":
-				log.Printf("Finding game for: %v\n", player.Name)
-
-				// place player in matchmaking queue
-				e.matchmakingService.AddPlayer(player.Name, 100)
-
-				// listen for match
-				e.matchmakingService.ListenForMatch(player.Name, func(matchId string) {
-					log.Printf("Match found for: %v\n", player.Name)
-
-					var matchList []string
-					json.Unmarshal([]byte(matchId), &matchList)
-					gameKeyForMatch := fmt.Sprintf("%v_%v", matchList[0], matchList[1])
-					payload, err := json.Marshal(
-						map[string]interface{}{
-							"command":   "matchFound",
-							"matchList": matchList,
-							"gameKey":   gameKeyForMatch,
-						},
-					)
-					if err != nil {
-						log.Printf("Error marshalling matchFound payload: %v\n", err)
-						return
-					}
-
-					newGame := &game.Game{
-						State:   "new",
-						Players: make(map[string]*game.Player),
-					}
-
-					e.gameService.AddGame(gameKeyForMatch, newGame)
-
-					c.WriteMessage(websocket.TextMessage, payload)
-
-				})
-
-				log.Printf("Matchmaking queue: %v\n", e.matchmakingService.GetPendingPlayers())
-
-			case "startGame":
-				gameKey := msgParts[1]
-
-				log.Printf("Starting game: %v\n", gameKey)
-
-				newGame := &game.Game{
-					State:   "new",
-					Players: make(map[string]*game.Player),
-				}
-
-				e.gameService.AddGame(gameKey, newGame)
-				e.gameService.JoinGame(gameKey, player)
-
-			default:
-				log.Println("Unknown command:", msgParts[0])
+// createMatchGame creates the ranked game of a published match and adds its bots,
+// it returns the matched players and the game's key
+func (e *WebsocketController) createMatchGame(matchId string) ([]string, string, bool) {
+	var matchList []string
+	if err := json.Unmarshal([]byte(matchId), &matchList); err != nil || len(matchList) < 2 {
+		log.Printf("Invalid match payload: %v\n", matchId)
+		return nil, "", false
+	}
+	gameKeyForMatch := fmt.Sprintf("%v_%v", matchList[0], matchList[1])
+	var bots []string
+	for _, playerId := range matchList {
+		if bot.IsID(playerId) {
+			bots = append(bots, playerId)
+		}
+	}
+
+	// both players get this callback, whoever is first creates the game
+	newGame := game.NewGame(game.RankedLifecycleConfig())
+	// bots have no rating, games against them are played by the ranked rules but not rated
+	newGame.Ranked = len(bots) == 0
+	newGame.Scoring = game.RankedScoringConfig()
+	newGame.Mode = modes.NewSnakeMode()
+	newGame.World = e.newWorld()
+	if config, ok := e.gameService.GetPreset(game.RankedPreset); ok {
+		newGame.Config = config
+	}
+	// ranked games are always recorded to settle disputes
+	newGame.Recorder = e.replayService.Recorder(gameKeyForMatch, newGame)
+	if e.gameService.AddGameIfAbsent(gameKeyForMatch, newGame) == newGame {
+		e.connectionService.SetSpectatorDelay(gameKeyForMatch, newGame.Config.SpectatorDelay())
+		for _, botId := range bots {
+			if _, err := e.botService.AddBotWithID(gameKeyForMatch, botId); err != nil {
+				log.Printf("Error adding bot %v to %v: %v\n", botId, gameKeyForMatch, err)
 			}
+		}
+	}
+	return matchList, gameKeyForMatch, true
+}
+
+// queueStatusInterval is how often a queued client is told about its search window
+const queueStatusInterval = 1 * time.Second
 
+// streamQueueStatus sends queue status updates until the player leaves the queue
+// or the connection closes
+func (e *WebsocketController) streamQueueStatus(ctx context.Context, s *Session, id string, playerId string) {
+	ticker := time.NewTicker(queueStatusInterval)
+	defer ticker.Stop()
+
+	for {
+		status, ok := e.matchmakingService.GetQueueStatus(playerId)
+		if !ok {
+			return
+		}
+		if err := s.Send("queueStatus", id, status); err != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
 		}
 	}
+}
 
-	return websocket.New(handler)(c)
+func (e *WebsocketController) handleStartGame(s *Session, id string, cmd StartGameCommand) error {
+	log.Printf("Starting game: %v\n", cmd.GameKey)
+
+	lifecycle := game.DefaultLifecycleConfig()
+	lifecycle.ReadyCheck = cmd.ReadyCheck
+	newGame := game.NewGame(lifecycle)
+	if cmd.Scoring != "" {
+		newGame.Scoring.Mode = game.ScoreMode(cmd.Scoring)
+	}
+	if cmd.Rounds > 0 {
+		newGame.Scoring.Rounds = cmd.Rounds
+	}
+	if cmd.Preset != "" {
+		config, ok := e.gameService.GetPreset(cmd.Preset)
+		if !ok {
+			return newProtocolError(ErrCodeInvalidPayload, "unknown preset %q", cmd.Preset)
+		}
+		newGame.Config = config
+	}
+	if cmd.Config != nil {
+		newGame.Config = *cmd.Config
+	}
+	mode, err := modes.New(cmd.Mode)
+	if err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "%v", err)
+	}
+	newGame.Mode = mode
+	newGame.World = e.newWorld()
+	newGame.Host = s.Player.ID
+	if cmd.Seed != 0 {
+		newGame.Seed = cmd.Seed
+	}
+	if cmd.Record {
+		newGame.Recorder = e.replayService.Recorder(cmd.GameKey, newGame)
+	}
+	// a running game, ranked or not, is never replaced by another player's game
+	if e.gameService.AddGameIfAbsent(cmd.GameKey, newGame) != newGame {
+		return newProtocolError(ErrCodeForbidden, "game %v already exists", cmd.GameKey)
+	}
+	e.connectionService.SetSpectatorDelay(cmd.GameKey, newGame.Config.SpectatorDelay())
+	if err := e.gameService.JoinGame(cmd.GameKey, s.Player); err != nil {
+		return err
+	}
+	e.connectionService.JoinRoom(cmd.GameKey, s.ConnectionID)
+	return nil
+}
+
+// handleAddBot adds a bot to a game, only the player that started the game may add them
+func (e *WebsocketController) handleAddBot(s *Session, id string, cmd AddBotCommand) error {
+	level, err := bot.ParseLevel(cmd.Level)
+	if err != nil {
+		return newProtocolError(ErrCodeInvalidPayload, "%v", err)
+	}
+	isHost := false
+	if !e.gameService.WithGame(cmd.GameKey, func(g *game.Game) {
+		isHost = g.Host == s.Player.ID
+	}) {
+		return newProtocolError(ErrCodeInvalidPayload, "game %v does not exist", cmd.GameKey)
+	}
+	if !isHost {
+		return newProtocolError(ErrCodeForbidden, "only the host of %v can add bots", cmd.GameKey)
+	}
+
+	player, err := e.botService.AddBot(cmd.GameKey, level)
+	if err != nil {
+		return newProtocolError(ErrCodeForbidden, "%v", err)
+	}
+	return s.Send("botAdded", id, map[string]interface{}{
+		"gameKey":  cmd.GameKey,
+		"playerId": player.ID,
+		"name":     player.Name,
+		"level":    level,
+	})
 }
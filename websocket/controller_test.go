@@ -0,0 +1,163 @@
+package websocket
+
+import (
+	"drbh/partita/bot"
+	"drbh/partita/collision"
+	"drbh/partita/connection"
+	"drbh/partita/game"
+	"drbh/partita/match"
+	"drbh/partita/memory"
+	"drbh/partita/replay"
+	"drbh/partita/session"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConn records what a ConnectionWriter writes
+type fakeConn struct {
+	mu      sync.Mutex
+	written []string
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, string(data))
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) messages() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.written...)
+}
+
+func TestStartGameKeepsExistingGame(t *testing.T) {
+	gameService := game.NewGameService()
+	gameService.ManualTicks = true
+	controller := WebsocketController{
+		connectionService: &connection.ConnectionService{},
+		gameService:       gameService,
+		newWorld:          collision.ProvideWorldFactory(),
+	}
+	running := game.NewGame(game.DefaultLifecycleConfig())
+	running.Ranked = true
+	gameService.AddGame("ranked", running)
+
+	intruder := &Session{ConnectionID: "intruder", Player: gameService.NewPlayer("intruder")}
+	err := controller.handleStartGame(intruder, "", StartGameCommand{GameKey: "ranked"})
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) || protocolError.Code != ErrCodeForbidden {
+		t.Errorf("handleStartGame failed, expected %v, got %v", ErrCodeForbidden, err)
+	}
+	if existing, _ := gameService.GetGame("ranked"); existing != running || existing.Host != "" {
+		t.Errorf("handleStartGame failed, expected %v, got %v", "the ranked game to keep running", existing)
+	}
+}
+
+func TestProtocolIsFrozenOnFirstFrame(t *testing.T) {
+	controller := WebsocketController{connectionService: &connection.ConnectionService{}}
+	controller.commands = controller.newCommandRegistry()
+	s := &Session{ConnectionID: "racer", connections: controller.connectionService}
+
+	// a match callback replies while frames keep arriving
+	matched := make(chan struct{})
+	go func() {
+		defer close(matched)
+		for i := 0; i < 200; i++ {
+			s.Send("matchFound", "", map[string]interface{}{"matchId": "match"})
+			s.SendError("", errors.New("failed"))
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		if i%2 == 0 {
+			controller.handleFrame(s, []byte("ping"))
+		} else {
+			controller.handleFrame(s, []byte(`{"v":1,"type":"ping"}`))
+		}
+	}
+	<-matched
+
+	if !s.legacy.Load() {
+		t.Errorf("handleFrame failed, expected %v, got %v", "the legacy protocol of the first frame", "JSON")
+	}
+}
+
+func TestSessionHelloWaitsForProtocol(t *testing.T) {
+	gameService := game.NewGameService()
+	connectionService := &connection.ConnectionService{Writers: make(map[string]*connection.ConnectionWriter)}
+	conn := &fakeConn{}
+	connectionService.Writers["legacy"] = connection.NewConnectionWriter("legacy", conn, connection.DefaultWriterConfig())
+	controller := WebsocketController{
+		connectionService: connectionService,
+		gameService:       gameService,
+		sessionService:    session.NewSessionService(session.NewSigner([]byte("secret")), time.Minute),
+	}
+	controller.commands = controller.newCommandRegistry()
+	s := &Session{ConnectionID: "legacy", PlayerID: "legacy", connections: connectionService}
+
+	controller.openSession(s, "")
+	time.Sleep(10 * time.Millisecond)
+	if written := conn.messages(); len(written) != 0 {
+		t.Errorf("openSession failed, expected %v, got %v", "no hello before the first frame", written)
+	}
+
+	controller.handleFrame(s, []byte("ping"))
+	deadline := time.Now().Add(time.Second)
+	for len(conn.messages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	written := conn.messages()
+	if len(written) < 2 || !strings.HasPrefix(written[0], `{"command":"session"`) {
+		t.Errorf("handleFrame failed, expected %v, got %v", "a legacy session hello first", written)
+	}
+}
+
+func TestAddPlayerBotMatchCreatesGame(t *testing.T) {
+	gameService := game.NewGameService()
+	gameService.ManualTicks = true
+	store := memory.NewMemoryMatchStore()
+	controller := WebsocketController{
+		connectionService:  &connection.ConnectionService{},
+		matchmakingService: match.NewMatchmakingService(store),
+		gameService:        gameService,
+		replayService:      replay.NewReplayService(t.TempDir(), collision.ProvideWorldFactory()),
+		botService:         bot.NewBotService(gameService),
+		newWorld:           collision.ProvideWorldFactory(),
+	}
+	s := &Session{ConnectionID: "queued", PlayerID: "queued", connections: controller.connectionService}
+	if err := controller.handleAddPlayer(s, "", AddPlayerCommand{PlayerID: "queued", Elo: 1000}); err != nil {
+		t.Fatalf("handleAddPlayer failed, expected %v, got %v", nil, err)
+	}
+	defer s.cancelMatch()
+
+	// bot fill publishes a match against a bot that only exists once the game adds it
+	botID := bot.NewID(bot.Easy)
+	gameKey := "queued_" + botID
+	countBots := func() int {
+		bots := 0
+		gameService.WithGame(gameKey, func(g *game.Game) {
+			for _, player := range g.Players {
+				if player.IsBot() {
+					bots++
+				}
+			}
+		})
+		return bots
+	}
+	deadline := time.Now().Add(time.Second)
+	for countBots() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("handleAddPlayer failed, expected %v, got %v", "a game with the bot", countBots())
+		}
+		store.PublishMatch(`["queued","` + botID + `"]`)
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,183 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProtocolVersion is the current version of the websocket message envelope
+const ProtocolVersion = 1
+
+// Error codes sent back to clients in structured error replies
+const (
+	ErrCodeBadEnvelope        = "bad_envelope"
+	ErrCodeUnsupportedVersion = "unsupported_version"
+	ErrCodeUnknownCommand     = "unknown_command"
+	ErrCodeInvalidPayload     = "invalid_payload"
+	ErrCodeCommandFailed      = "command_failed"
+	ErrCodeForbidden          = "forbidden"
+)
+
+// Envelope is the versioned wrapper around every message exchanged over the websocket
+// e.g. {"v":1,"type":"rotate","id":"42","payload":{"rotation":3.14}}
+type Envelope struct {
+	V       int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// ErrorPayload is the payload of an "error" reply
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ProtocolError is an error that is reported back to the client with a code
+type ProtocolError struct {
+	Code    string
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// newProtocolError creates a ProtocolError with a formatted message
+func newProtocolError(code string, format string, args ...interface{}) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// DecodeMessage decodes a raw websocket frame into an Envelope. Frames that do not
+// look like JSON are treated as the legacy colon separated format and the returned
+// bool reports whether the legacy shim was used.
+func DecodeMessage(msg []byte) (Envelope, bool, error) {
+	trimmed := strings.TrimSpace(string(msg))
+	if !strings.HasPrefix(trimmed, "{") {
+		envelope, err := ParseLegacyMessage(trimmed)
+		return envelope, true, err
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal([]byte(trimmed), &envelope); err != nil {
+		return Envelope{}, false, newProtocolError(ErrCodeBadEnvelope, "invalid JSON envelope: %v", err)
+	}
+	if envelope.V != ProtocolVersion {
+		return envelope, false, newProtocolError(ErrCodeUnsupportedVersion, "unsupported protocol version %d", envelope.V)
+	}
+	if envelope.Type == "" {
+		return envelope, false, newProtocolError(ErrCodeBadEnvelope, "missing message type")
+	}
+	return envelope, false, nil
+}
+
+// ParseLegacyMessage converts the legacy "command:arg:arg" format into an Envelope
+// so that old clients keep working while they migrate to the JSON protocol
+func ParseLegacyMessage(msg string) (Envelope, error) {
+	command, rest, _ := strings.Cut(msg, ":")
+	if command == "" {
+		return Envelope{}, newProtocolError(ErrCodeBadEnvelope, "Invalid message format")
+	}
+
+	var payload interface{}
+	switch command {
+	case "ping", "findGame":
+		payload = struct{}{}
+
+	// addPlayer, playerId, elo
+	case "addPlayer":
+		// the elo is always the last part so player IDs may contain colons
+		index := strings.LastIndex(rest, ":")
+		if index < 0 {
+			return Envelope{}, newProtocolError(ErrCodeInvalidPayload, "Invalid message format for addPlayer")
+		}
+		playerElo, err := strconv.ParseFloat(strings.TrimSpace(rest[index+1:]), 64)
+		if err != nil {
+			return Envelope{}, newProtocolError(ErrCodeInvalidPayload, "Invalid playerElo: %q", rest[index+1:])
+		}
+		payload = AddPlayerCommand{PlayerID: rest[:index], Elo: playerElo}
+
+	// joinGame, gameKey
+	case "joinGame":
+		payload = JoinGameCommand{GameKey: rest}
+
+	// leaveGame, gameKey
+	case "leaveGame":
+		payload = LeaveGameCommand{GameKey: rest}
+
+	// startGame, gameKey, (ignored legacy arguments)
+	case "startGame":
+		gameKey, _, _ := strings.Cut(rest, ":")
+		payload = StartGameCommand{GameKey: gameKey}
+
+	// ready, gameKey
+	case "ready":
+		payload = ReadyCommand{GameKey: rest}
+
+	// spectate, gameKey
+	case "spectate":
+		payload = SpectateCommand{GameKey: rest}
+
+	// addBot, gameKey, (level)
+	case "addBot":
+		gameKey, level, _ := strings.Cut(rest, ":")
+		payload = AddBotCommand{GameKey: gameKey, Level: level}
+
+	// setPlayerName, name
+	case "setPlayerName":
+		payload = SetPlayerNameCommand{Name: rest}
+
+	// rotate, direction
+	case "rotate":
+		rotation, err := strconv.ParseFloat(strings.TrimSpace(rest), 64)
+		if err != nil {
+			return Envelope{}, newProtocolError(ErrCodeInvalidPayload, "Invalid rotation value")
+		}
+		rotate := RotateCommand{Rotation: &rotation}
+		// validated before encoding since NaN and infinities cannot be encoded at all
+		if err := rotate.Validate(); err != nil {
+			return Envelope{}, newProtocolError(ErrCodeInvalidPayload, "%v", err)
+		}
+		payload = rotate
+
+	default:
+		return Envelope{V: ProtocolVersion, Type: command}, nil
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, newProtocolError(ErrCodeInvalidPayload, "%v", err)
+	}
+	return Envelope{V: ProtocolVersion, Type: command, Payload: raw}, nil
+}
+
+// encodeLegacyReply flattens a reply into the legacy {"command": type, ...payload} shape
+func encodeLegacyReply(msgType string, payload interface{}) ([]byte, error) {
+	fields := map[string]interface{}{}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+	}
+	fields["command"] = msgType
+	return json.Marshal(fields)
+}
+
+// encodeReply wraps a reply payload in a versioned Envelope
+func encodeReply(msgType string, id string, payload interface{}) ([]byte, error) {
+	envelope := Envelope{V: ProtocolVersion, Type: msgType, ID: id}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		envelope.Payload = raw
+	}
+	return json.Marshal(envelope)
+}
@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDecodeMessageEnvelope(t *testing.T) {
+	envelope, legacy, err := DecodeMessage([]byte(`{"v":1,"type":"rotate","id":"7","payload":{"rotation":1.5}}`))
+	if err != nil {
+		t.Fatalf("DecodeMessage failed, expected %v, got %v", "nil", err)
+	}
+	if legacy {
+		t.Errorf("DecodeMessage failed, expected %v, got %v", false, legacy)
+	}
+	if envelope.Type != "rotate" || envelope.ID != "7" {
+		t.Errorf("DecodeMessage failed, expected %v, got %v", "rotate/7", envelope.Type+"/"+envelope.ID)
+	}
+}
+
+func TestDecodeMessageUnsupportedVersion(t *testing.T) {
+	_, _, err := DecodeMessage([]byte(`{"v":99,"type":"rotate"}`))
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) || protocolError.Code != ErrCodeUnsupportedVersion {
+		t.Errorf("DecodeMessage failed, expected %v, got %v", ErrCodeUnsupportedVersion, err)
+	}
+}
+
+func TestParseLegacyMessageWithColonInName(t *testing.T) {
+	envelope, legacy, err := DecodeMessage([]byte("setPlayerName:snake:eyes"))
+	if err != nil {
+		t.Fatalf("DecodeMessage failed, expected %v, got %v", "nil", err)
+	}
+	if !legacy {
+		t.Errorf("DecodeMessage failed, expected %v, got %v", true, legacy)
+	}
+	var cmd SetPlayerNameCommand
+	json.Unmarshal(envelope.Payload, &cmd)
+	if cmd.Name != "snake:eyes" {
+		t.Errorf("ParseLegacyMessage failed, expected %v, got %v", "snake:eyes", cmd.Name)
+	}
+}
+
+func TestParseLegacyAddPlayer(t *testing.T) {
+	envelope, err := ParseLegacyMessage("addPlayer:a:b:1200")
+	if err != nil {
+		t.Fatalf("ParseLegacyMessage failed, expected %v, got %v", "nil", err)
+	}
+	var cmd AddPlayerCommand
+	json.Unmarshal(envelope.Payload, &cmd)
+	if cmd.PlayerID != "a:b" || cmd.Elo != 1200 {
+		t.Errorf("ParseLegacyMessage failed, expected %v, got %v", "a:b/1200", cmd)
+	}
+}
+
+func TestParseLegacyReady(t *testing.T) {
+	envelope, err := ParseLegacyMessage("ready:lobby")
+	if err != nil {
+		t.Fatalf("ParseLegacyMessage failed, expected %v, got %v", "nil", err)
+	}
+	var cmd ReadyCommand
+	json.Unmarshal(envelope.Payload, &cmd)
+	if envelope.Type != "ready" || cmd.GameKey != "lobby" {
+		t.Errorf("ParseLegacyMessage failed, expected %v, got %v", "ready/lobby", cmd)
+	}
+}
+
+func TestDispatchValidatesPayload(t *testing.T) {
+	registry := NewCommandRegistry()
+	called := false
+	Register(registry, "rotate", func(s *Session, id string, cmd RotateCommand) error {
+		called = true
+		return nil
+	})
+
+	err := registry.Dispatch(&Session{}, Envelope{V: 1, Type: "rotate", Payload: json.RawMessage(`{}`)})
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) || protocolError.Code != ErrCodeInvalidPayload {
+		t.Errorf("Dispatch failed, expected %v, got %v", ErrCodeInvalidPayload, err)
+	}
+
+	err = registry.Dispatch(&Session{}, Envelope{V: 1, Type: "rotate", Payload: json.RawMessage(`{"rotation":0}`)})
+	if err != nil || !called {
+		t.Errorf("Dispatch failed, expected %v, got %v", "nil", err)
+	}
+}
+
+func TestRotateRejectsNonFiniteRotations(t *testing.T) {
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if err := (RotateCommand{Rotation: &value}).Validate(); err == nil {
+			t.Errorf("Validate failed, expected %v, got %v", "an error", nil)
+		}
+	}
+	for _, message := range []string{"rotate:NaN", "rotate:Inf", "rotate:-inf"} {
+		_, err := ParseLegacyMessage(message)
+		var protocolError *ProtocolError
+		if !errors.As(err, &protocolError) || protocolError.Code != ErrCodeInvalidPayload {
+			t.Errorf("ParseLegacyMessage failed for %v, expected %v, got %v", message, ErrCodeInvalidPayload, err)
+		}
+	}
+}
+
+func TestDispatchUnknownCommand(t *testing.T) {
+	registry := NewCommandRegistry()
+	err := registry.Dispatch(&Session{}, Envelope{V: 1, Type: "nope"})
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) || protocolError.Code != ErrCodeUnknownCommand {
+		t.Errorf("Dispatch failed, expected %v, got %v", ErrCodeUnknownCommand, err)
+	}
+}
+
+func TestEncodeLegacyReply(t *testing.T) {
+	message, err := encodeLegacyReply("playerNameSet", map[string]interface{}{"name": "snake"})
+	if err != nil {
+		t.Fatalf("encodeLegacyReply failed, expected %v, got %v", "nil", err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(message, &fields)
+	if fields["command"] != "playerNameSet" || fields["name"] != "snake" {
+		t.Errorf("encodeLegacyReply failed, expected %v, got %v", "playerNameSet/snake", fields)
+	}
+}
+
+func TestDispatchRejectsSpectatorGameplay(t *testing.T) {
+	registry := NewCommandRegistry()
+	Register(registry, "rotate", func(s *Session, id string, cmd RotateCommand) error {
+		return nil
+	})
+	Register(registry, "ping", func(s *Session, id string, cmd PingCommand) error {
+		return nil
+	})
+	registry.AllowSpectators("ping")
+
+	spectator := &Session{Spectator: true}
+	err := registry.Dispatch(spectator, Envelope{V: 1, Type: "rotate", Payload: json.RawMessage(`{"rotation":0}`)})
+	var protocolError *ProtocolError
+	if !errors.As(err, &protocolError) || protocolError.Code != ErrCodeForbidden {
+		t.Errorf("Dispatch failed, expected %v, got %v", ErrCodeForbidden, err)
+	}
+	if err := registry.Dispatch(spectator, Envelope{V: 1, Type: "ping"}); err != nil {
+		t.Errorf("Dispatch failed, expected %v, got %v", nil, err)
+	}
+}
@@ -6,12 +6,17 @@ package main
 
 // Importing necessary packages.
 import (
+	"drbh/partita/auth"
 	"drbh/partita/background"
+	"drbh/partita/bot"
 	"drbh/partita/collision"
 	"drbh/partita/connection"
 	"drbh/partita/game"
 	"drbh/partita/match"
+	"drbh/partita/memory"
 	"drbh/partita/redis"
+	"drbh/partita/replay"
+	"drbh/partita/session"
 	"drbh/partita/websocket"
 
 	"github.com/google/wire"
@@ -21,14 +26,20 @@ import (
 var SuperSet = wire.NewSet(
 	connection.ProvideConnectionService,
 	redis.ProvideMyRedisService,
+	memory.ProvideMemoryMatchStore,
 	game.ProvideGameService,
+	session.ProvideSessionService,
+	auth.ProvideAuthService,
+	replay.ProvideReplayService,
+	bot.ProvideBotService,
+	collision.ProvideWorldFactory,
 	// background.ProvideBackgroundService,
 )
 
 // InitializeMatch is a Wire provider function that provides an instance of MatchmakingController.
 func InitializeMatch() match.MatchmakingController {
 	// Wire will use the providers in the Build call to inject the necessary dependencies.
-	wire.Build(match.NewMatchmakingController, match.NewMatchmakingService, redis.GetMyRedisServiceInstance)
+	wire.Build(match.NewMatchmakingController, match.NewMatchmakingService, match.ProvideMatchStore)
 	// An empty MatchmakingController is returned. Wire will replace this with the actual instance.
 	return match.MatchmakingController{}
 }
@@ -40,8 +51,11 @@ func InitializeWebSocket() websocket.WebsocketController {
 		websocket.NewWebsocketController,
 		connection.GetConnectionServiceInstance,
 		game.GetGameServiceInstance,
-		match.NewMatchmakingService, redis.GetMyRedisServiceInstance,
-		collision.GetLineSegmentManagerInstance,
+		match.NewMatchmakingService, match.ProvideMatchStore,
+		session.GetSessionServiceInstance,
+		replay.GetReplayServiceInstance,
+		bot.GetBotServiceInstance,
+		collision.ProvideWorldFactory,
 	)
 	// An empty WebsocketController is returned. Wire will replace this with the actual instance.
 	return websocket.WebsocketController{}
@@ -55,6 +69,30 @@ func InitalizeConnection() *connection.ConnectionService {
 	return &connection.ConnectionService{}
 }
 
+// InitializeAuth is a Wire provider function that provides an instance of AuthController.
+func InitializeAuth() auth.AuthController {
+	// Wire will use the providers in the Build call to inject the necessary dependencies.
+	wire.Build(auth.NewAuthController, auth.GetAuthServiceInstance)
+	// An empty AuthController is returned. Wire will replace this with the actual instance.
+	return auth.AuthController{}
+}
+
+// InitializeConnectionController is a Wire provider function that provides an instance of ConnectionController.
+func InitializeConnectionController() connection.ConnectionController {
+	// Wire will use the providers in the Build call to inject the necessary dependencies.
+	wire.Build(connection.NewConnectionController, connection.GetConnectionServiceInstance)
+	// An empty ConnectionController is returned. Wire will replace this with the actual instance.
+	return connection.ConnectionController{}
+}
+
+// InitializeReplay is a Wire provider function that provides an instance of ReplayController.
+func InitializeReplay() replay.ReplayController {
+	// Wire will use the providers in the Build call to inject the necessary dependencies.
+	wire.Build(replay.NewReplayController, replay.GetReplayServiceInstance)
+	// An empty ReplayController is returned. Wire will replace this with the actual instance.
+	return replay.ReplayController{}
+}
+
 // InitializeBackgroundService is a Wire provider function that provides an instance of BackgroundService.
 func InitializeBackgroundService() background.BackgroundServiceInterface {
 	// Wire will use the provider in the Build call to inject the necessary dependencies.
@@ -63,8 +101,7 @@ func InitializeBackgroundService() background.BackgroundServiceInterface {
 		connection.GetConnectionServiceInstance,
 		game.GetGameServiceInstance,
 		// TODO: fix that both required below since NewMatchmakingService is not a pointer
-		match.NewMatchmakingService, redis.GetMyRedisServiceInstance,
-		collision.GetLineSegmentManagerInstance,
+		match.NewMatchmakingService, match.ProvideMatchStore,
 	)
 	// An empty BackgroundService is returned. Wire will replace this with the actual instance.
 	// return &background.BackgroundService{}
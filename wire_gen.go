@@ -7,12 +7,17 @@
 package main
 
 import (
+	"drbh/partita/auth"
 	"drbh/partita/background"
+	"drbh/partita/bot"
 	"drbh/partita/collision"
 	"drbh/partita/connection"
 	"drbh/partita/game"
 	"drbh/partita/match"
+	"drbh/partita/memory"
 	"drbh/partita/redis"
+	"drbh/partita/replay"
+	"drbh/partita/session"
 	"drbh/partita/websocket"
 	"github.com/google/wire"
 )
@@ -21,8 +26,8 @@ import (
 
 // InitializeMatch is a Wire provider function that provides an instance of MatchmakingController.
 func InitializeMatch() match.MatchmakingController {
-	myRedisService := redis.GetMyRedisServiceInstance()
-	matchmakingService := match.NewMatchmakingService(myRedisService)
+	matchStore := match.ProvideMatchStore()
+	matchmakingService := match.NewMatchmakingService(matchStore)
 	matchmakingController := match.NewMatchmakingController(matchmakingService)
 	return matchmakingController
 }
@@ -30,11 +35,14 @@ func InitializeMatch() match.MatchmakingController {
 // InitializeWebSocket is a Wire provider function that provides an instance of WebsocketController.
 func InitializeWebSocket() websocket.WebsocketController {
 	connectionService := connection.GetConnectionServiceInstance()
-	myRedisService := redis.GetMyRedisServiceInstance()
-	matchmakingService := match.NewMatchmakingService(myRedisService)
+	matchStore := match.ProvideMatchStore()
+	matchmakingService := match.NewMatchmakingService(matchStore)
 	gameService := game.GetGameServiceInstance()
-	lineSegmentManager := collision.GetLineSegmentManagerInstance()
-	websocketController := websocket.NewWebsocketController(connectionService, matchmakingService, gameService, lineSegmentManager)
+	sessionService := session.GetSessionServiceInstance()
+	replayService := replay.GetReplayServiceInstance()
+	botService := bot.GetBotServiceInstance()
+	worldFactory := collision.ProvideWorldFactory()
+	websocketController := websocket.NewWebsocketController(connectionService, matchmakingService, gameService, sessionService, replayService, botService, worldFactory)
 	return websocketController
 }
 
@@ -44,18 +52,38 @@ func InitalizeConnection() *connection.ConnectionService {
 	return connectionService
 }
 
+// InitializeAuth is a Wire provider function that provides an instance of AuthController.
+func InitializeAuth() auth.AuthController {
+	authService := auth.GetAuthServiceInstance()
+	authController := auth.NewAuthController(authService)
+	return authController
+}
+
+// InitializeConnectionController is a Wire provider function that provides an instance of ConnectionController.
+func InitializeConnectionController() connection.ConnectionController {
+	connectionService := connection.GetConnectionServiceInstance()
+	connectionController := connection.NewConnectionController(connectionService)
+	return connectionController
+}
+
+// InitializeReplay is a Wire provider function that provides an instance of ReplayController.
+func InitializeReplay() replay.ReplayController {
+	replayService := replay.GetReplayServiceInstance()
+	replayController := replay.NewReplayController(replayService)
+	return replayController
+}
+
 // InitializeBackgroundService is a Wire provider function that provides an instance of BackgroundService.
 func InitializeBackgroundService() background.BackgroundServiceInterface {
 	connectionService := connection.GetConnectionServiceInstance()
-	myRedisService := redis.GetMyRedisServiceInstance()
-	matchmakingService := match.NewMatchmakingService(myRedisService)
+	matchStore := match.ProvideMatchStore()
+	matchmakingService := match.NewMatchmakingService(matchStore)
 	gameService := game.GetGameServiceInstance()
-	lineSegmentManager := collision.GetLineSegmentManagerInstance()
-	backgroundServiceInterface := background.NewBackgroundService(connectionService, matchmakingService, gameService, lineSegmentManager)
+	backgroundServiceInterface := background.NewBackgroundService(connectionService, matchmakingService, gameService)
 	return backgroundServiceInterface
 }
 
 // wire.go:
 
 // SuperSet is a Wire provider set that includes all the providers needed for the application.
-var SuperSet = wire.NewSet(connection.ProvideConnectionService, redis.ProvideMyRedisService, game.ProvideGameService)
+var SuperSet = wire.NewSet(connection.ProvideConnectionService, redis.ProvideMyRedisService, memory.ProvideMemoryMatchStore, game.ProvideGameService, session.ProvideSessionService, auth.ProvideAuthService, replay.ProvideReplayService, bot.ProvideBotService, collision.ProvideWorldFactory)